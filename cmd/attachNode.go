@@ -9,7 +9,9 @@ import (
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/inventory"
 	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -53,12 +55,28 @@ func init() {
 	attachNodeCmd.Flags().StringSliceVarP(&workerIPs, "worker-ip", "w", []string{}, "worker node ip address")
 	attachNodeCmd.Flags().StringVarP(&clusterUuid, "uuid", "u", "", "uuid of the cluster to attach the node to")
 	attachNodeCmd.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+	addInventoryFlags(attachNodeCmd)
 	rootCmd.AddCommand(attachNodeCmd)
 }
 
 func attachNodeRun(cmd *cobra.Command, args []string) {
 	zap.S().Debug("==========Running Attach Node==========")
 
+	if hosts, err := selectInventoryHosts(); err != nil {
+		zap.S().Fatalf("Unable to load the inventory: %s", err.Error())
+	} else if hosts != nil {
+		if len(masterIPs) > 0 || len(workerIPs) > 0 {
+			zap.S().Fatal("--master-ip and --worker-ip can not be used with --inventory, the roles of the nodes come from the inventory")
+		}
+		masterIPs = inventoryRoleIPs(hosts, inventory.RoleMaster)
+		workerIPs = inventoryRoleIPs(hosts, inventory.RoleWorker)
+		for _, h := range hosts {
+			if h.Role == "" {
+				fmt.Println(color.Yellow("! ") + "Skipping " + h.Name + ", it has no role in the inventory")
+			}
+		}
+	}
+
 	detachedMode := cmd.Flags().Changed("no-prompt")
 
 	if cmdexec.CheckRemote(nc) {
@@ -83,6 +101,7 @@ func attachNodeRun(cmd *cobra.Command, args []string) {
 	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, nc); err != nil {
 		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
 	}
+	defer executor.Close()
 
 	var c client.Client
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
@@ -99,105 +118,14 @@ func attachNodeRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		zap.S().Fatalf("Failed to get keystone %s", err.Error())
 	}
-	projectId := auth.ProjectID
-	token := auth.Token
 	if clusterUuid != "" {
-		if clusterName, err = c.Qbert.CheckClusterExistsWithUuid(clusterUuid, projectId, token); err != nil {
+		if clusterName, err = c.Qbert.CheckClusterExistsWithUuid(clusterUuid, auth.ProjectID, auth.Token); err != nil {
 			zap.S().Fatalf("unable to verify cluster using uuid %s", err.Error())
 		} else if clusterName == "" {
 			zap.S().Fatalf("cluster with given uuid does not exist")
 		}
 	}
-	_, clusterUuid, clusterStatus, err := c.Qbert.CheckClusterExists(clusterName, projectId, token)
-	if err != nil {
-		zap.S().Fatalf("unable to fetch cluster-uuid and cluster-status from cluster-name. Error: %s", err.Error())
-	}
-
-	if clusterStatus == "ok" {
-
-		// master ips
-		var masterHostIDs []string
-		if len(masterIPs) > 0 {
-			masterHostIDs = c.Resmgr.GetHostId(token, masterIPs)
-		}
-
-		// worker ips
-		var workerHostIDs []string
-		if len(workerIPs) > 0 {
-			workerHostIDs = c.Resmgr.GetHostId(token, workerIPs)
-		}
-
-		// Attaching worker node(s) to cluster
-		if err := c.Segment.SendEvent("Starting Attach-node", auth, "", ""); err != nil {
-			zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
-		}
-		if len(workerHostIDs) > 0 {
-			fmt.Printf("Attaching node to the cluster %s\n", clusterName)
-			var wokerids []string
-			for _, worker := range workerHostIDs {
-				cname, err := c.Qbert.GetNodeInfo(token, projectId, worker)
-				if err != nil {
-					zap.S().Fatalf("Failed to get node info for host %s: %s", worker, err.Error())
-				} else if cname.ClusterName != "" {
-					zap.S().Infof("Node with host id %s is connected to %s cluster", worker, cname)
-				} else {
-					wokerids = append(wokerids, worker)
-				}
-			}
-			if len(wokerids) > 0 {
-				err1 := c.Qbert.AttachNode(clusterUuid, projectId, token, wokerids, "worker")
-
-				if err1 != nil {
-					if err := c.Segment.SendEvent("Attaching-node", auth, "Failed to attach worker node", ""); err != nil {
-						zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
-					}
-					zap.S().Fatal("Encountered an error while attaching worker node to a Kubernetes cluster : ", err1)
-				} else {
-					if err := c.Segment.SendEvent("Attaching-node", auth, "Worker node attached", ""); err != nil {
-						zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
-					}
-					zap.S().Infof("Worker node(s) %v attached to cluster", wokerids)
-				}
-			} else {
-				zap.S().Infof("No worker node available to attach to the cluster")
-			}
-
-		}
-		// Attaching master node(s) to cluster
-		if len(masterHostIDs) > 0 {
-			fmt.Printf("Attaching node to the cluster %s\n", clusterName)
-			var masterids []string
-			for _, master := range masterHostIDs {
-				cname, err := c.Qbert.GetNodeInfo(token, projectId, master)
-				if err != nil {
-					zap.S().Fatalf("Failed to get node info for host %s: %s", master, err.Error())
-				} else if cname.ClusterName != "" {
-					zap.S().Infof("Node with host id %s is connected to %s cluster", master, cname)
-				} else {
-					masterids = append(masterids, master)
-				}
-			}
-			if len(masterids) > 0 {
-				err1 := c.Qbert.AttachNode(clusterUuid, projectId, token, masterids, "master")
-
-				if err1 != nil {
-					if err := c.Segment.SendEvent("Attaching-node", auth, "Failed to attach master node", ""); err != nil {
-						zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
-					}
-					zap.S().Fatal("Encountered an error while attaching master node to a Kubernetes cluster : ", err1)
-				} else {
-					if err := c.Segment.SendEvent("Attaching-node", auth, "Master node attached", ""); err != nil {
-						zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
-					}
-					zap.S().Infof("Master node(s) %v attached to cluster", masterids)
-				}
-			} else {
-				zap.S().Infof("No master node available to attach to the cluster")
-			}
-
-		}
-	} else {
-		zap.S().Fatalf("Cluster is not ready. cluster status is %v", clusterStatus)
+	if err := pmk.AttachNodes(c, auth, clusterName, masterIPs, workerIPs); err != nil {
+		zap.S().Fatal(err.Error())
 	}
-
 }
@@ -62,6 +62,7 @@ func authNodeRun(cmd *cobra.Command, args []string) {
 	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, nc); err != nil {
 		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
 	}
+	defer executor.Close()
 
 	var c client.Client
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
@@ -77,10 +78,17 @@ func authNodeRun(cmd *cobra.Command, args []string) {
 	if ipAdd != "" {
 		nodeIPs = append(nodeIPs, ipAdd)
 	} else {
-		nodeIPs = append(nodeIPs, pmk.GetIp().String())
+		ip, err := pmk.GetIp()
+		if err != nil {
+			zap.S().Fatalf("Unable to find the IP of this node: %s", err.Error())
+		}
+		nodeIPs = append(nodeIPs, ip.String())
 	}
 	token := auth.Token
-	nodeUuids := c.Resmgr.GetHostId(token, nodeIPs)
+	nodeUuids, err := c.Resmgr.GetHostId(token, nodeIPs)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
 
 	if len(nodeUuids) == 0 {
 		zap.S().Fatalf("Could not find the node. Check if the node associated with this account")
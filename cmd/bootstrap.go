@@ -72,8 +72,10 @@ Optional Flags:
 	-e, --sudo-pass string                    Sudo password for user on remote host
 	    --tag string                          Add tag metadata to this cluster (key=value)
             --topology-manager-policy string      Topology manager policy (default "none")
+	    --timeout duration                    How long --wait waits for the cluster to be ready (default 30m0s)
 	    --use-hostname                        Use node hostname for cluster creation, use either --use-hostname or --use-hostname=true to change
 	-u, --user string                         Ssh username for the node
+	    --wait                                Wait for the cluster to be ready, fail if it goes in error or the timeout runs out
 
 
 Global Flags:
@@ -144,6 +146,9 @@ func init() {
 	bootstrapCmd.Flags().StringVar(&httpProxy, "http-proxy", "", "Specify the HTTP proxy for this cluster. Format-> <scheme>://<username>:<password>@<host>:<port>, username and password are optional.")
 	bootstrapCmd.Flags().IntVar(&intervalInMins, "interval-in-mins", 30, "time interval of etcd-backup in minutes(should be between 30 to 60)")
 	bootstrapCmd.Flags().StringVar(&backupPath, "etcd-backup-path", "/etc/pf9/etcd-backup", "Backup path for etcd")
+	bootstrapCmd.Flags().BoolVar(&bootstrapWait, "wait", false, "Wait for the cluster to be ready, fail if it goes in error or the timeout runs out")
+	bootstrapCmd.Flags().DurationVar(&bootstrapTimeout, "timeout", 30*time.Minute, "How long --wait waits for the cluster to be ready")
+	addSSHFlags(bootstrapCmd, &bootConfig)
 	bootstrapCmd.SetHelpTemplate(boostrapHelpTemplate)
 	rootCmd.AddCommand(bootstrapCmd)
 }
@@ -184,6 +189,11 @@ var (
 	backupPath               string   //etcd storage path
 )
 
+var (
+	bootstrapWait    bool          //if set then bootstrap waits for the cluster to be ready
+	bootstrapTimeout time.Duration //how long bootstrap waits for the cluster
+)
+
 func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 	zap.S().Debug("Received a call to bootstrap the node")
 
@@ -232,6 +242,7 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, bootConfig); err != nil {
 		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
 	}
+	defer executor.Close()
 
 	var c client.Client
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
@@ -241,7 +252,7 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 	defer c.Segment.Close()
 
 	if isRemote {
-		if err := SudoPasswordCheck(executor, detachedMode, bootConfig.SudoPassword); err != nil {
+		if err := SudoPasswordCheck(executor, detachedMode, bootConfig.IPs[0], bootConfig.SudoPassword); err != nil {
 			zap.S().Fatal("Failed executing commands on remote machine with sudo: ", err.Error())
 		}
 	}
@@ -260,7 +271,10 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 	}
 
 	//Getting all pmk versions
-	pmkRoles := c.Qbert.GetPMKVersions(auth.Token, auth.ProjectID)
+	pmkRoles, err := c.Qbert.GetPMKVersions(auth.Token, auth.ProjectID)
+	if err != nil {
+		zap.S().Fatalf("Unable to get the pmk versions: %s", err.Error())
+	}
 
 	qbert.IsPMKversionDefined = cmd.Flags().Changed("pmk-version")
 	if qbert.IsPMKversionDefined {
@@ -308,7 +322,7 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 
 	val, val1, err := pmk.PreReqBootstrap(executor)
 	if err != nil {
-		zap.S().Fatalf("Error running Prerequisite Checks for Bootstrap Command")
+		zap.S().Fatalf("Error running Prerequisite Checks for Bootstrap Command: %s", err.Error())
 	}
 	s.Stop()
 	if !val1 && !val { //Both node and cluster are already present
@@ -325,7 +339,7 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 	if !util.SkipPrepNode {
 		zap.S().Debug("========== Running check-node as a part of bootstrap ==========")
 
-		result, err := pmk.CheckNode(*cfg, c, auth, bootConfig)
+		result, _, err := pmk.CheckNode(*cfg, c, auth, bootConfig)
 		if err != nil {
 			zap.S().Fatalf("Unable to perform pre-requisite checks on this node: %s", err.Error())
 		}
@@ -422,9 +436,19 @@ func bootstrapCmdRun(cmd *cobra.Command, args []string) {
 		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
 	}
 
-	if err := pmk.Bootstrap(*cfg, c, payload, auth, bootConfig); err != nil {
+	clusterID, err := pmk.Bootstrap(*cfg, c, payload, auth, bootConfig)
+	if err != nil {
 		zap.S().Debugf("Unable to bootstrap node: %s\n", err.Error())
 		zap.S().Fatalf("Failed to bootstrap node. %s. See %s or use --verbose for logs\n", err.Error(), log.GetLogLocation(util.Pf9Log))
 	}
+
+	if bootstrapWait {
+		if err := pmk.WaitForCluster(c, clusterName, clusterID, auth, bootstrapTimeout); err != nil {
+			zap.S().Fatalf("%s. See %s or use --verbose for logs\n", err.Error(), log.GetLogLocation(util.Pf9Log))
+		}
+	} else {
+		zap.S().Debug("Cluster creation started....This may take a few minutes....Check the latest status in UI")
+		fmt.Println("Cluster creation started....This may take a few minutes....Check the latest status in UI")
+	}
 	zap.S().Debug("==========Finished running bootstrap==========")
 }
@@ -4,7 +4,9 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/platform9/pf9ctl/pkg/client"
@@ -27,7 +29,8 @@ var (
 		Short: "Checks prerequisites on a node to use with PMK",
 		Long: `Check if a node satisfies prerequisites to be ready to be added to a Kubernetes cluster. Read more
 	at https://platform9.com/blog/support/managed-container-cloud-requirements-checklist/`,
-		Run: checkNodeRun,
+		Run:         checkNodeRun,
+		Annotations: map[string]string{"dry-run": "true", "output": "true"},
 	}
 )
 
@@ -40,6 +43,9 @@ func init() {
 	checkNodeCmd.Flags().StringVar(&nc.MFA, "mfa", "", "MFA token")
 	checkNodeCmd.Flags().StringVarP(&nc.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
 	checkNodeCmd.Flags().BoolVarP(&nc.RemoveExistingPkgs, "remove-existing-pkgs", "r", false, "Will remove previous installation if found (default false)")
+	checkNodeCmd.Flags().IntVar(&parallelism, "parallelism", defaultParallelism, "Number of hosts checked at the same time when more than one IP is passed")
+	addSSHFlags(checkNodeCmd, &nc)
+	addInventoryFlags(checkNodeCmd)
 
 	//checkNodeCmd.Flags().BoolVarP(&floatingIP, "floating-ip", "f", false, "") //Unsupported in first version.
 
@@ -48,11 +54,21 @@ func init() {
 
 func checkNodeRun(cmd *cobra.Command, args []string) {
 	zap.S().Debug("==========Running check-node==========")
+	progress := progressOut()
+
+	if _, err := loadInventory(&nc); err != nil {
+		zap.S().Fatalf("Unable to load the inventory: %s", err.Error())
+	}
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
 	isRemote := cmdexec.CheckRemote(nc)
+	multiHost := len(nc.IPs) > 1
 
-	if isRemote {
+	if multiHost && !detachedMode {
+		zap.S().Fatal(errMultiHostPrompt.Error())
+	}
+
+	if isRemote && inventoryHosts == nil {
 		if !config.ValidateNodeConfig(&nc, !detachedMode) {
 			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
 		}
@@ -62,25 +78,27 @@ func checkNodeRun(cmd *cobra.Command, args []string) {
 	var err error
 	if detachedMode {
 		nc.RemoveExistingPkgs = true
-		err = config.LoadConfig(util.Pf9DBLoc, cfg, nc)
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, configNodeConfig(nc))
 	} else {
-		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, nc)
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, configNodeConfig(nc))
 	}
 	if err != nil {
 		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
 	}
 
-	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
+	fmt.Fprintln(progress, color.Green("✓ ")+"Loaded Config Successfully")
 	zap.S().Debug("Loaded Config Successfully")
 	var executor cmdexec.Executor
-	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, nc); err != nil {
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, configNodeConfig(nc)); err != nil {
 		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
 	}
+	defer executor.Close()
 
 	var c client.Client
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
 		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
 	}
+	c.Out = progress
 
 	defer c.Segment.Close()
 
@@ -104,13 +122,55 @@ func checkNodeRun(cmd *cobra.Command, args []string) {
 		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
 	}
 
+	if multiHost {
+		var mu sync.Mutex
+		reports := map[string]pmk.CheckReport{}
+		results := pmk.RunOnHosts(progress, nc.IPs, parallelism, func(host string, out io.Writer) (string, error) {
+			hostNc := hostNodeConfig(nc, host)
+			c, err := newHostClient(cfg, hostNc, host, out)
+			if err != nil {
+				return "", err
+			}
+			defer c.Segment.Close()
+			defer c.Executor.Close()
+			result, checks, err := checkHostNode(cfg, hostNc, auth, c)
+
+			mu.Lock()
+			reports[host] = pmk.NewCheckReport(host, result, checks, err)
+			mu.Unlock()
+			return string(result), err
+		})
+		if outputFormat != pmk.OutputText {
+			var ordered []pmk.CheckReport
+			for i, host := range nc.IPs {
+				report, ok := reports[host]
+				if !ok {
+					// The host could not be reached
+					report = pmk.NewCheckReport(host, pmk.RequiredFail, nil, results[i].Err)
+				}
+				ordered = append(ordered, report)
+			}
+			writeCheckReports(ordered)
+		}
+		printHostResults(progress, results)
+		zap.S().Debug("==========Finished running check-node==========")
+		return
+	}
+
 	if isRemote {
-		if err := SudoPasswordCheck(executor, detachedMode, nc.SudoPassword); err != nil {
+		if err := SudoPasswordCheck(executor, detachedMode, nc.IPs[0], nc.SudoPassword); err != nil {
 			zap.S().Fatal("Failed executing commands on remote machine with sudo: ", err.Error())
 		}
 	}
 
-	result, err := pmk.CheckNode(*cfg, c, auth, nc)
+	result, checks, err := pmk.CheckNode(*cfg, c, auth, nc)
+	if outputFormat != pmk.OutputText {
+		host := "localhost"
+		if isRemote {
+			host = nc.IPs[0]
+		}
+		writeCheckReports([]pmk.CheckReport{pmk.NewCheckReport(host, result, checks, err)})
+	}
 	if err != nil {
 		zap.S().Fatalf("Unable to perform pre-requisite checks on this node: %s", err.Error())
 	}
@@ -119,9 +179,16 @@ func checkNodeRun(cmd *cobra.Command, args []string) {
 		zap.S().Fatalf(color.Red("x ")+"Required pre-requisite check(s) failed. See %s or use --verbose for logs \n", log.GetLogLocation(util.Pf9Log))
 		//this is so the exit flag is set to 1
 	} else if result == pmk.OptionalFail {
-		fmt.Printf("\nOptional pre-requisite check(s) failed. See %s or use --verbose for logs \n", log.GetLogLocation(util.Pf9Log))
+		fmt.Fprintf(progress, "\nOptional pre-requisite check(s) failed. See %s or use --verbose for logs \n", log.GetLogLocation(util.Pf9Log))
 	} else if result == pmk.CleanInstallFail {
-		fmt.Println("\nPrevious Installation Removed")
+		fmt.Fprintln(progress, "\nPrevious Installation Removed")
 	}
 	zap.S().Debug("==========Finished running check-node==========")
 }
+
+// writeCheckReports prints the check results in the format selected with --output
+func writeCheckReports(reports []pmk.CheckReport) {
+	if err := pmk.WriteCheckReports(reportOut, outputFormat, reports); err != nil {
+		zap.S().Fatalf("Unable to write the check results: %s", err.Error())
+	}
+}
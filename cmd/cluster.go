@@ -3,46 +3,200 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
+// clusterCmd groups the commands managing an existing cluster
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Manage an existing Kubernetes cluster",
+	Long:  "Manage the nodes and the lifecycle of an existing Kubernetes cluster",
+}
+
 // clusterCmdGet represents the cluster get command
 var clusterCmdGet = &cobra.Command{
-	Use:   "cluster",
+	Use:   "cluster [name]",
 	Short: "Display one or many clusters",
 	Long: `Query your controller using the current config and list
 	 the clusters`,
-	Run: func(cmd *cobra.Command, args []string) {
-		zap.S().Info("Get cluster called")
+	Args: func(clusterCmdGet *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("Only cluster name is accepted as a parameter")
+		}
+		return nil
 	},
+	Example: "pf9ctl get cluster <clusterName>",
+	Run:     clusterCmdGetRun,
 }
 
 // clusterCmdCreate represents Create cluster command
 var clusterCmdCreate = &cobra.Command{
 	Use:   "cluster",
 	Short: "Create a kubernetes cluster",
-	Long:  `Create a cluster and add one or more nodes to it.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		zap.S().Info("Create cluster called")
+	Long: `Create a cluster and add one or more nodes to it. The cluster is described
+	in a YAML or JSON file using the same keys as the Qbert cluster API.`,
+	Args: func(clusterCmdCreate *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.New("please pass the cluster spec using the --file flag")
+		}
+		return nil
 	},
+	Example: "pf9ctl create cluster -f cluster.yaml",
+	Run:     clusterCmdCreateRun,
 }
 
-/*
-// This initialization is using create and get commands which are not in use for now.
+var (
+	clusterSpecFile  string
+	validateSpecOnly bool
+)
+
 func init() {
+	rootCmd.AddCommand(clusterCmd)
+
+	clusterCmdGet.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+	getCmd.AddCommand(clusterCmdGet)
+
+	clusterCmdCreate.Flags().StringVarP(&clusterSpecFile, "file", "f", "", "YAML or JSON file describing the cluster")
+	clusterCmdCreate.Flags().BoolVar(&validateSpecOnly, "validate", false, "Only validate the cluster spec, do not create the cluster")
+	clusterCmdCreate.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+	clusterCmdCreate.MarkFlagRequired("file")
+	createCmd.AddCommand(clusterCmdCreate)
+}
+
+func clusterCmdCreateRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running create cluster==========")
+
+	spec, err := pmk.LoadClusterSpec(clusterSpecFile)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	if err = spec.Validate(); err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + "Cluster spec is valid")
+	if validateSpecOnly {
+		return
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: attachconfig.MFA}
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
 
-	//createCmd.AddCommand(clusterCmdCreate)
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	defer executor.Close()
 
-	// Here you will define your flags and configuration settings.
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// clusterCmd.PersistentFlags().String("foo", "", "A help for foo")
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Failed to get keystone %s", err.Error())
+	}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// clusterCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	if err := pmk.CreateClusterFromSpec(c, spec, auth); err != nil {
+		zap.S().Fatalf("Failed to create cluster. %s. See %s or use --verbose for logs\n", err.Error(), log.GetLogLocation(util.Pf9Log))
+	}
+	zap.S().Debug("==========Finished running create cluster==========")
+}
+
+func clusterCmdGetRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running get cluster==========")
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: attachconfig.MFA}
+	var err error
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	defer executor.Close()
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Failed to get keystone %s", err.Error())
+	}
 
-	//getCmd.AddCommand(clusterCmdGet)
-}*/
+	clusters, err := c.Qbert.ListClusters(auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to list clusters: %s", err.Error())
+	}
+
+	if len(args) == 1 {
+		clusters = filterClustersByName(clusters, args[0])
+		if len(clusters) == 0 {
+			zap.S().Fatalf("Cluster %s not found", args[0])
+		}
+	}
+
+	printClusters(clusters)
+	zap.S().Debug("==========Finished running get cluster==========")
+}
+
+// returns the clusters having the given name
+func filterClustersByName(clusters []qbert.Cluster, name string) []qbert.Cluster {
+	var filtered []qbert.Cluster
+	for _, cluster := range clusters {
+		if cluster.Name == name {
+			filtered = append(filtered, cluster)
+		}
+	}
+	return filtered
+}
+
+func printClusters(clusters []qbert.Cluster) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tUUID\tSTATUS\tVERSION\tMASTERS\tWORKERS\tCNI")
+	for _, cluster := range clusters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\n",
+			cluster.Name, cluster.Uuid, cluster.Status, cluster.KubeRoleVersion,
+			cluster.NumMasters, cluster.NumWorkers, cluster.NetworkPlugin)
+	}
+	w.Flush()
+}
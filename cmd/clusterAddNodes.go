@@ -0,0 +1,246 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// clusterAddNodesCmd prepares nodes and attaches them to a cluster
+var clusterAddNodesCmd = &cobra.Command{
+	Use:   "add-nodes [flags] cluster-name",
+	Short: "Prepares nodes and attaches them to a cluster",
+	Long: `Add nodes to an existing cluster in one step. The nodes which are not
+	connected to the PMK control plane yet are checked and prepared, pf9ctl then
+	waits for them to respond and attaches them with their role.`,
+	Args: func(clusterAddNodesCmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("cluster name is required for add-nodes")
+		}
+		return nil
+	},
+	Example: "pf9ctl cluster add-nodes <clusterName> --worker-ip 10.0.0.2,10.0.0.3 -u ubuntu -s ~/.ssh/id_rsa --no-prompt",
+	Run:     clusterAddNodesRun,
+}
+
+var (
+	addNodesConfig  objects.NodeConfig
+	addMasterIPs    []string
+	addWorkerIPs    []string
+	addNodesTimeout time.Duration
+)
+
+func init() {
+	clusterAddNodesCmd.Flags().StringSliceVarP(&addMasterIPs, "master-ip", "m", []string{}, "IP address of the master nodes to add")
+	clusterAddNodesCmd.Flags().StringSliceVarP(&addWorkerIPs, "worker-ip", "w", []string{}, "IP address of the worker nodes to add")
+	clusterAddNodesCmd.Flags().StringVarP(&addNodesConfig.User, "user", "u", "", "ssh username for the nodes")
+	clusterAddNodesCmd.Flags().StringVarP(&addNodesConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	clusterAddNodesCmd.Flags().StringVarP(&addNodesConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	clusterAddNodesCmd.Flags().StringVarP(&addNodesConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	clusterAddNodesCmd.Flags().StringVar(&addNodesConfig.MFA, "mfa", "", "MFA token")
+	clusterAddNodesCmd.Flags().BoolVarP(&skipChecks, "skip-checks", "c", false, "Will skip optional checks if true")
+	clusterAddNodesCmd.Flags().IntVar(&parallelism, "parallelism", defaultParallelism, "Number of nodes prepared at the same time")
+	clusterAddNodesCmd.Flags().DurationVar(&addNodesTimeout, "timeout", 10*time.Minute, "How long to wait for each node to respond after it is prepared")
+	addSSHFlags(clusterAddNodesCmd, &addNodesConfig)
+	clusterCmd.AddCommand(clusterAddNodesCmd)
+}
+
+func clusterAddNodesRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running cluster add-nodes==========")
+	name := args[0]
+
+	if skipChecks {
+		pmk.WarningOptionalChecks = true
+	}
+
+	hosts, roles, err := addNodesRoles(addMasterIPs, addWorkerIPs)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	addNodesConfig.IPs = hosts
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if len(hosts) > 1 && !detachedMode {
+		zap.S().Fatal(errMultiHostPrompt.Error())
+	}
+	if cmdexec.CheckRemote(addNodesConfig) {
+		if !config.ValidateNodeConfig(&addNodesConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: addNodesConfig.MFA}
+	if detachedMode {
+		addNodesConfig.RemoveExistingPkgs = true
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, configNodeConfig(addNodesConfig))
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, configNodeConfig(addNodesConfig))
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
+	zap.S().Debug("Loaded Config Successfully")
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	defer executor.Close()
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Failed to get keystone %s", err.Error())
+	}
+
+	exists, clusterID, clusterStatus, err := c.Qbert.CheckClusterExists(name, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to check the cluster %s: %s", name, err.Error())
+	} else if !exists {
+		zap.S().Fatalf("Cluster %s not found", name)
+	} else if clusterStatus != "ok" {
+		zap.S().Fatalf("Cluster is not ready. cluster status is %v", clusterStatus)
+	}
+
+	if err := c.Segment.SendEvent("Starting add-nodes", auth, "", ""); err != nil {
+		zap.S().Debugf("Unable to send Segment event for add-nodes. Error: %s", err.Error())
+	}
+
+	// IDs of the nodes ready to be attached, by IP
+	var mu sync.Mutex
+	hostIDs := map[string]string{}
+	results := pmk.RunOnHosts(os.Stdout, hosts, parallelism, func(host string, out io.Writer) (string, error) {
+		hostNc := hostNodeConfig(addNodesConfig, host)
+		c, err := newHostClient(cfg, hostNc, host, out)
+		if err != nil {
+			return "", err
+		}
+		defer c.Segment.Close()
+		defer c.Executor.Close()
+		result, err := prepareNodeForCluster(cfg, hostNc, auth, c)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintln(out, "Waiting for the node to respond")
+		hostID, err := pmk.WaitForHost(c, host, auth, addNodesTimeout)
+		if err != nil {
+			return "", err
+		}
+		node, err := c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, hostID)
+		if err != nil {
+			return "", fmt.Errorf("Failed to get node info for host %s: %w", hostID, err)
+		}
+		if node.ClusterUuid == clusterID {
+			return "already in the cluster", nil
+		} else if node.ClusterUuid != "" {
+			return "", fmt.Errorf("Node is attached to the cluster %s", node.ClusterName)
+		}
+		mu.Lock()
+		hostIDs[host] = hostID
+		mu.Unlock()
+		return result, nil
+	})
+	fmt.Println()
+	pmk.PrintHostResults(os.Stdout, results)
+	fmt.Println()
+
+	// Masters are attached one at a time, before the workers
+	failed := pmk.HostsFailed(results)
+	for _, role := range []string{"master", "worker"} {
+		for _, host := range hosts {
+			hostID, ok := hostIDs[host]
+			if !ok || roles[host] != role {
+				continue
+			}
+			fmt.Printf("Attaching %s to the cluster %s as %s\n", host, name, role)
+			if err := c.Qbert.AttachNode(clusterID, auth.ProjectID, auth.Token, []string{hostID}, role); err != nil {
+				if err := c.Segment.SendEvent("Adding-nodes", auth, "Failed to attach "+role+" node", ""); err != nil {
+					zap.S().Debugf("Unable to send Segment event for add-nodes. Error: %s", err.Error())
+				}
+				fmt.Println(color.Red("x ") + "Failed to attach " + host + ": " + err.Error())
+				failed++
+				continue
+			}
+			fmt.Println(color.Green("✓ ") + "Attached " + host + " as " + role)
+		}
+	}
+
+	if failed > 0 {
+		zap.S().Fatalf("Failed to add %d of %d node(s) to the cluster %s", failed, len(hosts), name)
+	}
+	if err := c.Segment.SendEvent("Adding-nodes", auth, "Nodes added", ""); err != nil {
+		zap.S().Debugf("Unable to send Segment event for add-nodes. Error: %s", err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + fmt.Sprintf("Added %d node(s) to the cluster %s", len(hosts), name))
+	zap.S().Debug("==========Finished running cluster add-nodes==========")
+}
+
+// addNodesRoles returns the IPs of the nodes to add, masters first, with the
+// role of each node
+func addNodesRoles(masters, workers []string) ([]string, map[string]string, error) {
+	var hosts []string
+	roles := map[string]string{}
+	for _, ip := range masters {
+		if _, ok := roles[ip]; !ok {
+			hosts = append(hosts, ip)
+		}
+		roles[ip] = "master"
+	}
+	for _, ip := range workers {
+		if roles[ip] == "master" {
+			return nil, nil, fmt.Errorf("%s can not be both a master and a worker", ip)
+		} else if _, ok := roles[ip]; !ok {
+			hosts = append(hosts, ip)
+		}
+		roles[ip] = "worker"
+	}
+	if len(hosts) == 0 {
+		return nil, nil, errors.New("No nodes were specified, use --master-ip or --worker-ip")
+	}
+	return hosts, roles, nil
+}
+
+// prepareNodeForCluster runs check-node and prep-node on a node which is not
+// connected to the PMK control plane yet
+func prepareNodeForCluster(cfg *objects.Config, nc objects.NodeConfig, auth keystone.KeystoneAuth, c client.Client) (string, error) {
+	if _, err := pmk.WaitForHost(c, nc.IPs[0], auth, 0); err == nil {
+		return "already connected", nil
+	}
+	result, _, err := checkHostNode(cfg, nc, auth, c)
+	if err != nil {
+		return string(result), err
+	}
+	if result == pmk.AlreadyConnected {
+		return "already connected", nil
+	}
+	if result == pmk.OptionalFail && !skipChecks {
+		return string(result), errors.New("Optional pre-requisite check(s) failed. Use --skip-checks to skip these checks")
+	}
+	if err := pmk.PrepNode(*cfg, c, auth); err != nil {
+		return "", fmt.Errorf("Failed to prepare node. %w", err)
+	}
+	return "prepared", nil
+}
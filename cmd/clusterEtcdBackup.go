@@ -0,0 +1,290 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// clusterEtcdBackupCmd groups the commands managing the etcd backups of a cluster
+var clusterEtcdBackupCmd = &cobra.Command{
+	Use:   "etcd-backup",
+	Short: "Manage the etcd backups of a cluster",
+	Long: `Show and change the etcd backup settings of an existing cluster, list the
+	backups stored on a master and take a backup on demand`,
+}
+
+var (
+	etcdBackupShowCmd = &cobra.Command{
+		Use:     "show [flags] cluster-name",
+		Short:   "Shows the etcd backup settings of a cluster",
+		Args:    clusterNameArg,
+		Example: "pf9ctl cluster etcd-backup show <clusterName>",
+		Run:     etcdBackupShowRun,
+	}
+	etcdBackupEnableCmd = &cobra.Command{
+		Use:     "enable [flags] cluster-name",
+		Short:   "Enables the etcd backups of a cluster",
+		Args:    clusterNameArg,
+		Example: "pf9ctl cluster etcd-backup enable <clusterName> --interval-in-mins 60",
+		Run:     etcdBackupEnableRun,
+	}
+	etcdBackupDisableCmd = &cobra.Command{
+		Use:     "disable [flags] cluster-name",
+		Short:   "Disables the etcd backups of a cluster",
+		Args:    clusterNameArg,
+		Example: "pf9ctl cluster etcd-backup disable <clusterName>",
+		Run:     etcdBackupDisableRun,
+	}
+	etcdBackupUpdateCmd = &cobra.Command{
+		Use:     "update [flags] cluster-name",
+		Short:   "Changes the etcd backup settings of a cluster",
+		Long:    "Change the etcd backup settings of a cluster, the settings not passed are kept",
+		Args:    clusterNameArg,
+		Example: "pf9ctl cluster etcd-backup update <clusterName> --max-backups 5",
+		Run:     etcdBackupUpdateRun,
+	}
+	etcdBackupListCmd = &cobra.Command{
+		Use:     "list [flags] cluster-name",
+		Short:   "Lists the etcd backups stored on a master of a cluster",
+		Args:    clusterNameArg,
+		Example: "pf9ctl cluster etcd-backup list <clusterName> -u ubuntu -s ~/.ssh/id_rsa",
+		Run:     etcdBackupListRun,
+	}
+	etcdBackupNowCmd = &cobra.Command{
+		Use:     "now [flags] cluster-name",
+		Short:   "Takes an etcd backup of a cluster on a master",
+		Args:    clusterNameArg,
+		Example: "pf9ctl cluster etcd-backup now <clusterName> -u ubuntu -s ~/.ssh/id_rsa",
+		Run:     etcdBackupNowRun,
+	}
+)
+
+var (
+	etcdBackupConfig     objects.NodeConfig
+	etcdBackupPath       string
+	etcdBackupInterval   int
+	etcdBackupMaxBackups int
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{etcdBackupShowCmd, etcdBackupEnableCmd, etcdBackupDisableCmd, etcdBackupUpdateCmd, etcdBackupListCmd, etcdBackupNowCmd} {
+		cmd.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+		clusterEtcdBackupCmd.AddCommand(cmd)
+	}
+	for _, cmd := range []*cobra.Command{etcdBackupEnableCmd, etcdBackupUpdateCmd} {
+		cmd.Flags().StringVar(&etcdBackupPath, "etcd-backup-path", pmk.DefaultEtcdBackupPath, "Backup path for etcd on the masters")
+		cmd.Flags().IntVar(&etcdBackupInterval, "interval-in-mins", pmk.DefaultEtcdBackupInterval, "time interval of etcd-backup in minutes(should be between 30 to 60)")
+		cmd.Flags().IntVar(&etcdBackupMaxBackups, "max-backups", pmk.DefaultEtcdBackupMaxBackups, "Number of backups kept on the masters")
+	}
+	for _, cmd := range []*cobra.Command{etcdBackupListCmd, etcdBackupNowCmd} {
+		cmd.Flags().StringSliceVarP(&etcdBackupConfig.IPs, "ip", "i", []string{}, "IP address of the master, localhost for this node (default the first master of the cluster)")
+		cmd.Flags().StringVarP(&etcdBackupConfig.User, "user", "u", "", "ssh username for the master")
+		cmd.Flags().StringVarP(&etcdBackupConfig.Password, "password", "p", "", "ssh password for the master (use 'single quotes' to pass password)")
+		cmd.Flags().StringVarP(&etcdBackupConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the master")
+		cmd.Flags().StringVarP(&etcdBackupConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on the master")
+		addSSHFlags(cmd, &etcdBackupConfig)
+	}
+	clusterCmd.AddCommand(clusterEtcdBackupCmd)
+}
+
+// clusterNameArg accepts the name of a cluster as the only argument
+func clusterNameArg(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("only cluster name is accepted as a parameter")
+	}
+	return nil
+}
+
+// loadEtcdBackupCluster loads the context and returns the cluster with the name
+func loadEtcdBackupCluster(cmd *cobra.Command, name string) (*objects.Config, client.Client, keystone.KeystoneAuth, qbert.Cluster) {
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: attachconfig.MFA}
+	var err error
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Failed to get keystone %s", err.Error())
+	}
+
+	clusters, err := c.Qbert.ListClusters(auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to list clusters: %s", err.Error())
+	}
+	clusters = filterClustersByName(clusters, name)
+	if len(clusters) == 0 {
+		zap.S().Fatalf("Cluster %s not found", name)
+	}
+	return cfg, c, auth, clusters[0]
+}
+
+func etcdBackupShowRun(cmd *cobra.Command, args []string) {
+	_, c, _, cluster := loadEtcdBackupCluster(cmd, args[0])
+	defer c.Segment.Close()
+	defer c.Executor.Close()
+	pmk.PrintEtcdBackup(os.Stdout, cluster)
+}
+
+func etcdBackupEnableRun(cmd *cobra.Command, args []string) {
+	backup, err := pmk.EtcdBackupSettings(etcdBackupPath, etcdBackupInterval, etcdBackupMaxBackups)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	updateEtcdBackup(cmd, args[0], backup)
+}
+
+func etcdBackupDisableRun(cmd *cobra.Command, args []string) {
+	updateEtcdBackup(cmd, args[0], qbert.EtcdBackup{})
+}
+
+func etcdBackupUpdateRun(cmd *cobra.Command, args []string) {
+	_, c, auth, cluster := loadEtcdBackupCluster(cmd, args[0])
+	defer c.Segment.Close()
+	defer c.Executor.Close()
+	current := cluster.EtcdBackup
+	if current.IsEtcdBackupEnabled != 1 {
+		zap.S().Fatalf("Etcd backup of cluster %s is disabled, use etcd-backup enable", cluster.Name)
+	}
+
+	dir, interval, maxBackups := current.StorageProperties.LocalPath, current.IntervalInMins, current.MaxIntervalBackupCount
+	if cmd.Flags().Changed("etcd-backup-path") {
+		dir = etcdBackupPath
+	}
+	if cmd.Flags().Changed("interval-in-mins") {
+		interval = etcdBackupInterval
+	}
+	if cmd.Flags().Changed("max-backups") {
+		maxBackups = etcdBackupMaxBackups
+	}
+	backup, err := pmk.EtcdBackupSettings(dir, interval, maxBackups)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	saveEtcdBackup(c, auth, cluster, backup)
+}
+
+// updateEtcdBackup replaces the etcd backup settings of the cluster with the name
+func updateEtcdBackup(cmd *cobra.Command, name string, backup qbert.EtcdBackup) {
+	_, c, auth, cluster := loadEtcdBackupCluster(cmd, name)
+	defer c.Segment.Close()
+	defer c.Executor.Close()
+	saveEtcdBackup(c, auth, cluster, backup)
+}
+
+// saveEtcdBackup replaces the etcd backup settings of the cluster and prints them
+func saveEtcdBackup(c client.Client, auth keystone.KeystoneAuth, cluster qbert.Cluster, backup qbert.EtcdBackup) {
+	if err := c.Qbert.UpdateEtcdBackup(cluster.Uuid, auth.ProjectID, auth.Token, backup); err != nil {
+		zap.S().Fatalf("Unable to update the etcd backup of cluster %s: %s", cluster.Name, err.Error())
+	}
+	cluster.EtcdBackup = backup
+	fmt.Println(color.Green("✓ ") + "Updated the etcd backup of cluster " + cluster.Name)
+	pmk.PrintEtcdBackup(os.Stdout, cluster)
+}
+
+// etcdBackupMaster returns an executor for the master passed with --ip or the
+// first master of the cluster
+func etcdBackupMaster(cmd *cobra.Command, cfg *objects.Config, c client.Client, auth keystone.KeystoneAuth, cluster qbert.Cluster) cmdexec.Executor {
+	if len(etcdBackupConfig.IPs) > 1 {
+		zap.S().Fatal("Only one master can be passed with --ip")
+	}
+	if len(etcdBackupConfig.IPs) == 0 {
+		nodes, err := pmk.ListNodes(c, auth, pmk.NodeFilter{Cluster: cluster.Uuid})
+		if err != nil {
+			zap.S().Fatalf(err.Error())
+		}
+		for _, n := range nodes {
+			if n.ClusterRole == pmk.NodeRoleMaster {
+				etcdBackupConfig.IPs = []string{n.PrimaryIP}
+				break
+			}
+		}
+		if len(etcdBackupConfig.IPs) == 0 {
+			zap.S().Fatalf("Cluster %s has no master", cluster.Name)
+		}
+		zap.S().Debugf("Using the master %s", etcdBackupConfig.IPs[0])
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if cmdexec.CheckRemote(etcdBackupConfig) {
+		if !config.ValidateNodeConfig(&etcdBackupConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, etcdBackupConfig)
+	if err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	if cmdexec.CheckRemote(etcdBackupConfig) {
+		if err := SudoPasswordCheck(executor, detachedMode, etcdBackupConfig.IPs[0], etcdBackupConfig.SudoPassword); err != nil {
+			zap.S().Fatal("Failed executing commands on remote machine with sudo: ", err.Error())
+		}
+	}
+	return executor
+}
+
+func etcdBackupListRun(cmd *cobra.Command, args []string) {
+	cfg, c, auth, cluster := loadEtcdBackupCluster(cmd, args[0])
+	defer c.Segment.Close()
+	defer c.Executor.Close()
+	executor := etcdBackupMaster(cmd, cfg, c, auth, cluster)
+	defer executor.Close()
+
+	dir := pmk.EtcdBackupPath(cluster)
+	files, err := pmk.ListEtcdBackups(executor, dir)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	if len(files) == 0 {
+		fmt.Printf("No etcd backup in %s on %s\n", dir, etcdBackupConfig.IPs[0])
+		return
+	}
+	pmk.PrintEtcdBackups(os.Stdout, files)
+}
+
+func etcdBackupNowRun(cmd *cobra.Command, args []string) {
+	cfg, c, auth, cluster := loadEtcdBackupCluster(cmd, args[0])
+	defer c.Segment.Close()
+	defer c.Executor.Close()
+	executor := etcdBackupMaster(cmd, cfg, c, auth, cluster)
+	defer executor.Close()
+
+	snapshot, err := pmk.EtcdSnapshot(executor, pmk.EtcdBackupPath(cluster))
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + "Etcd backup saved to " + snapshot + " on " + etcdBackupConfig.IPs[0])
+}
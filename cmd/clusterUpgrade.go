@@ -0,0 +1,134 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// clusterUpgradeCmd upgrades the Kubernetes version of a cluster
+var clusterUpgradeCmd = &cobra.Command{
+	Use:   "upgrade [flags] cluster-name",
+	Short: "Upgrades the Kubernetes version of a cluster",
+	Long: `Show the current and the available versions of a cluster and upgrade it.
+	The newest version the cluster can be upgraded to is used unless --to is passed,
+	pf9ctl then waits for all the nodes to run the new version.`,
+	Args: func(clusterUpgradeCmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("cluster name is required for upgrade")
+		}
+		return nil
+	},
+	Example: "pf9ctl cluster upgrade <clusterName> --to 1.29.2-pmk.1",
+	Run:     clusterUpgradeRun,
+}
+
+var (
+	upgradeTo      string
+	upgradePlan    bool
+	upgradeTimeout time.Duration
+)
+
+func init() {
+	clusterUpgradeCmd.Flags().StringVar(&upgradeTo, "to", "", "version to upgrade to (default the newest version allowed)")
+	clusterUpgradeCmd.Flags().BoolVar(&upgradePlan, "plan", false, "only print what the upgrade would change")
+	clusterUpgradeCmd.Flags().DurationVar(&upgradeTimeout, "timeout", time.Hour, "How long to wait for all the nodes to be upgraded")
+	clusterUpgradeCmd.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+	clusterCmd.AddCommand(clusterUpgradeCmd)
+}
+
+func clusterUpgradeRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running cluster upgrade==========")
+	name := args[0]
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: attachconfig.MFA}
+	var err error
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	defer executor.Close()
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Failed to get keystone %s", err.Error())
+	}
+
+	clusters, err := c.Qbert.ListClusters(auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to list clusters: %s", err.Error())
+	}
+	clusters = filterClustersByName(clusters, name)
+	if len(clusters) == 0 {
+		zap.S().Fatalf("Cluster %s not found", name)
+	}
+	cluster := clusters[0]
+
+	plan, err := pmk.PlanUpgrade(c, auth, cluster, upgradeTo)
+	pmk.PrintUpgradePlan(os.Stdout, plan)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	if plan.Target == "" {
+		fmt.Println(color.Green("✓ ") + "Cluster " + name + " is up to date")
+		return
+	}
+	if upgradePlan {
+		return
+	}
+
+	if !detachedMode {
+		fmt.Println()
+		ok, err := util.AskBool("Upgrade cluster %s to %s", name, plan.Target)
+		if err != nil {
+			zap.S().Fatalf(err.Error())
+		} else if !ok {
+			return
+		}
+	}
+
+	if err := c.Segment.SendEvent("Starting cluster upgrade", auth, "", ""); err != nil {
+		zap.S().Debugf("Unable to send Segment event for cluster upgrade. Error: %s", err.Error())
+	}
+	if err := pmk.UpgradeCluster(c, auth, plan, upgradeTimeout); err != nil {
+		if err := c.Segment.SendEvent("Upgrading cluster", auth, "Failed to upgrade cluster", ""); err != nil {
+			zap.S().Debugf("Unable to send Segment event for cluster upgrade. Error: %s", err.Error())
+		}
+		zap.S().Fatalf("%s. See %s or use --verbose for logs\n", err.Error(), log.GetLogLocation(util.Pf9Log))
+	}
+	if err := c.Segment.SendEvent("Upgrading cluster", auth, "Cluster upgraded", ""); err != nil {
+		zap.S().Debugf("Unable to send Segment event for cluster upgrade. Error: %s", err.Error())
+	}
+	zap.S().Debug("==========Finished running cluster upgrade==========")
+}
@@ -3,9 +3,11 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"text/tabwriter"
 
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/config"
@@ -24,10 +26,11 @@ var (
 	}
 
 	configCmdSet = &cobra.Command{
-		Use:   "set",
-		Short: "Create a new config",
-		Long:  `Create a new config that can be used to query Platform9 controller`,
-		Run:   configCmdCreateRun,
+		Use:         "set",
+		Short:       "Create a new config",
+		Long:        `Create a new config that can be used to query Platform9 controller`,
+		Run:         configCmdCreateRun,
+		Annotations: contextFallback,
 	}
 
 	configCmdGet = &cobra.Command{
@@ -61,6 +64,14 @@ var (
 		},
 	}
 
+	configCmdGetContexts = &cobra.Command{
+		Use:         "get-contexts",
+		Short:       "List the stored contexts",
+		Long:        `List the stored config contexts, the context in use is marked with *`,
+		Run:         configCmdGetContextsRun,
+		Annotations: contextFallback,
+	}
+
 	cfg objects.Config
 )
 
@@ -68,6 +79,7 @@ func init() {
 	rootCmd.AddCommand(configCmdCreate)
 	configCmdCreate.AddCommand(configCmdGet)
 	configCmdCreate.AddCommand(configCmdSet)
+	configCmdCreate.AddCommand(configCmdGetContexts)
 
 	configCmdSet.Flags().StringVarP(&cfg.Fqdn, "account-url", "u", "", "sets account-url")
 	configCmdSet.Flags().StringVarP(&cfg.Username, "username", "e", "", "sets username")
@@ -76,6 +88,7 @@ func init() {
 	configCmdSet.Flags().StringVarP(&cfg.Region, "region", "r", "", "sets region")
 	configCmdSet.Flags().StringVarP(&cfg.Tenant, "tenant", "t", "", "sets tenant")
 	configCmdSet.Flags().StringVar(&cfg.MfaToken, "mfa", "", "set MFA token")
+	configCmdSet.Flags().StringVar(&config.SecretBackend, "secret-backend", "", "where to store the password, keyring or file (default keyring when available)")
 }
 
 func configCmdCreateRun(cmd *cobra.Command, args []string) {
@@ -103,3 +116,41 @@ func configCmdCreateRun(cmd *cobra.Command, args []string) {
 
 	zap.S().Debug("==========Finished running set config==========")
 }
+
+func configCmdGetContextsRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running get-contexts config==========")
+
+	current := contextName
+	if current == "" {
+		var err error
+		if current, err = config.CurrentContext(); err != nil {
+			// The contexts are listed so a valid one can be picked
+			zap.S().Warn("Could not read current context: ", err)
+		}
+	}
+
+	names, err := config.ListContexts()
+	if err != nil {
+		zap.S().Fatal("Could not list contexts: ", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tACCOUNT URL\tUSERNAME\tREGION\tTENANT")
+	for _, name := range names {
+		var ctx objects.Config
+		data, err := os.ReadFile(config.ContextLoc(name))
+		if err == nil {
+			err = json.Unmarshal(data, &ctx)
+		}
+		if err != nil {
+			zap.S().Debugf("Could not read context %s: %s", name, err)
+		}
+		marker := ""
+		if name == current {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", marker, name, ctx.Fqdn, ctx.Username, ctx.Region, ctx.Tenant)
+	}
+	w.Flush()
+	zap.S().Debug("==========Finished running get-contexts config==========")
+}
@@ -61,6 +61,7 @@ func deauthNodeRun(cmd *cobra.Command, args []string) {
 	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, nc); err != nil {
 		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
 	}
+	defer executor.Close()
 
 	var c client.Client
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
@@ -76,11 +77,18 @@ func deauthNodeRun(cmd *cobra.Command, args []string) {
 	if ipAdd != "" {
 		nodeIPs = append(nodeIPs, ipAdd)
 	} else {
-		nodeIPs = append(nodeIPs, pmk.GetIp().String())
+		ip, err := pmk.GetIp()
+		if err != nil {
+			zap.S().Fatalf("Unable to find the IP of this node: %s", err.Error())
+		}
+		nodeIPs = append(nodeIPs, ip.String())
 	}
 	projectId := auth.ProjectID
 	token := auth.Token
-	nodeUuids := c.Resmgr.GetHostId(token, nodeIPs)
+	nodeUuids, err := c.Resmgr.GetHostId(token, nodeIPs)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
 	if len(nodeUuids) == 0 {
 		zap.S().Fatalf("Could not find the node. Check if the node associated with this account")
 	}
@@ -92,7 +100,10 @@ func deauthNodeRun(cmd *cobra.Command, args []string) {
 
 	if !detachedMode && isMaster.ClusterUuid != "" {
 
-		projectNodes := c.Qbert.GetAllNodes(token, projectId)
+		projectNodes, err := c.Qbert.GetAllNodes(token, projectId)
+		if err != nil {
+			zap.S().Fatalf("Unable to get the nodes: %s", err.Error())
+		}
 		clusterNodes := getAllClusterNodes(projectNodes, []string{isMaster.ClusterUuid})
 
 		if len(clusterNodes) == 1 || isMaster.IsMaster == 1 {
@@ -3,6 +3,8 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
@@ -25,7 +27,8 @@ var decommissionNodeCmd = &cobra.Command{
 		}
 		return nil
 	},
-	Run: decommissionNodeRun,
+	Run:         decommissionNodeRun,
+	Annotations: dryRunSupported,
 }
 
 func init() {
@@ -34,14 +37,20 @@ func init() {
 	decommissionNodeCmd.Flags().StringVarP(&nc.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
 	decommissionNodeCmd.Flags().StringVarP(&nc.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
 	decommissionNodeCmd.Flags().StringSliceVarP(&nc.IPs, "ip", "i", []string{}, "IP address of host to be decommissioned")
+	addSSHFlags(decommissionNodeCmd, &nc)
+	addInventoryFlags(decommissionNodeCmd)
 	rootCmd.AddCommand(decommissionNodeCmd)
 }
 
 func decommissionNodeRun(cmd *cobra.Command, args []string) {
 
+	if _, err := loadInventory(&nc); err != nil {
+		zap.S().Fatalf("Unable to load the inventory: %s", err.Error())
+	}
+
 	detachedMode := cmd.Flags().Changed("no-prompt")
 
-	if cmdexec.CheckRemote(nc) {
+	if cmdexec.CheckRemote(nc) && inventoryHosts == nil {
 		if !config.ValidateNodeConfig(&nc, !detachedMode) {
 			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
 		}
@@ -50,15 +59,27 @@ func decommissionNodeRun(cmd *cobra.Command, args []string) {
 	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: attachconfig.MFA}
 	var err error
 	if detachedMode {
-		err = config.LoadConfig(util.Pf9DBLoc, cfg, nc)
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, configNodeConfig(nc))
 	} else {
-		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, nc)
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, configNodeConfig(nc))
 	}
 	if err != nil {
 		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
 	}
 	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
 	zap.S().Debug("Loaded Config Successfully")
-	pmk.DecommissionNode(cfg, nc, true)
+	if len(nc.IPs) > 1 {
+		results := pmk.RunOnHosts(os.Stdout, nc.IPs, parallelism, func(host string, out io.Writer) (string, error) {
+			if err := pmk.DecommissionNode(cfg, hostNodeConfig(nc, host), true); err != nil {
+				return "", err
+			}
+			return "decommissioned", nil
+		})
+		printHostResults(os.Stdout, results)
+		return
+	}
+	if err := pmk.DecommissionNode(cfg, nc, true); err != nil {
+		zap.S().Fatalf(err.Error())
+	}
 
 }
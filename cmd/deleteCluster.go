@@ -71,6 +71,7 @@ func deleteClusterRun(cmd *cobra.Command, args []string) {
 	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, nc); err != nil {
 		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
 	}
+	defer executor.Close()
 
 	var c client.Client
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
@@ -82,37 +83,18 @@ func deleteClusterRun(cmd *cobra.Command, args []string) {
 		zap.S().Fatalf("Failed to get keystone %s", err.Error())
 	}
 
-	projectId := auth.ProjectID
-	token := auth.Token
-
 	if !cmd.Flags().Changed("uuid") {
-		_, clusterUuid, _, err = c.Qbert.CheckClusterExists(clusterName, projectId, token)
-
+		_, clusterUuid, _, err = c.Qbert.CheckClusterExists(clusterName, auth.ProjectID, auth.Token)
 		if err != nil {
 			zap.S().Fatalf("Could not delete the cluster, error while fetching cluster uuid: %s", err.Error())
 		}
-
 	}
 
-	nodeIPs = append(nodeIPs, pmk.GetIp().String())
-
-	projectNodes := c.Qbert.GetAllNodes(token, projectId)
-	nodeUuids := c.Resmgr.GetHostId(token, nodeIPs)
-	localNode, err := getNodesFromUuids(nodeUuids, projectNodes)
+	ip, err := pmk.GetIp()
 	if err != nil {
-		zap.S().Fatalf("Could not delete cluster, error while fetch nodes info: %s", err.Error())
+		zap.S().Fatalf("Unable to find the IP of this node: %s", err.Error())
 	}
-
-	if len(localNode) == 1 && localNode[0].ClusterUuid == clusterUuid {
-		c.Executor.RunCommandWait("sudo pkill -9 `pidof kubelet`")
-		c.Executor.RunCommandWait("sudo pkill -9 `pidof etcd`")
-		c.Executor.RunCommandWait("sudo pkill -9 `pidof kube-proxy`")
-	}
-
-	err = c.Qbert.DeleteCluster(clusterUuid, projectId, token)
-	if err != nil {
-		zap.S().Fatalf("Error deleting cluster ", err.Error())
+	if err := pmk.DeleteCluster(c, auth, clusterUuid, ip.String()); err != nil {
+		zap.S().Fatal(err.Error())
 	}
-	fmt.Println("Cluster deletion started....This may take a few minutes.")
-	zap.S().Debug("Cluster deletion started....This may take a few minutes.")
 }
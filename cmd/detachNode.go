@@ -39,7 +39,11 @@ func init() {
 func detachNodeRun(cmd *cobra.Command, args []string) {
 
 	if len(nodeIPs) == 0 {
-		nodeIPs = append(nodeIPs, pmk.GetIp().String())
+		ip, err := pmk.GetIp()
+		if err != nil {
+			zap.S().Fatalf("Unable to find the IP of this node: %s", err.Error())
+		}
+		nodeIPs = append(nodeIPs, ip.String())
 	}
 
 	detachedMode := cmd.Flags().Changed("no-prompt")
@@ -66,6 +70,7 @@ func detachNodeRun(cmd *cobra.Command, args []string) {
 	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, nc); err != nil {
 		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
 	}
+	defer executor.Close()
 
 	var c client.Client
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
@@ -78,73 +83,9 @@ func detachNodeRun(cmd *cobra.Command, args []string) {
 	if err != nil {
 		zap.S().Fatalf("Failed to get keystone %s", err.Error())
 	}
-	projectId := auth.ProjectID
-	token := auth.Token
-
-	projectNodes := c.Qbert.GetAllNodes(token, projectId)
-	nodeUuids := c.Resmgr.GetHostId(token, nodeIPs)
-
-	detachNodes, err := getNodesFromUuids(nodeUuids, projectNodes)
-
-	if err != nil {
-		zap.S().Fatalf(err.Error())
-	}
-
-	fmt.Println("Starting detaching process")
-
-	if err := c.Segment.SendEvent("Starting detach-node", auth, "", ""); err != nil {
-		zap.S().Debugf("Unable to send Segment event for detach node. Error: %s", err.Error())
-	}
-
-	for i := range detachNodes {
-
-		isMaster, err := c.Qbert.GetNodeInfo(token, projectId, nodeUuids[0])
-		if err != nil {
-			zap.S().Fatalf("Failed to get node info for host %s: %s", nodeUuids[0], err.Error())
-			//continue
-		}
-		clusterNodes := getAllClusterNodes(projectNodes, []string{isMaster.ClusterUuid})
-
-		if len(clusterNodes) == 1 || isMaster.IsMaster == 1 {
-			fmt.Printf("Node %v is either the master node or the last node in the cluster\n", isMaster.Uuid)
-		}
-
-		err1 := c.Qbert.DetachNode(detachNodes[i].ClusterUuid, projectId, token, detachNodes[i].Uuid)
-
-		if err1 != nil {
-			if err := c.Segment.SendEvent("Detaching-node", auth, "Failed to detach node", ""); err != nil {
-				zap.S().Debugf("Unable to send Segment event for detach node. Error: %s", err.Error())
-			}
-			zap.S().Fatalf("Encountered an error while detaching the %s node from a Kubernetes cluster : %s", detachNodes[i].PrimaryIp, err1)
-		} else {
-			if err := c.Segment.SendEvent("Detaching-node", detachNodes[i].PrimaryIp, "Node detached", ""); err != nil {
-				zap.S().Debugf("Unable to send Segment event for detach node. Error: %s", err.Error())
-			}
-			zap.S().Infof("Node [%v] detached from cluster", detachNodes[i].Uuid)
-		}
-	}
-
-}
-
-// returns the nodes whos ip's were passed in the flag (or the node installed on the machine if no ip was passed)
-func getNodesFromUuids(nodeUuids []string, allNodes []qbert.Node) ([]qbert.Node, error) {
-
-	var nodesUuid []qbert.Node
-	for i := range allNodes {
-		for j := range nodeUuids {
-
-			if nodeUuids[j] == allNodes[i].Uuid {
-
-				if allNodes[i].ClusterUuid == "" {
-					return nodesUuid, fmt.Errorf("The node %v is not connected to any clusters", allNodes[i].PrimaryIp)
-				} else {
-					nodesUuid = append(nodesUuid, allNodes[i])
-					break
-				}
-			}
-		}
+	if err := pmk.DetachNodes(c, auth, nodeIPs); err != nil {
+		zap.S().Fatal(err.Error())
 	}
-	return nodesUuid, nil
 }
 
 // returns a list of all clusters the nodes are attached to
@@ -0,0 +1,88 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/google/uuid"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/fakedu"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// devCmd groups the commands used for developing and demoing pf9ctl
+var devCmd = &cobra.Command{
+	Use:   "dev",
+	Short: "Tools for developing and demoing pf9ctl",
+}
+
+var (
+	fakeDUListen string
+	fakeDUHosts  []string
+
+	devCmdFakeDU = &cobra.Command{
+		Use:   "fake-du",
+		Short: "Runs a local fake Platform9 control plane",
+		Long: `Serve an in-memory stand-in for the Platform9 control plane implementing the keystone,
+	resmgr and qbert APIs used by pf9ctl, and store a context pointing to it, fake-du unless
+	--context is passed. The hostagent installer it serves does not install any package,
+	it only registers the host.`,
+		Example: "pf9ctl dev fake-du --host 10.0.0.5\npf9ctl --context fake-du bootstrap demo",
+		Run:     devCmdFakeDURun,
+	}
+)
+
+func init() {
+	devCmdFakeDU.Flags().StringVar(&fakeDUListen, "listen", "127.0.0.1:9443", "address the fake control plane listens on")
+	devCmdFakeDU.Flags().StringSliceVar(&fakeDUHosts, "host", []string{}, "IP of a host registered with the fake control plane at startup")
+
+	devCmd.AddCommand(devCmdFakeDU)
+	rootCmd.AddCommand(devCmd)
+}
+
+func devCmdFakeDURun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running dev fake-du==========")
+
+	fakeDUContext := "fake-du"
+	if cmd.Flags().Changed("context") {
+		fakeDUContext = contextName
+	}
+
+	du := fakedu.NewUnstarted()
+	if err := du.Listen(fakeDUListen); err != nil {
+		zap.S().Fatalf("Unable to listen on %s: %s", fakeDUListen, err.Error())
+	}
+	du.StartTLS()
+	defer du.Close()
+
+	for _, ip := range fakeDUHosts {
+		du.AddHost(uuid.New().String(), "fake-"+ip, ip)
+	}
+
+	cfg := &objects.Config{
+		Fqdn:          du.URL,
+		Username:      du.Username,
+		Password:      du.Password,
+		Tenant:        du.Tenant,
+		Region:        du.Region,
+		AllowInsecure: true,
+	}
+	if err := config.StoreConfig(cfg, config.ContextLoc(fakeDUContext)); err != nil {
+		zap.S().Fatalf("Unable to store the %s context: %s", fakeDUContext, err.Error())
+	}
+
+	fmt.Println(color.Green("✓ ") + "Fake control plane listening on " + du.URL)
+	fmt.Printf("Use it with `pf9ctl --context %s <command>` or `pf9ctl use context %s`, stop it with Ctrl+C\n", fakeDUContext, fakeDUContext)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	zap.S().Debug("==========Finished running dev fake-du==========")
+}
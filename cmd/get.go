@@ -15,18 +15,6 @@ var getCmd = &cobra.Command{
 	// },
 }
 
-/*
-This initialization of get command to root isnot in use for now.
 func init() {
-	//rootCmd.AddCommand(getCmd)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// getCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// getCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-}*/
+	rootCmd.AddCommand(getCmd)
+}
@@ -0,0 +1,99 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/inventory"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inventoryFile  string
+	inventoryLimit string
+	// inventoryHosts are the hosts selected from the inventory, by IP
+	inventoryHosts map[string]inventory.Host
+)
+
+// addInventoryFlags adds the flags selecting the nodes from an inventory file
+func addInventoryFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&inventoryFile, "inventory", "", "YAML or Ansible INI file listing the nodes with their ssh settings and roles")
+	cmd.Flags().StringVar(&inventoryLimit, "limit", "", "comma separated groups, names or IPs of the inventory nodes to run against (default all)")
+}
+
+// selectInventoryHosts returns the hosts selected from the inventory, or nil when
+// no inventory is passed.
+func selectInventoryHosts() ([]inventory.Host, error) {
+	if inventoryFile == "" {
+		if inventoryLimit != "" {
+			return nil, errors.New("--limit requires --inventory")
+		}
+		return nil, nil
+	}
+	inv, err := inventory.Load(inventoryFile)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := inv.Select(inventoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	inventoryHosts = map[string]inventory.Host{}
+	for _, h := range hosts {
+		inventoryHosts[h.IP] = h
+	}
+	return hosts, nil
+}
+
+// loadInventory replaces the IPs of nc with the hosts selected from the inventory,
+// it returns nil when no inventory is passed. The settings of the hosts win over
+// the flags, which fill in what the inventory leaves empty.
+func loadInventory(nc *objects.NodeConfig) ([]inventory.Host, error) {
+	if inventoryFile != "" && len(nc.IPs) > 0 {
+		return nil, errors.New("--ip can not be used with --inventory, use --limit to select the nodes")
+	}
+	hosts, err := selectInventoryHosts()
+	if err != nil || hosts == nil {
+		return nil, err
+	}
+	for _, h := range hosts {
+		nc.IPs = append(nc.IPs, h.IP)
+		hostNc := hostNodeConfig(*nc, h.IP)
+		if cmdexec.CheckRemote(hostNc) && !config.ValidateNodeConfig(&hostNc, false) {
+			return nil, fmt.Errorf("No ssh user or credentials for %s, set them in the inventory or with the flags", h.Name)
+		}
+	}
+	if len(hosts) == 1 {
+		*nc = hostNodeConfig(*nc, hosts[0].IP)
+	}
+	return hosts, nil
+}
+
+// inventoryRoleIPs returns the IPs of the selected inventory hosts with a role
+func inventoryRoleIPs(hosts []inventory.Host, role string) []string {
+	var ips []string
+	for _, h := range hosts {
+		if h.Role == role {
+			ips = append(ips, h.IP)
+		}
+	}
+	return ips
+}
+
+// inventoryHostNodeConfig applies the inventory settings of host, if it has any
+func inventoryHostNodeConfig(nc objects.NodeConfig, host string) objects.NodeConfig {
+	h, ok := inventoryHosts[host]
+	if !ok {
+		return nc
+	}
+	if h.SudoPassword != "" {
+		ssh.SetHostSudoPassword(host, h.SudoPassword)
+	}
+	return h.NodeConfig(nc)
+}
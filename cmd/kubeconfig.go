@@ -0,0 +1,159 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	kubeconfigAuth    string
+	kubeconfigFile    string
+	kubeconfigMerge   bool
+	kubeconfigLoc     string
+	kubeconfigContext string
+
+	kubeconfigCmdGet = &cobra.Command{
+		Use:   "kubeconfig <cluster>",
+		Short: "Download the kubeconfig of a cluster",
+		Long: `Download the kubeconfig of a cluster with the credentials of the current config
+	embedded. It is printed, written to a file with --file or merged into the kubeconfig
+	used by kubectl with --merge.`,
+		Args: func(kubeconfigCmdGet *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("Cluster name is required")
+			}
+			return nil
+		},
+		Example: "pf9ctl get kubeconfig prod --merge\npf9ctl get kubeconfig prod --auth password -f prod.yaml",
+		Run:     kubeconfigCmdGetRun,
+	}
+)
+
+func init() {
+	kubeconfigCmdGet.Flags().StringVar(&kubeconfigAuth, "auth", pmk.KubeconfigAuthToken, "credentials embedded in the kubeconfig, token (expires with the keystone token) or password")
+	kubeconfigCmdGet.Flags().StringVarP(&kubeconfigFile, "file", "f", "", "write the kubeconfig to this file")
+	kubeconfigCmdGet.Flags().BoolVar(&kubeconfigMerge, "merge", false, "merge the kubeconfig into the kubeconfig used by kubectl and switch to its context")
+	kubeconfigCmdGet.Flags().StringVar(&kubeconfigLoc, "kubeconfig", "", "kubeconfig file to merge into (default $KUBECONFIG or ~/.kube/config)")
+	kubeconfigCmdGet.Flags().StringVar(&kubeconfigContext, "kube-context", "", "name of the kubeconfig context, cluster and user (default the cluster name)")
+	kubeconfigCmdGet.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+	getCmd.AddCommand(kubeconfigCmdGet)
+}
+
+func kubeconfigCmdGetRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running get kubeconfig==========")
+
+	clusterName := args[0]
+	if kubeconfigAuth != pmk.KubeconfigAuthToken && kubeconfigAuth != pmk.KubeconfigAuthPassword {
+		zap.S().Fatal(pmk.ErrInvalidKubeconfigAuth.Error())
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: attachconfig.MFA}
+	var err error
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	defer executor.Close()
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Failed to get keystone %s", err.Error())
+	}
+
+	exists, clusterUuid, _, err := c.Qbert.CheckClusterExists(clusterName, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to check cluster %s: %s", clusterName, err.Error())
+	}
+	if !exists {
+		zap.S().Fatalf("Cluster %s not found", clusterName)
+	}
+
+	data, err := c.Qbert.GetKubeconfig(clusterUuid, auth.ProjectID, auth.Token)
+	if err != nil {
+		zap.S().Fatalf("Unable to download the kubeconfig: %s", err.Error())
+	}
+	kubeconfig, err := pmk.ParseKubeconfig(data)
+	if err != nil {
+		zap.S().Fatal(err.Error())
+	}
+	if err = kubeconfig.EmbedCredentials(kubeconfigAuth, auth.Token, cfg.Username, cfg.Password); err != nil {
+		zap.S().Fatal(err.Error())
+	}
+	name := kubeconfigContext
+	if name == "" {
+		name = clusterName
+	}
+	if err = kubeconfig.Rename(name); err != nil {
+		zap.S().Fatal(err.Error())
+	}
+
+	if kubeconfigFile == "" && !kubeconfigMerge {
+		out, err := kubeconfig.Marshal()
+		if err != nil {
+			zap.S().Fatalf("Unable to render the kubeconfig: %s", err.Error())
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	if kubeconfigFile != "" {
+		if err = pmk.WriteKubeconfig(kubeconfigFile, kubeconfig); err != nil {
+			zap.S().Fatalf("Unable to write the kubeconfig: %s", err.Error())
+		}
+		fmt.Println(color.Green("✓ ") + "Kubeconfig of cluster " + clusterName + " written to " + kubeconfigFile)
+	}
+
+	if kubeconfigMerge {
+		loc := kubeconfigLoc
+		if loc == "" {
+			if loc, err = pmk.DefaultKubeconfigLoc(); err != nil {
+				zap.S().Fatalf("Unable to find the kubeconfig: %s", err.Error())
+			}
+		}
+		existing, err := pmk.LoadKubeconfig(loc)
+		if err != nil {
+			zap.S().Fatalf("Unable to read %s: %s", loc, err.Error())
+		}
+		existing.Merge(kubeconfig)
+		if err = pmk.WriteKubeconfig(loc, existing); err != nil {
+			zap.S().Fatalf("Unable to write the kubeconfig: %s", err.Error())
+		}
+		fmt.Println(color.Green("✓ ") + "Merged kubeconfig of cluster " + clusterName + " into " + loc + ", switched to context " + name)
+	}
+
+	if kubeconfigAuth == pmk.KubeconfigAuthToken {
+		fmt.Println("The embedded token expires with the keystone token, run this command again or use --auth password for a kubeconfig which does not expire")
+	}
+	zap.S().Debug("==========Finished running get kubeconfig==========")
+}
@@ -0,0 +1,80 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"go.uber.org/zap"
+)
+
+// Number of hosts processed at the same time when more than one IP is passed
+var parallelism int
+
+const defaultParallelism = 10
+
+var errMultiHostPrompt = errors.New("Running against more than one host requires --no-prompt, pass the ssh and sudo credentials as flags")
+
+// hostNodeConfig returns the node config for a single host of a multi host run
+func hostNodeConfig(nc objects.NodeConfig, host string) objects.NodeConfig {
+	hostNc := nc
+	hostNc.IPs = []string{host}
+	return inventoryHostNodeConfig(hostNc, host)
+}
+
+// configNodeConfig is the node config of the first node, the one used to
+// validate the context
+func configNodeConfig(nc objects.NodeConfig) objects.NodeConfig {
+	if len(nc.IPs) == 0 {
+		return nc
+	}
+	return hostNodeConfig(nc, nc.IPs[0])
+}
+
+// newHostClient creates the clients for one host, progress for the host is printed to out
+func newHostClient(cfg *objects.Config, nc objects.NodeConfig, host string, out io.Writer) (client.Client, error) {
+	executor, err := cmdexec.GetExecutorForHost(cfg.ProxyURL, nc, host)
+	if err != nil {
+		return client.Client{}, fmt.Errorf("Unable to create executor: %w", err)
+	}
+	c, err := client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false)
+	if err != nil {
+		return client.Client{}, fmt.Errorf("Unable to create client: %w", err)
+	}
+	c.Out = out
+	return c, nil
+}
+
+// checkHostNode runs the pre-requisite checks on one host of a multi host run
+func checkHostNode(cfg *objects.Config, nc objects.NodeConfig, auth keystone.KeystoneAuth, c client.Client) (pmk.CheckNodeResult, []platform.Check, error) {
+	if cmdexec.CheckRemote(nc) {
+		if err := SudoPasswordCheck(c.Executor, true, nc.IPs[0], nc.SudoPassword); err != nil {
+			return pmk.RequiredFail, nil, fmt.Errorf("Failed executing commands with sudo: %w", err)
+		}
+	}
+	result, checks, err := pmk.CheckNode(*cfg, c, auth, nc)
+	if err != nil {
+		return result, checks, err
+	}
+	if result == pmk.RequiredFail {
+		return result, checks, errors.New("Required pre-requisite check(s) failed")
+	}
+	return result, checks, nil
+}
+
+// printHostResults prints the per host summary to out and exits with 1 if any host failed
+func printHostResults(out io.Writer, results []pmk.HostResult) {
+	fmt.Fprintln(out)
+	pmk.PrintHostResults(out, results)
+	if failed := pmk.HostsFailed(results); failed > 0 {
+		zap.S().Fatalf("Failed on %d of %d host(s)", failed, len(results))
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// nodeCmd groups the commands looking after the Platform9 agents of a node
+var nodeCmd = &cobra.Command{
+	Use:   "node",
+	Short: "Inspect and repair the Platform9 agents of a node",
+}
+
+var nodeStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Shows the health of the Platform9 agents of a node",
+	Long: `Combine the state of the Platform9 units, the installed Platform9 packages, the host ID
+	and what the management plane reports for the node into a verdict: healthy, degraded or
+	disconnected, with hints on what to fix. pf9ctl exits with 1 when a node is not healthy.`,
+	Example:     "pf9ctl node status --ip <nodeIP> -u <user> -s <sshKey>",
+	Run:         nodeStatusRun,
+	Annotations: outputSupported,
+}
+
+var nodeRepairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Brings a disconnected node back to the management plane",
+	Long: `Escalate through the repair steps until resmgr reports the node responding again:
+	restart pf9-comms and pf9-hostagent, check the certificates in /etc/pf9/certs, reinstall
+	the hostagent keeping its data and authorize the host again. Each step is reported, pf9ctl
+	waits up to --timeout for the node to respond after each of them.`,
+	Example:     "pf9ctl node repair --ip <nodeIP> -u <user> -s <sshKey>",
+	Run:         nodeRepairRun,
+	Annotations: dryRunSupported,
+}
+
+var (
+	nodeCmdConfig     objects.NodeConfig
+	nodeRepairTimeout time.Duration
+)
+
+func init() {
+	nodeRepairCmd.Flags().DurationVar(&nodeRepairTimeout, "timeout", 3*time.Minute, "How long to wait for the node to respond after each repair step")
+	for _, cmd := range []*cobra.Command{nodeStatusCmd, nodeRepairCmd} {
+		cmd.Flags().StringVarP(&nodeCmdConfig.User, "user", "u", "", "ssh username for the nodes")
+		cmd.Flags().StringVarP(&nodeCmdConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+		cmd.Flags().StringVarP(&nodeCmdConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+		cmd.Flags().StringSliceVarP(&nodeCmdConfig.IPs, "ip", "i", []string{}, "IP address of the nodes")
+		cmd.Flags().StringVar(&nodeCmdConfig.MFA, "mfa", "", "MFA token")
+		cmd.Flags().StringVarP(&nodeCmdConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+		cmd.Flags().IntVar(&parallelism, "parallelism", defaultParallelism, "Number of hosts processed at the same time when more than one IP is passed")
+		addSSHFlags(cmd, &nodeCmdConfig)
+		addInventoryFlags(cmd)
+		nodeCmd.AddCommand(cmd)
+	}
+	rootCmd.AddCommand(nodeCmd)
+}
+
+// loadNodeCmdClient selects the nodes, loads the context and logs in to the
+// management plane
+func loadNodeCmdClient(cmd *cobra.Command) (*objects.Config, client.Client, keystone.KeystoneAuth) {
+	if _, err := loadInventory(&nodeCmdConfig); err != nil {
+		zap.S().Fatalf("Unable to load the inventory: %s", err.Error())
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if len(nodeCmdConfig.IPs) > 1 && !detachedMode {
+		zap.S().Fatal(errMultiHostPrompt.Error())
+	}
+	if cmdexec.CheckRemote(nodeCmdConfig) && inventoryHosts == nil {
+		if !config.ValidateNodeConfig(&nodeCmdConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: nodeCmdConfig.MFA}
+	var err error
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, configNodeConfig(nodeCmdConfig))
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, configNodeConfig(nodeCmdConfig))
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Failed to get keystone %s", err.Error())
+	}
+	return cfg, c, auth
+}
+
+// nodeCmdHosts returns the hosts the node command runs against, localhost when no
+// IP is passed
+func nodeCmdHosts() []string {
+	if len(nodeCmdConfig.IPs) == 0 {
+		return []string{"localhost"}
+	}
+	return nodeCmdConfig.IPs
+}
+
+// newNodeCmdHostClient creates the clients for one host of a node command and
+// checks sudo works on a remote host
+func newNodeCmdHostClient(cfg *objects.Config, host string, out io.Writer) (client.Client, error) {
+	hostNc := hostNodeConfig(nodeCmdConfig, host)
+	c, err := newHostClient(cfg, hostNc, host, out)
+	if err != nil {
+		return c, err
+	}
+	if cmdexec.CheckRemote(hostNc) {
+		if err := SudoPasswordCheck(c.Executor, true, host, hostNc.SudoPassword); err != nil {
+			c.Segment.Close()
+			c.Executor.Close()
+			return c, fmt.Errorf("Failed executing commands with sudo: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func nodeStatusRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running node status==========")
+	if outputFormat == pmk.OutputJUnit {
+		zap.S().Fatalf("Output format %s is not supported by node status", outputFormat)
+	}
+	cfg, c, auth := loadNodeCmdClient(cmd)
+	defer c.Segment.Close()
+	defer c.Executor.Close()
+	progress := progressOut()
+
+	hosts := nodeCmdHosts()
+	statuses := make([]pmk.NodeStatus, len(hosts))
+	hostStatus := func(i int, host string, out io.Writer) (string, error) {
+		hc, err := newNodeCmdHostClient(cfg, host, out)
+		if err != nil {
+			return "", err
+		}
+		defer hc.Segment.Close()
+		defer hc.Executor.Close()
+		status, err := pmk.GetNodeStatus(hc, auth, cfg.Fqdn, host)
+		if err != nil {
+			return "", err
+		}
+		statuses[i] = status
+		return status.Verdict, nil
+	}
+
+	var failed []error
+	if len(hosts) > 1 {
+		index := map[string]int{}
+		for i, host := range hosts {
+			index[host] = i
+		}
+		results := pmk.RunOnHosts(progress, hosts, parallelism, func(host string, out io.Writer) (string, error) {
+			return hostStatus(index[host], host, out)
+		})
+		fmt.Fprintln(progress)
+		for _, r := range results {
+			if r.Err != nil {
+				failed = append(failed, fmt.Errorf("%s: %w", r.Host, r.Err))
+			}
+		}
+	} else if _, err := hostStatus(0, hosts[0], progress); err != nil {
+		failed = append(failed, err)
+	}
+	if len(failed) > 0 {
+		for _, err := range failed {
+			zap.S().Error(err.Error())
+		}
+		zap.S().Fatalf("Unable to get the status of %d of %d host(s)", len(failed), len(hosts))
+	}
+
+	if err := pmk.WriteNodeStatus(reportOut, outputFormat, statuses); err != nil {
+		zap.S().Fatalf("Unable to write the node status: %s", err.Error())
+	}
+	unhealthy := 0
+	for _, s := range statuses {
+		if s.Verdict != pmk.NodeHealthy {
+			unhealthy++
+		}
+	}
+	if unhealthy > 0 {
+		zap.S().Fatalf("%d of %d node(s) are not healthy", unhealthy, len(hosts))
+	}
+	zap.S().Debug("==========Finished running node status==========")
+}
+
+func nodeRepairRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running node repair==========")
+	cfg, c, auth := loadNodeCmdClient(cmd)
+	defer c.Segment.Close()
+	defer c.Executor.Close()
+	progress := progressOut()
+
+	hosts := nodeCmdHosts()
+	repairHost := func(host string, out io.Writer) (string, error) {
+		hc, err := newNodeCmdHostClient(cfg, host, out)
+		if err != nil {
+			return "", err
+		}
+		defer hc.Segment.Close()
+		defer hc.Executor.Close()
+		return pmk.RepairNode(*cfg, hc, auth, nodeRepairTimeout)
+	}
+
+	if len(hosts) > 1 {
+		printHostResults(progress, pmk.RunOnHosts(progress, hosts, parallelism, repairHost))
+	} else if _, err := repairHost(hosts[0], progress); err != nil {
+		zap.S().Fatalf("Unable to repair the node: %s", err.Error())
+	}
+	zap.S().Debug("==========Finished running node repair==========")
+}
@@ -0,0 +1,95 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// nodesCmdGet represents the get nodes command
+var nodesCmdGet = &cobra.Command{
+	Use:     "nodes",
+	Aliases: []string{"node"},
+	Short:   "Display the nodes",
+	Long: `List every host connected to the PMK control plane with its status,
+	its roles and the cluster it is attached to`,
+	Args: func(nodesCmdGet *cobra.Command, args []string) error {
+		if len(args) > 0 {
+			return errors.New("No parameters are needed, use --cluster to select the nodes of a cluster")
+		}
+		return nil
+	},
+	Example:     "pf9ctl get nodes --cluster <clusterName> --output json",
+	Run:         nodesCmdGetRun,
+	Annotations: outputSupported,
+}
+
+var nodeFilter pmk.NodeFilter
+
+func init() {
+	nodesCmdGet.Flags().StringVar(&nodeFilter.Cluster, "cluster", "", "only list the nodes of the cluster with this name or uuid")
+	nodesCmdGet.Flags().BoolVar(&nodeFilter.Unassigned, "unassigned", false, "only list the nodes which are not attached to a cluster")
+	nodesCmdGet.Flags().BoolVar(&nodeFilter.Disconnected, "disconnected", false, "only list the nodes which are not responding")
+	nodesCmdGet.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+	getCmd.AddCommand(nodesCmdGet)
+}
+
+func nodesCmdGetRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running get nodes==========")
+
+	if nodeFilter.Cluster != "" && nodeFilter.Unassigned {
+		zap.S().Fatal("--cluster and --unassigned can not be used together")
+	}
+	if outputFormat == pmk.OutputJUnit {
+		zap.S().Fatalf("Output format %s is not supported by get nodes", outputFormat)
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: attachconfig.MFA}
+	var err error
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, objects.NodeConfig{})
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	defer executor.Close()
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+	defer c.Segment.Close()
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Failed to get keystone %s", err.Error())
+	}
+
+	nodes, err := pmk.ListNodes(c, auth, nodeFilter)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	if err := pmk.WriteNodes(reportOut, outputFormat, nodes); err != nil {
+		zap.S().Fatalf("Unable to write the nodes: %s", err.Error())
+	}
+	zap.S().Debug("==========Finished running get nodes==========")
+}
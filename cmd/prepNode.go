@@ -4,8 +4,10 @@ package cmd
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"time"
@@ -31,7 +33,8 @@ var prepNodeCmd = &cobra.Command{
 	Short: "Sets up prerequisites & prepares a node to use with PMK",
 	Long: `Prepare a node to be ready to be added to a Kubernetes cluster. Read more
 	at http://pf9.io/cli_clprep.`,
-	Run: prepNodeRun,
+	Run:         prepNodeRun,
+	Annotations: dryRunSupported,
 	Args: func(prepNodeCmd *cobra.Command, args []string) error {
 		if prepNodeCmd.Flags().Changed("disable-swapoff") {
 			util.SwapOffDisabled = true
@@ -70,6 +73,9 @@ func init() {
 	prepNodeCmd.Flags().StringVar(&util.KubeVersion, "kube-version", "", "Specific version of pf9-kube to install")
 	prepNodeCmd.Flags().MarkHidden("kube-version")
 	prepNodeCmd.Flags().BoolVar(&util.CheckIfOnboarded, "skip-connected", false, "If the node is already connected to the PMK control plane, prep-node will be skipped")
+	prepNodeCmd.Flags().IntVar(&parallelism, "parallelism", defaultParallelism, "Number of hosts prepared at the same time when more than one IP is passed")
+	addSSHFlags(prepNodeCmd, &nodeConfig)
+	addInventoryFlags(prepNodeCmd)
 
 	rootCmd.AddCommand(prepNodeCmd)
 }
@@ -84,10 +90,19 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 		platform.SkipOSChecks = true
 	}
 
+	if _, err := loadInventory(&nodeConfig); err != nil {
+		zap.S().Fatalf("Unable to load the inventory: %s", err.Error())
+	}
+
 	detachedMode := cmd.Flags().Changed("no-prompt")
 	isRemote := cmdexec.CheckRemote(nodeConfig)
+	multiHost := len(nodeConfig.IPs) > 1
 
-	if isRemote {
+	if multiHost && !detachedMode {
+		zap.S().Fatal(errMultiHostPrompt.Error())
+	}
+
+	if isRemote && inventoryHosts == nil {
 		if !config.ValidateNodeConfig(&nodeConfig, !detachedMode) {
 			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
 		}
@@ -97,9 +112,9 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 	var err error
 	if detachedMode {
 		nodeConfig.RemoveExistingPkgs = true
-		err = config.LoadConfig(util.Pf9DBLoc, cfg, nodeConfig)
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, configNodeConfig(nodeConfig))
 	} else {
-		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, nodeConfig)
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, configNodeConfig(nodeConfig))
 	}
 
 	if err != nil {
@@ -109,9 +124,10 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 	fmt.Println(color.Green("✓ ") + "Loaded Config Successfully")
 	zap.S().Debug("Loaded Config Successfully")
 	var executor cmdexec.Executor
-	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, nodeConfig); err != nil {
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, configNodeConfig(nodeConfig)); err != nil {
 		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
 	}
+	defer executor.Close()
 
 	var c client.Client
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
@@ -137,14 +153,43 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 		}
 		zap.S().Fatalf("Unable to obtain keystone credentials: %s", err.Error())
 	}
+	if multiHost {
+		results := pmk.RunOnHosts(os.Stdout, nodeConfig.IPs, parallelism, func(host string, out io.Writer) (string, error) {
+			hostNc := hostNodeConfig(nodeConfig, host)
+			c, err := newHostClient(cfg, hostNc, host, out)
+			if err != nil {
+				return "", err
+			}
+			defer c.Segment.Close()
+			defer c.Executor.Close()
+			result, _, err := checkHostNode(cfg, hostNc, auth, c)
+			if err != nil {
+				return string(result), err
+			}
+			if result == pmk.AlreadyConnected {
+				return "already connected", nil
+			}
+			if result == pmk.OptionalFail && !skipChecks {
+				return string(result), errors.New("Optional pre-requisite check(s) failed. Use --skip-checks to skip these checks")
+			}
+			if err := pmk.PrepNode(*cfg, c, auth); err != nil {
+				return "", fmt.Errorf("Failed to prepare node. %w", err)
+			}
+			return "prepared", nil
+		})
+		printHostResults(os.Stdout, results)
+		zap.S().Debug("==========Finished running prep-node==========")
+		return
+	}
+
 	if isRemote {
-		if err := SudoPasswordCheck(executor, detachedMode, nodeConfig.SudoPassword); err != nil {
+		if err := SudoPasswordCheck(executor, detachedMode, nodeConfig.IPs[0], nodeConfig.SudoPassword); err != nil {
 			zap.S().Fatal("Failed executing commands on remote machine with sudo: ", err.Error())
 		}
 	}
 
 	// If all pre-requisite checks passed in Check-Node then prep-node
-	result, err := pmk.CheckNode(*cfg, c, auth, nodeConfig)
+	result, _, err := pmk.CheckNode(*cfg, c, auth, nodeConfig)
 	if err != nil {
 		zap.S().Fatalf("\nPre-requisite check(s) failed %s\n", err.Error())
 	}
@@ -153,6 +198,8 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 		zap.S().Fatalf(color.Red("x ")+"Required pre-requisite check(s) failed. See %s or use --verbose for logs \n", log.GetLogLocation(util.Pf9Log))
 	} else if result == pmk.CleanInstallFail {
 		fmt.Println("\nPrevious Installation Removed")
+	} else if result == pmk.AlreadyConnected {
+		os.Exit(0)
 	}
 
 	if result == pmk.OptionalFail {
@@ -182,9 +229,10 @@ func prepNodeRun(cmd *cobra.Command, args []string) {
 }
 
 // To check if Remote Host needs Password to access Sudo and prompt for Sudo Password if exists.
-func SudoPasswordCheck(exec cmdexec.Executor, detached bool, sudoPass string) error {
+// The password is kept for the host only, so hosts checked in parallel do not share it.
+func SudoPasswordCheck(exec cmdexec.Executor, detached bool, host, sudoPass string) error {
 
-	ssh.SudoPassword = sudoPass
+	ssh.SetHostSudoPassword(host, sudoPass)
 
 	_, err := exec.RunWithStdout("-l | grep '(ALL) PASSWD: ALL'")
 	if err == nil {
@@ -203,12 +251,13 @@ func SudoPasswordCheck(exec cmdexec.Executor, detached bool, sudoPass string) er
 				zap.S().Fatalf("\n" + color.Red("x ") + "Invalid Sudo Password entered multiple times")
 			}
 			// Validate Sudo Password entered.
-			if ssh.SudoPassword == "" || validateSudoPassword(exec) == util.Invalid {
+			if sudoPass == "" || validateSudoPassword(exec) == util.Invalid {
 				loopcounter += 1
 				fmt.Printf("\n" + color.Red("x ") + "Invalid Sudo Password provided of Remote Host\n")
 				fmt.Printf("Enter Sudo password for Remote Host: ")
 				sudopassword, _ := terminal.ReadPassword(0)
-				ssh.SudoPassword = string(sudopassword)
+				sudoPass = string(sudopassword)
+				ssh.SetHostSudoPassword(host, sudoPass)
 			} else {
 				return nil
 			}
@@ -217,11 +266,16 @@ func SudoPasswordCheck(exec cmdexec.Executor, detached bool, sudoPass string) er
 	return nil
 }
 
+// sudo waits for a password on a prompt nobody answers when it is wrong
+const sudoCheckTimeout = 30 * time.Second
+
 func validateSudoPassword(exec cmdexec.Executor) string {
 
-	_ = pmk.CheckSudo(exec)
+	ctx, cancel := context.WithTimeout(context.Background(), sudoCheckTimeout)
+	defer cancel()
+	res, _ := exec.RunContext(ctx, "-l")
 	// Validate Sudo Password entered for Remote Host from stderr.
-	if strings.Contains(cmdexec.StdErrSudoPassword, util.InvalidPassword) {
+	if strings.Contains(res.Stderr, util.InvalidPassword) {
 		return util.Invalid
 	}
 	return util.Valid
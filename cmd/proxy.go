@@ -0,0 +1,232 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// proxyCmd groups the commands managing the proxy of the Platform9 services on a node
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Manage the proxy used by the Platform9 services of a node",
+	Long:  "Show, set or remove the proxy used by pf9-hostagent and pf9-comms to reach the Platform9 management plane",
+}
+
+var proxyShowCmd = &cobra.Command{
+	Use:     "show",
+	Short:   "Shows the proxy of a node",
+	Example: "pf9ctl proxy show --ip <nodeIP> -u <hostUser> -s <sshKey>",
+	Run:     proxyShowRun,
+}
+
+var proxyUnsetCmd = &cobra.Command{
+	Use:         "unset",
+	Short:       "Removes the proxy of a node",
+	Long:        "Removes the proxy from pf9-hostagent.env, comms_proxy_cfg.json and the containerd, docker and pf9-nodeletd drop-ins, and restarts the services",
+	Example:     "pf9ctl proxy unset --ip <nodeIP> -u <hostUser> -s <sshKey>",
+	Run:         proxyUnsetRun,
+	Annotations: dryRunSupported,
+}
+
+var proxySetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Puts a node behind a proxy",
+	Long: `Validates the proxy and checks the node reaches the Platform9 management plane
+	through it, then configures pf9-hostagent and pf9-comms to use it and restarts them.`,
+	Example:     "pf9ctl proxy set --protocol <http/https> --host-ip <proxyIP> --port <proxyPort> --proxy-user <proxyUsername> --proxy-password <proxyPassword> --no-proxy <comma seperated string>",
+	Run:         proxySetRun,
+	Annotations: dryRunSupported,
+}
+
+func init() {
+	proxySetCmd.Flags().StringVar(&proxySetting.Proxy.Protocol, "protocol", "", "Proxy protocol")
+	proxySetCmd.Flags().StringVar(&proxySetting.Proxy.Host, "host-ip", "", "Proxy IP")
+	proxySetCmd.Flags().StringVar(&proxySetting.Proxy.Port, "port", "", "Proxy port")
+	proxySetCmd.Flags().StringVar(&proxySetting.Proxy.User, "proxy-user", "", "Proxy username")
+	proxySetCmd.Flags().StringVar(&proxySetting.Proxy.Pass, "proxy-password", "", "Proxy password")
+	proxySetCmd.Flags().StringVar(&noProxyList, "no-proxy", "", "comma seperated list of IPs or domain names")
+	addRuntimeProxyFlags(proxySetCmd)
+	proxySetCmd.MarkFlagRequired("protocol")
+	proxySetCmd.MarkFlagRequired("host-ip")
+	proxySetCmd.MarkFlagRequired("port")
+
+	for _, cmd := range []*cobra.Command{proxyShowCmd, proxyUnsetCmd, proxySetCmd} {
+		//Remote node details
+		cmd.Flags().StringVarP(&nodeConfig.User, "host-user", "u", "", "ssh username for the node")
+		cmd.Flags().StringVarP(&nodeConfig.Password, "host-password", "p", "", "ssh password for the node (use 'single quotes' to pass password)")
+		cmd.Flags().StringVarP(&nodeConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+		cmd.Flags().StringSliceVarP(&nodeConfig.IPs, "ip", "i", []string{}, "ssh Ip of host")
+		addSSHFlags(cmd, &nodeConfig)
+		addInventoryFlags(cmd)
+		proxyCmd.AddCommand(cmd)
+	}
+	rootCmd.AddCommand(proxyCmd)
+}
+
+// loadProxyNodes selects the nodes the proxy command runs against
+func loadProxyNodes(cmd *cobra.Command) {
+	if _, err := loadInventory(&nodeConfig); err != nil {
+		zap.S().Fatalf("Unable to load the inventory: %s", err.Error())
+	}
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	if cmdexec.CheckRemote(nodeConfig) && inventoryHosts == nil {
+		if !config.ValidateNodeConfig(&nodeConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+}
+
+// loadProxyClient loads the context and logs in to the management plane
+func loadProxyClient(cmd *cobra.Command) (*objects.Config, client.Client, keystone.KeystoneAuth) {
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	cfg := &objects.Config{WaitPeriod: time.Duration(60), AllowInsecure: false, MfaToken: attachconfig.MFA}
+	var err error
+	if detachedMode {
+		err = config.LoadConfig(util.Pf9DBLoc, cfg, configNodeConfig(nodeConfig))
+	} else {
+		err = config.LoadConfigInteractive(util.Pf9DBLoc, cfg, configNodeConfig(nodeConfig))
+	}
+	if err != nil {
+		zap.S().Fatalf("Unable to load the context: %s\n", err.Error())
+	}
+
+	var executor cmdexec.Executor
+	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, objects.NodeConfig{}); err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+
+	var c client.Client
+	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
+		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+	}
+
+	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
+	if err != nil {
+		zap.S().Fatalf("Failed to get keystone %s", err.Error())
+	}
+	return cfg, c, auth
+}
+
+// runOnProxyNodes runs fn on each selected node, on more than one node they are
+// processed in parallel and a summary is printed
+func runOnProxyNodes(fn func(executor cmdexec.Executor, nc objects.NodeConfig) (string, error)) {
+	if len(nodeConfig.IPs) > 1 {
+		results := pmk.RunOnHosts(os.Stdout, nodeConfig.IPs, parallelism, func(host string, out io.Writer) (string, error) {
+			hostNc := hostNodeConfig(nodeConfig, host)
+			executor, err := cmdexec.GetExecutorForHost("", hostNc, host)
+			if err != nil {
+				return "", fmt.Errorf("Unable to create executor: %w", err)
+			}
+			defer executor.Close()
+			return fn(executor, hostNc)
+		})
+		printHostResults(os.Stdout, results)
+		return
+	}
+
+	executor, err := cmdexec.GetExecutor("", nodeConfig)
+	if err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	defer executor.Close()
+	summary, err := fn(executor, nodeConfig)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	fmt.Println(color.Green("✓ ") + summary)
+}
+
+func proxyShowRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running proxy show==========")
+	loadProxyNodes(cmd)
+
+	hosts := nodeConfig.IPs
+	if len(hosts) == 0 {
+		hosts = []string{"localhost"}
+	}
+	failed := 0
+	for i, host := range hosts {
+		hostNc := hostNodeConfig(nodeConfig, host)
+		if len(hosts) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", host)
+		}
+		executor, err := cmdexec.GetExecutorForHost("", hostNc, host)
+		if err != nil {
+			zap.S().Errorf("Unable to create executor for %s: %s", host, err.Error())
+			failed++
+			continue
+		}
+		proxy, err := pmk.ReadNodeProxy(executor)
+		executor.Close()
+		if err != nil {
+			zap.S().Errorf("Unable to read the proxy of %s: %s", host, err.Error())
+			failed++
+			continue
+		}
+		pmk.PrintNodeProxy(os.Stdout, proxy)
+	}
+	if failed > 0 {
+		zap.S().Fatalf("Failed on %d of %d host(s)", failed, len(hosts))
+	}
+	zap.S().Debug("==========Finished running proxy show==========")
+}
+
+func proxyUnsetRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running proxy unset==========")
+	loadProxyNodes(cmd)
+
+	runOnProxyNodes(func(executor cmdexec.Executor, nc objects.NodeConfig) (string, error) {
+		if err := pmk.UnsetNodeProxy(executor); err != nil {
+			return "", err
+		}
+		return "Proxy removed", nil
+	})
+	zap.S().Debug("==========Finished running proxy unset==========")
+}
+
+func proxySetRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running proxy set==========")
+	proxyURL, err := pmk.ProxyURL(proxySetting.Proxy)
+	if err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	loadProxyNodes(cmd)
+	cfg, c, auth := loadProxyClient(cmd)
+	defer c.Segment.Close()
+	defer c.Executor.Close()
+
+	runOnProxyNodes(func(executor cmdexec.Executor, nc objects.NodeConfig) (string, error) {
+		if err := pmk.CheckProxyReachesDU(executor, proxyURL, cfg.Fqdn); err != nil {
+			return "", err
+		}
+		if err := setHostProxy(executor); err != nil {
+			return "", err
+		}
+		if proxyRuntime {
+			if err := setHostRuntimeProxy(c, auth, executor); err != nil {
+				return "", err
+			}
+		}
+		return "Proxy set", nil
+	})
+	zap.S().Debug("==========Finished running proxy set==========")
+}
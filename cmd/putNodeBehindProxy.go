@@ -2,10 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
 
+	"github.com/platform9/pf9ctl/pkg/client"
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/keystone"
 	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/qbert"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
@@ -14,13 +20,17 @@ var (
 	proxySetting objects.ProxySetting
 	noProxy      = "localhost,127.0.0.1,::1,localhost.localdomain,localhost4,localhost6"
 	noProxyList  string
+	// proxyRuntime also puts containerd, docker and nodelet behind the proxy
+	proxyRuntime bool
+	proxyCluster string
 )
 
 var putNodeBehindProxycmd = &cobra.Command{
-	Use:     "set-proxy",
-	Short:   "Put existing pmk node behind proxy",
-	Example: "pf9ctl set-proxy --protocol <http/https> --host-ip <proxyIP> --port <proxyPort> --proxy-user <proxyUsername> --proxy-password <proxyPassword> --no-proxy <comma seperated string>",
-	Run:     putNodeBehindProxyRun,
+	Use:         "set-proxy",
+	Short:       "Put existing pmk node behind proxy",
+	Example:     "pf9ctl set-proxy --protocol <http/https> --host-ip <proxyIP> --port <proxyPort> --proxy-user <proxyUsername> --proxy-password <proxyPassword> --no-proxy <comma seperated string>",
+	Run:         putNodeBehindProxyRun,
+	Annotations: dryRunSupported,
 }
 
 func init() {
@@ -30,6 +40,7 @@ func init() {
 	putNodeBehindProxycmd.Flags().StringVar(&proxySetting.Proxy.User, "proxy-user", "", "Proxy username")
 	putNodeBehindProxycmd.Flags().StringVar(&proxySetting.Proxy.Pass, "proxy-password", "", "Proxy password")
 	putNodeBehindProxycmd.Flags().StringVar(&noProxyList, "no-proxy", "", "comma seperated list of IPs or domain names")
+	addRuntimeProxyFlags(putNodeBehindProxycmd)
 	putNodeBehindProxycmd.MarkFlagRequired("protocol")
 	putNodeBehindProxycmd.MarkFlagRequired("host-ip")
 	putNodeBehindProxycmd.MarkFlagRequired("port")
@@ -39,11 +50,125 @@ func init() {
 	putNodeBehindProxycmd.Flags().StringVarP(&nodeConfig.Password, "host-password", "p", "", "ssh password for the node (use 'single quotes' to pass password)")
 	putNodeBehindProxycmd.Flags().StringVarP(&nodeConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
 	putNodeBehindProxycmd.Flags().StringSliceVarP(&nodeConfig.IPs, "ip", "i", []string{}, "ssh Ip of host")
+	addSSHFlags(putNodeBehindProxycmd, &nodeConfig)
+	addInventoryFlags(putNodeBehindProxycmd)
 	rootCmd.AddCommand(putNodeBehindProxycmd)
 }
 
 func putNodeBehindProxyRun(cmd *cobra.Command, args []string) {
 	zap.S().Debugf("Setting proxy on host")
+
+	if _, err := loadInventory(&nodeConfig); err != nil {
+		zap.S().Fatalf("Unable to load the inventory: %s", err.Error())
+	}
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+
+	if cmdexec.CheckRemote(nodeConfig) && inventoryHosts == nil {
+		if !config.ValidateNodeConfig(&nodeConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	var c client.Client
+	var auth keystone.KeystoneAuth
+	if proxyRuntime {
+		_, c, auth = loadProxyClient(cmd)
+		defer c.Segment.Close()
+		defer c.Executor.Close()
+	}
+
+	if len(nodeConfig.IPs) > 1 {
+		results := pmk.RunOnHosts(os.Stdout, nodeConfig.IPs, parallelism, func(host string, out io.Writer) (string, error) {
+			hostNc := hostNodeConfig(nodeConfig, host)
+			executor, err := cmdexec.GetExecutorForHost("", hostNc, host)
+			if err != nil {
+				return "", fmt.Errorf("Unable to create executor: %w", err)
+			}
+			defer executor.Close()
+			if err := setHostProxy(executor); err != nil {
+				return "", err
+			}
+			if proxyRuntime {
+				if err := setHostRuntimeProxy(c, auth, executor); err != nil {
+					return "", err
+				}
+			}
+			return "proxy set", nil
+		})
+		printHostResults(os.Stdout, results)
+		return
+	}
+
+	executor, err := cmdexec.GetExecutor("", nodeConfig)
+	if err != nil {
+		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+	}
+	defer executor.Close()
+	if err := setHostProxy(executor); err != nil {
+		zap.S().Fatalf(err.Error())
+	}
+	if proxyRuntime {
+		if err := setHostRuntimeProxy(c, auth, executor); err != nil {
+			zap.S().Fatalf(err.Error())
+		}
+	}
+}
+
+// addRuntimeProxyFlags adds the flags putting the container runtimes and nodelet
+// behind the proxy
+func addRuntimeProxyFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&proxyRuntime, "container-runtime", false, "also set the proxy of containerd, docker and pf9-nodeletd")
+	cmd.Flags().StringVar(&proxyCluster, "cluster", "", "cluster whose pod and service CIDRs bypass the proxy of the container runtime (default the cluster of the node)")
+	cmd.Flags().StringVar(&attachconfig.MFA, "mfa", "", "MFA token")
+}
+
+// setHostRuntimeProxy puts containerd, docker and nodelet of a host behind the
+// proxy. The pod and service CIDRs of its cluster and its own IPs bypass it.
+func setHostRuntimeProxy(c client.Client, auth keystone.KeystoneAuth, executor cmdexec.Executor) error {
+	proxyURL, err := pmk.ProxyURL(proxySetting.Proxy)
+	if err != nil {
+		return err
+	}
+
+	var cluster *qbert.Cluster
+	if proxyCluster != "" {
+		clusters, err := c.Qbert.ListClusters(auth.ProjectID, auth.Token)
+		if err != nil {
+			return fmt.Errorf("Unable to list clusters: %w", err)
+		}
+		clusters = filterClustersByName(clusters, proxyCluster)
+		if len(clusters) == 0 {
+			return fmt.Errorf("Cluster %s not found", proxyCluster)
+		}
+		cluster = &clusters[0]
+	} else {
+		hostID, err := pmk.ReadHostID(executor)
+		if err != nil {
+			return err
+		}
+		if cluster, err = pmk.NodeCluster(c, auth, hostID); err != nil {
+			return err
+		}
+		if cluster == nil {
+			zap.S().Warnf("Host %s is not attached to a cluster, pass --cluster for its pod and service CIDRs to bypass the proxy", hostID)
+		}
+	}
+
+	ips, err := pmk.NodeIPs(executor)
+	if err != nil {
+		return err
+	}
+	bypass := noProxy
+	if noProxyList != "" {
+		bypass = noProxyList
+	}
+	return pmk.SetRuntimeProxy(executor, proxyURL, pmk.RuntimeNoProxy(bypass, cluster, ips))
+}
+
+// setHostProxy configures pf9-hostagent and pf9-comms of a host to use the proxy
+// and restarts them
+func setHostProxy(executor cmdexec.Executor) error {
 	var proxy_url string
 	if proxySetting.Proxy.User != "" && proxySetting.Proxy.Pass != "" {
 		proxy_url = fmt.Sprintf("%s://%s:%s@%s:%s", proxySetting.Proxy.Protocol, proxySetting.Proxy.User, proxySetting.Proxy.Pass, proxySetting.Proxy.Host, proxySetting.Proxy.Port)
@@ -51,8 +176,7 @@ func putNodeBehindProxyRun(cmd *cobra.Command, args []string) {
 		proxy_url = fmt.Sprintf("%s://%s:%s", proxySetting.Proxy.Protocol, proxySetting.Proxy.Host, proxySetting.Proxy.Port)
 	}
 
-	commsProxyFilePath := "/etc/pf9/comms_proxy_cfg.json"
-	hostAgentEnvFile := "/opt/pf9/hostagent/pf9-hostagent.env"
+	hostAgentEnvFile := pmk.HostAgentEnvFile
 
 	var envs = "http_proxy=" + proxy_url + "\n" +
 		"https_proxy=" + proxy_url + "\n" +
@@ -65,25 +189,12 @@ func putNodeBehindProxyRun(cmd *cobra.Command, args []string) {
 		envs = envs + "\n" + "no_proxy=" + noProxy + "\n" + "NO_PROXY=" + noProxy
 	}
 
-	detachedMode := cmd.Flags().Changed("no-prompt")
-
-	if cmdexec.CheckRemote(nodeConfig) {
-		if !config.ValidateNodeConfig(&nodeConfig, !detachedMode) {
-			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
-		}
-	}
-
-	executor, err := cmdexec.GetExecutor("", nodeConfig)
-	if err != nil {
-		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
-	}
-
 	//If node is already onboarded this /opt/pf9/hostagent/pf9-hostagent.env file will present bydefault
 	//Append pf9-hostagent proxy settings
 
 	//Handle rerun
 	cmnd := fmt.Sprintf("grep http_proxy %s", hostAgentEnvFile)
-	_, err = executor.RunWithStdout("bash", "-c", cmnd)
+	_, err := executor.RunWithStdout("bash", "-c", cmnd)
 	if err == nil {
 		//Remove existing proxy settings
 		zap.S().Debugf("Removing existing proxy envs")
@@ -91,13 +202,13 @@ func putNodeBehindProxyRun(cmd *cobra.Command, args []string) {
 		cmnd = fmt.Sprintf("grep -iv _proxy %s > %s.tmp", hostAgentEnvFile, hostAgentEnvFile)
 		_, err = executor.RunWithStdout("bash", "-c", cmnd)
 		if err != nil {
-			zap.S().Fatalf("Unable to remove existing proxy from %s ", hostAgentEnvFile)
+			return fmt.Errorf("Unable to remove existing proxy from %s", hostAgentEnvFile)
 		}
 		//Move temp file back to original file
 		cmnd = fmt.Sprintf("mv %s{.tmp,}", hostAgentEnvFile)
 		_, err = executor.RunWithStdout("bash", "-c", cmnd)
 		if err != nil {
-			zap.S().Fatalf("Failed while moving temp file back to original file %s ", hostAgentEnvFile)
+			return fmt.Errorf("Failed while moving temp file back to original file %s", hostAgentEnvFile)
 		}
 		//Remove temp file
 		cmnd = "rm -rf /opt/pf9/hostagent/pf9-hostagent.env.tmp"
@@ -110,7 +221,7 @@ func putNodeBehindProxyRun(cmd *cobra.Command, args []string) {
 	cmnd = fmt.Sprintf("ls %s", hostAgentEnvFile)
 	_, err = executor.RunWithStdout("bash", "-c", cmnd)
 	if err != nil {
-		zap.S().Fatalf("HostAgentEnv %s file is not present", hostAgentEnvFile)
+		return fmt.Errorf("HostAgentEnv %s file is not present", hostAgentEnvFile)
 	}
 
 	zap.S().Infof("Adding proxy setting to %s", hostAgentEnvFile)
@@ -120,71 +231,18 @@ EOT`, hostAgentEnvFile, envs)
 
 	_, err = executor.RunWithStdout("bash", "-c", cmnd)
 	if err != nil {
-		zap.S().Fatalf("Unable to add proxy setting to %s ", hostAgentEnvFile)
+		return fmt.Errorf("Unable to add proxy setting to %s", hostAgentEnvFile)
 	} else {
 		zap.S().Infof("pf9-hostagent proxy setting added to %s ", hostAgentEnvFile)
 	}
 
-	zap.S().Infof("Adding proxy setting to %s ", commsProxyFilePath)
 	//write pf9-comms proxy setting to /etc/pf9/comms_proxy_cfg.json
-	_, err = executor.RunWithStdout("bash", "-c", "touch /etc/pf9/comms_proxy_cfg.json")
-	if err != nil {
-		zap.S().Fatalf("Unable to create %s file", commsProxyFilePath)
-	}
-
-	var json string
-	if proxySetting.Proxy.User != "" && proxySetting.Proxy.Pass != "" && noProxyList != "" {
-		json = fmt.Sprintf(`{"http_proxy":{"protocol":"%s", "host":"%s", "port":%s, "user":"%s", "pass":"%s","no_proxy":"%s"}}`, proxySetting.Proxy.Protocol, proxySetting.Proxy.Host, proxySetting.Proxy.Port, proxySetting.Proxy.User, proxySetting.Proxy.Pass, noProxyList)
-	} else if noProxyList != "" {
-		json = fmt.Sprintf(`{"http_proxy":{"protocol":"%s", "host":"%s", "port":%s,"no_proxy":"%s"}}`, proxySetting.Proxy.Protocol, proxySetting.Proxy.Host, proxySetting.Proxy.Port, noProxyList)
-	} else {
-		json = fmt.Sprintf(`{"http_proxy":{"protocol":"%s", "host":"%s", "port":%s}}`, proxySetting.Proxy.Protocol, proxySetting.Proxy.Host, proxySetting.Proxy.Port)
-	}
-
-	isRemote := cmdexec.CheckRemote(nodeConfig)
-
-	if isRemote {
-		if proxySetting.Proxy.User != "" && proxySetting.Proxy.Pass != "" && noProxyList != "" {
-			json = fmt.Sprintf(`{\"http_proxy\":{\"protocol\":\"%s\", \"host\":\"%s\", \"port\":%s, \"user\":\"%s\", \"pass\":\"%s\", \"no_proxy\":\"%s\"}}`, proxySetting.Proxy.Protocol, proxySetting.Proxy.Host, proxySetting.Proxy.Port, proxySetting.Proxy.User, proxySetting.Proxy.Pass, noProxyList)
-		} else if noProxyList != "" {
-			json = fmt.Sprintf(`{\"http_proxy\":{\"protocol\":\"%s\", \"host\":\"%s\", \"port\":%s, \"no_proxy\":\"%s\"}}`, proxySetting.Proxy.Protocol, proxySetting.Proxy.Host, proxySetting.Proxy.Port, noProxyList)
-		} else {
-			json = fmt.Sprintf(`{\"http_proxy\":{\"protocol\":\"%s\", \"host\":\"%s\", \"port\":%s}}`, proxySetting.Proxy.Protocol, proxySetting.Proxy.Host, proxySetting.Proxy.Port)
-		}
-	}
-
-	cmnd = fmt.Sprintf(`echo '%s' 2>&1 | tee %s`, json, commsProxyFilePath)
-
-	_, err = executor.RunWithStdout("bash", "-c", cmnd)
-	if err != nil {
-		zap.S().Fatalf("Unable to add proxy setting to %s file", commsProxyFilePath)
-	} else {
-		zap.S().Infof("pf9-comms proxy settng added to %s ", commsProxyFilePath)
-	}
-
-	//change file ownership to pf9 group
-	cmnd = fmt.Sprintf("chown pf9:pf9group %s", commsProxyFilePath)
-	_, err = executor.RunWithStdout("bash", "-c", cmnd)
-	if err != nil {
-		zap.S().Errorf("Unable to change ownership of %s file", commsProxyFilePath)
-	} else {
-		zap.S().Infof("Changed ownership of %s file to pf9:pf9group", commsProxyFilePath)
+	commsProxy := proxySetting.Proxy
+	commsProxy.NoProxy = noProxyList
+	if err := pmk.SetCommsProxy(executor, commsProxy); err != nil {
+		return err
 	}
 
 	//Restart pf9 services
-	zap.S().Info("Restarting Platform9 services")
-	_, err = executor.RunWithStdout("bash", "-c", "systemctl restart pf9-hostagent")
-	if err != nil {
-		zap.S().Fatalf("Unable to restart pf9-hostagent")
-	} else {
-		zap.S().Infof("pf9-hostagent is restarted")
-	}
-
-	_, err = executor.RunWithStdout("bash", "-c", "systemctl restart pf9-comms")
-	if err != nil {
-		zap.S().Fatalf("Unable to restart pf9-comms")
-	} else {
-		zap.S().Infof("pf9-comms is restarted")
-	}
-
+	return pmk.RestartPf9Services(executor)
 }
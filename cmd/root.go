@@ -4,21 +4,57 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	//homedir "github.com/mitchellh/go-homedir"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/config"
 	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/pmk"
 	"github.com/platform9/pf9ctl/pkg/util"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var cfgFile string
 var verbosity bool
 var detach bool
 var logDirPath string
+var contextName string
+var outputFormat string
+var dryRun bool
+var commandTimeout time.Duration
+
+// dryRunSupported annotates the commands which can be run with --dry-run, the
+// ones changing hosts only through the executors.
+var dryRunSupported = map[string]string{"dry-run": "true"}
+
+// outputSupported annotates the commands which can be run with --output, the ones
+// writing a report to reportOut.
+var outputSupported = map[string]string{"output": "true"}
+
+// contextFallback annotates the commands managing the contexts, they fall back to
+// the default context when the one in use can't be resolved so it can be fixed.
+var contextFallback = map[string]string{"context-fallback": "true"}
+
+// reportOut is where the report of the commands supporting --output is written
+var reportOut io.Writer = os.Stdout
+
+// progressOut is where the commands supporting --output print their human readable
+// output, stderr when the report is machine readable so it can be piped.
+func progressOut() io.Writer {
+	if outputFormat != pmk.OutputText {
+		return os.Stderr
+	}
+	return os.Stdout
+}
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -31,8 +67,47 @@ var rootCmd = &cobra.Command{
 		if err := log.ConfigureGlobalLog(verbosity, util.Pf9Log); err != nil {
 			return fmt.Errorf("log initialization failed: %s", err)
 		}
+		// Point the config location at the context in use
+		loc, err := config.ResolveContext(contextName)
+		if err != nil {
+			if cmd.Annotations["context-fallback"] != "true" {
+				return fmt.Errorf("%w, switch to a valid context with %s use context <name>", err, util.ExeName)
+			}
+			zap.S().Warnf("%s, using the %s context", err, config.DefaultContext)
+			loc = config.ContextLoc(config.DefaultContext)
+		}
+		util.Pf9DBLoc = loc
+
+		if err := pmk.ValidateOutputFormat(outputFormat); err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("output") && cmd.Annotations["output"] != "true" {
+			return fmt.Errorf("--output is not supported by %s", cmd.CommandPath())
+		}
+
+		cmdexec.DefaultTimeout = commandTimeout
+
+		if dryRun {
+			if cmd.Annotations["dry-run"] != "true" {
+				return fmt.Errorf("--dry-run is not supported by %s", cmd.CommandPath())
+			}
+			cmdexec.DryRun = true
+			// Show the plan recorded so far when the command bails out
+			zap.ReplaceGlobals(zap.L().WithOptions(zap.Hooks(func(e zapcore.Entry) error {
+				if e.Level == zapcore.FatalLevel {
+					cmdexec.PrintPlan(os.Stderr)
+				}
+				return nil
+			})))
+		}
 		return nil
 	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if dryRun {
+			// Keep the machine readable reports of --output parseable
+			cmdexec.PrintPlan(progressOut())
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -43,6 +118,16 @@ func Execute() {
 		zap.S().Fatalf("Base directory initialization failed: %s\n", err.Error())
 	}
 
+	// Kill the commands running on the hosts on Ctrl-C rather than leave them behind
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Fprintln(os.Stderr, "\nInterrupted, stopping the running commands")
+		cmdexec.Interrupt(10 * time.Second)
+		os.Exit(130)
+	}()
+
 	if err := rootCmd.Execute(); err != nil {
 		zap.S().Fatalf(err.Error())
 	}
@@ -57,6 +142,10 @@ func initializeBaseDirs() (err error) {
 	if err != nil {
 		return
 	}
+	err = os.MkdirAll(util.Pf9ContextDir, 0700)
+	if err != nil {
+		return
+	}
 	err = os.MkdirAll(util.Pf9LogDir, 0700)
 	return
 }
@@ -67,6 +156,10 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&verbosity, "verbose", false, "print verbose logs")
 	rootCmd.PersistentFlags().BoolVar(&detach, "no-prompt", false, "disable all user prompts")
 	rootCmd.PersistentFlags().StringVar(&logDirPath, "log-dir", "", "path to save logs")
+	rootCmd.PersistentFlags().StringVar(&contextName, "context", "", "name of the config context to use")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", pmk.OutputText, "output format of check-node, get nodes and node status, one of text, json, yaml or junit (check-node only)")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "only print the commands which would change the hosts, supported by prep-node, check-node, decommission-node, node repair and set-proxy")
+	rootCmd.PersistentFlags().DurationVar(&commandTimeout, "command-timeout", 0, "kill the commands run on the hosts after this long, e.g. 10m, no limit by default")
 	//rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pf9ctl.yaml)")
 	//rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
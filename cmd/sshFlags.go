@@ -0,0 +1,17 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/ssh"
+	"github.com/spf13/cobra"
+)
+
+// addSSHFlags adds the flags controlling the ssh connections to the nodes
+func addSSHFlags(cmd *cobra.Command, nc *objects.NodeConfig) {
+	cmd.Flags().IntVar(&nc.SshPort, "ssh-port", 22, "ssh port of the nodes")
+	cmd.Flags().StringVar(&nc.JumpHost, "jump-host", "", "reach the nodes through a bastion, [user@]host[:port], several hosts can be chained with commas")
+	cmd.Flags().StringVar(&ssh.KnownHostsFile, "known-hosts", "", "known_hosts file used to verify the host keys of the nodes (default ~/.ssh/known_hosts)")
+	cmd.Flags().StringVar(&ssh.HostKeyCheck, "host-key-check", ssh.HostKeyTOFU, "host key verification, tofu records the keys of new hosts, strict refuses hosts missing from known_hosts")
+}
@@ -0,0 +1,107 @@
+// Copyright © 2020 The pf9ctl authors
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
+	"github.com/platform9/pf9ctl/pkg/log"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/pmk"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var (
+	bundleConfig objects.NodeConfig
+	bundleDir    string
+
+	supportBundleCmd = &cobra.Command{
+		Use:   "support-bundle",
+		Short: "Collects the logs and configuration of nodes for Platform9 support",
+		Long: `Create a timestamped tarball with the Platform9 logs, the hostagent, comms and nodelet
+	journals, /etc/pf9 with the secrets redacted, the pf9ctl logs, the OS release, the installed
+	packages and the output of read-only probes, like swapon --show, of the local node or of the nodes passed with --ip`,
+		Run: supportBundleRun,
+	}
+)
+
+func init() {
+	supportBundleCmd.Flags().StringVarP(&bundleConfig.User, "user", "u", "", "ssh username for the nodes")
+	supportBundleCmd.Flags().StringVarP(&bundleConfig.Password, "password", "p", "", "ssh password for the nodes (use 'single quotes' to pass password)")
+	supportBundleCmd.Flags().StringVarP(&bundleConfig.SshKey, "ssh-key", "s", "", "ssh key file for connecting to the nodes")
+	supportBundleCmd.Flags().StringSliceVarP(&bundleConfig.IPs, "ip", "i", []string{}, "IP address of the hosts to collect from (default localhost)")
+	supportBundleCmd.Flags().StringVarP(&bundleConfig.SudoPassword, "sudo-pass", "e", "", "sudo password for user on remote host")
+	supportBundleCmd.Flags().StringVar(&bundleDir, "dir", ".", "directory the support bundle is written to")
+	addSSHFlags(supportBundleCmd, &bundleConfig)
+
+	createCmd.AddCommand(supportBundleCmd)
+}
+
+func supportBundleRun(cmd *cobra.Command, args []string) {
+	zap.S().Debug("==========Running create support-bundle==========")
+
+	detachedMode := cmd.Flags().Changed("no-prompt")
+	isRemote := cmdexec.CheckRemote(bundleConfig)
+	if isRemote {
+		if !config.ValidateNodeConfig(&bundleConfig, !detachedMode) {
+			zap.S().Fatal("Invalid remote node config (Username/Password/IP), use 'single quotes' to pass password")
+		}
+	}
+
+	hosts := bundleConfig.IPs
+	if len(hosts) == 0 {
+		hosts = []string{"localhost"}
+	}
+
+	bundle, err := pmk.NewSupportBundle(bundleDir, time.Now())
+	if err != nil {
+		zap.S().Fatal(err.Error())
+	}
+
+	for _, host := range hosts {
+		if err := collectHostBundle(bundle, host, detachedMode); err != nil {
+			fmt.Println(color.Red("x ") + fmt.Sprintf("Unable to collect support bundle from %s: %s", host, err))
+			bundle.AddBytes(host+"/errors.txt", []byte(err.Error()+"\n"))
+			continue
+		}
+		fmt.Println(color.Green("✓ ") + "Collected support bundle from " + host)
+	}
+
+	if err := bundle.AddLogs(util.Pf9Log); err != nil {
+		zap.S().Debugf("Unable to add the pf9ctl logs: %s", err)
+	}
+	if err := bundle.Close(); err != nil {
+		os.Remove(bundle.Path)
+		zap.S().Fatalf("Unable to write support bundle: %s", err)
+	}
+	fmt.Println(color.Green("✓ ") + "Support bundle written to " + bundle.Path)
+	zap.S().Debug("==========Finished running create support-bundle==========")
+}
+
+func collectHostBundle(bundle *pmk.SupportBundle, host string, detachedMode bool) error {
+	executor, err := cmdexec.GetExecutorForHost("", bundleConfig, host)
+	if err != nil {
+		return fmt.Errorf("Unable to create executor: %w", err)
+	}
+	defer executor.Close()
+	// The tarball built on the host is read by the ssh user, or by pf9ctl itself
+	owner := strconv.Itoa(os.Getuid())
+	if _, ok := executor.(*cmdexec.RemoteExecutor); ok {
+		if err := SudoPasswordCheck(executor, detachedMode, host, bundleConfig.SudoPassword); err != nil {
+			return fmt.Errorf("Failed executing commands with sudo: %w", err)
+		}
+		owner = bundleConfig.User
+	}
+	if err := bundle.AddHost(host, owner, executor); err != nil {
+		return fmt.Errorf("%w, see %s", err, log.GetLogLocation(util.Pf9Log))
+	}
+	return nil
+}
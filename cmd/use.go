@@ -3,30 +3,46 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/config"
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 // useCmd represents the use command
 var useCmd = &cobra.Command{
 	Use:   "use",
 	Short: "Use a specific config",
-	// Run: func(cmd *cobra.Command, args []string) {
-	// 	log.Info("Use called")
-	// },
 }
 
-/*
-This initialization of use command to root isnot in use for now.
-func init() {
-	//rootCmd.AddCommand(useCmd)
-
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// useCmd.PersistentFlags().String("foo", "", "A help for foo")
+// useContextCmd switches the context used by the other commands
+var useContextCmd = &cobra.Command{
+	Use:   "context <name>",
+	Short: "Switch to a stored context",
+	Long: `Make the named context the one used by all the commands. A context is
+	created with the config set command and the --context flag.`,
+	Args: func(useContextCmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Context name is required")
+		}
+		return nil
+	},
+	Example:     "pf9ctl use context prod",
+	Annotations: contextFallback,
+	Run: func(cmd *cobra.Command, args []string) {
+		zap.S().Debug("==========Running use context==========")
+		if err := config.UseContext(args[0]); err != nil {
+			zap.S().Fatal(color.Red("x "), err)
+		}
+		fmt.Println(color.Green("✓ ") + "Switched to context " + args[0])
+		zap.S().Debug("==========Finished running use context==========")
+	},
+}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// useCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-}*/
+func init() {
+	rootCmd.AddCommand(useCmd)
+	useCmd.AddCommand(useContextCmd)
+}
@@ -3,7 +3,10 @@ package client
 
 import (
 	"crypto/tls"
+	"io"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/platform9/pf9ctl/pkg/cmdexec"
@@ -24,14 +27,21 @@ type Client struct {
 	Qbert    qbert.Qbert
 	Executor cmdexec.Executor
 	Segment  Segment
+	// Out is where progress for the host is printed, stdout when not set
+	Out io.Writer
 }
 
+var allowInsecureOnce sync.Once
+
 // New creates the clients needed by the CLI
 // to interact with the external services.
 func NewClient(fqdn string, executor cmdexec.Executor, allowInsecure bool, noTracking bool) (Client, error) {
 	// Bring the hammer down to make default http allow insecure
+	// once, clients for several hosts are created in parallel
 	if allowInsecure {
-		http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		allowInsecureOnce.Do(func() {
+			http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		})
 	}
 	return Client{
 		Resmgr:   resmgr.NewResmgr(fqdn, HTTPMaxRetry, HTTPRetryMinWait, HTTPRetryMaxWait, allowInsecure),
@@ -41,3 +51,11 @@ func NewClient(fqdn string, executor cmdexec.Executor, allowInsecure bool, noTra
 		Segment:  NewSegment(fqdn, noTracking),
 	}, nil
 }
+
+// Output returns the writer used for printing progress
+func (c Client) Output() io.Writer {
+	if c.Out == nil {
+		return os.Stdout
+	}
+	return c.Out
+}
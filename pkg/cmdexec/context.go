@@ -0,0 +1,74 @@
+package cmdexec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of a command run with RunContext
+type Result struct {
+	Stdout string
+	Stderr string
+	// ExitCode is -1 when the command did not exit on its own, it was killed
+	// or could not be started
+	ExitCode int
+	Duration time.Duration
+}
+
+// DefaultTimeout bounds the commands which are run without a deadline, there is
+// no limit when it is zero.
+var DefaultTimeout time.Duration
+
+// Time given to a cancelled local command to exit after SIGTERM before it is killed
+var killDelay = 5 * time.Second
+
+var (
+	interrupted, interrupt = context.WithCancel(context.Background())
+	running                sync.WaitGroup
+)
+
+// Interrupt cancels the commands being run, killing their processes and remote
+// sessions, and waits up to grace for them to return. Commands run afterwards
+// are cancelled straight away.
+func Interrupt(grace time.Duration) {
+	interrupt()
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(grace):
+	}
+}
+
+// commandContext returns the context to run a command with, it is also cancelled
+// by Interrupt and bounded by DefaultTimeout when ctx has no deadline.
+func commandContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	var cancels []context.CancelFunc
+	if _, ok := ctx.Deadline(); !ok && DefaultTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		cancels = append(cancels, cancel)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(interrupted, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
+}
+
+// contextError explains why a command was stopped, the error wraps ctx.Err()
+func contextError(ctx context.Context, cmd string) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("command %s timed out: %w", cmd, ctx.Err())
+	}
+	return fmt.Errorf("command %s was cancelled: %w", cmd, ctx.Err())
+}
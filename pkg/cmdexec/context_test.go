@@ -0,0 +1,130 @@
+package cmdexec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// hangingClient is an ssh.Client whose commands only return once cancelled,
+// like a stuck package manager.
+type hangingClient struct {
+	stdout, stderr string
+	cancelled      chan string
+}
+
+func (h *hangingClient) RunCommand(cmd string) ([]byte, []byte, error) {
+	return h.RunCommandContext(context.Background(), cmd)
+}
+
+func (h *hangingClient) RunCommandContext(ctx context.Context, cmd string) ([]byte, []byte, error) {
+	if h.cancelled == nil {
+		return []byte(h.stdout), []byte(h.stderr), nil
+	}
+	<-ctx.Done()
+	h.cancelled <- cmd
+	return []byte(h.stdout), nil, ctx.Err()
+}
+
+func (h *hangingClient) UploadFile(string, string, os.FileMode, func(int64, int64)) error {
+	return nil
+}
+
+func (h *hangingClient) DownloadFile(string, string, os.FileMode, func(int64, int64)) error {
+	return nil
+}
+
+func (h *hangingClient) Close() error {
+	return nil
+}
+
+func TestRemoteExecutorRunContext(t *testing.T) {
+	exec := &RemoteExecutor{Client: &hangingClient{stdout: "out", stderr: "Sorry, try again."}}
+	res, err := exec.RunContext(context.Background(), "-l")
+	assert.Nil(t, err)
+	assert.Equal(t, "out", res.Stdout)
+	assert.Equal(t, "Sorry, try again.", res.Stderr)
+	assert.Equal(t, 0, res.ExitCode)
+
+	client := &hangingClient{stdout: "partial", cancelled: make(chan string, 1)}
+	exec = &RemoteExecutor{Client: client}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	res, err = exec.RunContext(ctx, "yum", "-y", "install", "curl")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.ErrorContains(t, err, "timed out")
+	assert.Equal(t, `yum "-y" "install" "curl"`, <-client.cancelled)
+	assert.Equal(t, "partial", res.Stdout)
+	assert.Equal(t, -1, res.ExitCode)
+	assert.True(t, res.Duration >= 10*time.Millisecond)
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	DefaultTimeout = 10 * time.Millisecond
+	defer func() { DefaultTimeout = 0 }()
+
+	client := &hangingClient{cancelled: make(chan string, 1)}
+	exec := &RemoteExecutor{Client: client}
+	_, err := exec.RunWithStdout("apt", "update")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	<-client.cancelled
+}
+
+func TestLocalRunWithProgressStagesTimeout(t *testing.T) {
+	// sudo runs the command as is
+	bin := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(bin, "sudo"), []byte("#!/bin/sh\nexec \"$@\"\n"), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	DefaultTimeout = 100 * time.Millisecond
+	defer func() { DefaultTimeout = 0 }()
+
+	start := time.Now()
+	_, err := LocalExecutor{}.RunWithProgressStages("sleep", "10")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.ErrorContains(t, err, "timed out")
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestInterrupt(t *testing.T) {
+	defer func() {
+		interrupted, interrupt = context.WithCancel(context.Background())
+	}()
+
+	client := &hangingClient{cancelled: make(chan string, 1)}
+	exec := &RemoteExecutor{Client: client}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- exec.Run("apt", "update")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	Interrupt(time.Second)
+
+	err := <-errs
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.ErrorContains(t, err, "was cancelled")
+	assert.Equal(t, `apt "update"`, <-client.cancelled)
+}
+
+func TestDryRunExecutorRunContext(t *testing.T) {
+	ResetPlan()
+	defer ResetPlan()
+	mock := &MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			return "active\n", nil
+		},
+	}
+	exec := DryRunExecutor{Executor: mock, Host: "10.0.0.1"}
+
+	res, err := exec.RunContext(context.Background(), "bash", "-c", "systemctl is-active pf9-hostagent")
+	assert.Nil(t, err)
+	assert.Equal(t, Result{Stdout: "active\n", Duration: res.Duration}, res)
+	res, err = exec.RunContext(context.Background(), "bash", "-c", "systemctl restart pf9-hostagent")
+	assert.Nil(t, err)
+	assert.Equal(t, Result{}, res)
+	assert.Equal(t, []PlannedStep{{"10.0.0.1", `bash -c "systemctl restart pf9-hostagent"`}}, Plan())
+}
@@ -0,0 +1,396 @@
+package cmdexec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// DryRun makes GetExecutor and GetExecutorForHost return executors which run the
+// read-only probes but only record the commands which would change the host.
+var DryRun bool
+
+// PlannedStep is a change a dry run would have made
+type PlannedStep struct {
+	Host string
+	Step string
+}
+
+var plan struct {
+	sync.Mutex
+	steps []PlannedStep
+}
+
+func addStep(host, step string) {
+	plan.Lock()
+	defer plan.Unlock()
+	plan.steps = append(plan.steps, PlannedStep{Host: host, Step: step})
+}
+
+// Plan returns the steps recorded so far
+func Plan() []PlannedStep {
+	plan.Lock()
+	defer plan.Unlock()
+	return append([]PlannedStep{}, plan.steps...)
+}
+
+// ResetPlan forgets the steps recorded so far
+func ResetPlan() {
+	plan.Lock()
+	defer plan.Unlock()
+	plan.steps = nil
+}
+
+// PrintPlan writes the steps recorded so far grouped by host, in the order
+// the hosts were first changed.
+func PrintPlan(w io.Writer) {
+	steps := Plan()
+	if len(steps) == 0 {
+		fmt.Fprintln(w, "\nDry run: no change would be made")
+		return
+	}
+	fmt.Fprintln(w, "\nDry run: nothing was changed, the plan is")
+	var hosts []string
+	byHost := map[string][]string{}
+	for _, s := range steps {
+		if _, ok := byHost[s.Host]; !ok {
+			hosts = append(hosts, s.Host)
+		}
+		byHost[s.Host] = append(byHost[s.Host], s.Step)
+	}
+	for _, host := range hosts {
+		fmt.Fprintf(w, "%s:\n", host)
+		for i, step := range byHost[host] {
+			fmt.Fprintf(w, "  %d. %s\n", i+1, step)
+		}
+	}
+}
+
+// DryRunExecutor runs the read-only probes with the wrapped executor and records
+// the other commands in the plan, reporting them as successful.
+type DryRunExecutor struct {
+	Executor Executor
+	Host     string
+}
+
+func (d DryRunExecutor) Run(name string, args ...string) error {
+	_, err := d.RunWithStdout(name, args...)
+	return err
+}
+
+func (d DryRunExecutor) RunWithStdout(name string, args ...string) (string, error) {
+	if IsReadOnly(name, args...) {
+		return d.Executor.RunWithStdout(name, args...)
+	}
+	d.record(name, args...)
+	return "", nil
+}
+
+func (d DryRunExecutor) RunCommandWait(command string) string {
+	if IsReadOnly(command) {
+		return d.Executor.RunCommandWait(command)
+	}
+	d.record(command)
+	return ""
+}
+
+func (d DryRunExecutor) RunWithProgressStages(name string, args ...string) (string, error) {
+	if IsReadOnly(name, args...) {
+		return d.Executor.RunWithProgressStages(name, args...)
+	}
+	d.record(name, args...)
+	return "", nil
+}
+
+func (d DryRunExecutor) RunContext(ctx context.Context, name string, args ...string) (Result, error) {
+	if IsReadOnly(name, args...) {
+		return d.Executor.RunContext(ctx, name, args...)
+	}
+	d.record(name, args...)
+	return Result{}, nil
+}
+
+// Close closes the wrapped executor
+func (d DryRunExecutor) Close() error {
+	return d.Executor.Close()
+}
+
+// Record adds a step to the plan for the host of the executor, it is used for
+// the changes made through the Platform9 APIs during a dry run.
+func (d DryRunExecutor) Record(step string) {
+	addStep(d.Host, step)
+}
+
+func (d DryRunExecutor) record(name string, args ...string) {
+	cmd := name
+	for _, arg := range args {
+		if strings.ContainsAny(arg, " \t\n'\"|;&<>$`*") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+		cmd += " " + arg
+	}
+	// Avoid confidential info in the command from getting printed
+	cmd = ConfidentialInfoRemover(cmd)
+	zap.S().Debug("Dry run, not running ", cmd)
+	d.Record(cmd)
+}
+
+// Commands which do not change the host whatever their arguments
+var readOnlyCommands = map[string]bool{
+	"cat": true, "grep": true, "egrep": true, "cut": true, "tr": true,
+	"head": true, "tail": true, "wc": true, "sort": true, "uniq": true,
+	"ls": true, "stat": true, "test": true, "[": true, "which": true, "type": true,
+	"id": true, "whoami": true, "uname": true, "nproc": true, "free": true, "df": true,
+	"lsblk": true, "lscpu": true, "ps": true, "pgrep": true, "pidof": true, "lsof": true,
+	"ss": true, "netstat": true, "getent": true, "getconf": true,
+	"true": true, "false": true, "echo": true, "printf": true, "printenv": true,
+	"basename": true, "dirname": true, "readlink": true, "realpath": true, "pwd": true,
+	"md5sum": true, "sha256sum": true, "dpkg-query": true, "apt-cache": true,
+	"ping": true, "nslookup": true, "dig": true, "host": true,
+}
+
+// Arguments making a command otherwise read-only change the host. awk programs
+// may write through system(), > or |, and program files can't be checked at all.
+var mutatingArgs = map[string]*regexp.Regexp{
+	"sed":         regexp.MustCompile(`^(-[a-zA-Z]*i|--in-place)`),
+	"find":        regexp.MustCompile(`^-(delete|exec|ok|fprint|fls)`),
+	"curl":        regexp.MustCompile(`^(-[a-zA-Z]*[oOTdXF]|--(output|remote-name|upload-file|data|request|form))`),
+	"ip":          regexp.MustCompile(`^(add|del|delete|set|flush|change|replace|append)$`),
+	"timedatectl": regexp.MustCompile(`^set-`),
+	"hostname":    regexp.MustCompile(`^[^-]`),
+	"journalctl":  regexp.MustCompile(`^--(vacuum|rotate|flush)`),
+	"date":        regexp.MustCompile(`^(-[a-zA-Z]*s|--set)`),
+	"awk":         regexp.MustCompile(`^-[a-zA-Z]*[fi]|^--(file|include)|system|[>|]`),
+}
+
+// Short options of sudo followed by a value, like -u root
+const sudoValueOptions = "CDcghprtTUu"
+
+// Subcommands which only query the state of the host
+var readOnlySubcommands = map[string]map[string]bool{
+	"systemctl": {"is-active": true, "is-enabled": true, "is-failed": true, "status": true, "show": true, "cat": true, "list-units": true, "list-unit-files": true},
+	"dpkg":      {"-s": true, "-l": true, "-L": true, "--status": true, "--list": true, "--listfiles": true, "--print-architecture": true},
+	"rpm":       {"-q": true, "-qa": true, "-qi": true, "-ql": true, "--query": true},
+	"yum":       {"list": true, "repolist": true, "info": true},
+	"apt":       {"list": true, "show": true, "policy": true},
+	"docker":    {"ps": true, "images": true, "info": true, "version": true, "inspect": true},
+	"crictl":    {"ps": true, "images": true, "info": true, "version": true, "inspect": true},
+	"swapon":    {"--show": true, "-s": true, "--summary": true},
+}
+
+// IsReadOnly reports if a command, as passed to an Executor, only queries the host.
+// Commands which are not known to be read-only are considered to change the host.
+func IsReadOnly(name string, args ...string) bool {
+	if (name == "bash" || name == "sh") && len(args) == 2 && args[0] == "-c" {
+		return isReadOnlyScript(args[1])
+	}
+	// The executors run the commands with sudo
+	if len(args) == 0 {
+		// A remote executor runs its command through the shell
+		return isReadOnlyScript("sudo " + name)
+	}
+	return isReadOnlyWords(append([]string{"sudo", name}, args...))
+}
+
+func isReadOnlyScript(script string) bool {
+	commands, ok := splitScript(script)
+	if !ok {
+		return false
+	}
+	for _, words := range commands {
+		if !isReadOnlyWords(words) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitScript splits a shell script in the words of its simple commands, the
+// commands of pipelines and lists. It reports false when the script writes to a
+// file or substitutes commands, except for read-only commands substituted in
+// arithmetic expansions, which are replaced by a number.
+func splitScript(script string) ([][]string, bool) {
+	var commands [][]string
+	var words []string
+	var word strings.Builder
+	endWord := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			commands = append(commands, words)
+			words = nil
+		}
+	}
+
+	for i := 0; i < len(script); i++ {
+		ch := script[i]
+		switch {
+		case ch == '\\' && i+1 < len(script):
+			i++
+			word.WriteByte(script[i])
+		case ch == '\'':
+			end := strings.IndexByte(script[i+1:], '\'')
+			if end < 0 {
+				return nil, false
+			}
+			word.WriteString(script[i+1 : i+1+end])
+			i += end + 1
+		case ch == '"':
+			end := strings.IndexByte(script[i+1:], '"')
+			if end < 0 {
+				return nil, false
+			}
+			quoted := script[i+1 : i+1+end]
+			if strings.Contains(quoted, "$(") || strings.Contains(quoted, "`") {
+				return nil, false
+			}
+			word.WriteString(quoted)
+			i += end + 1
+		case strings.HasPrefix(script[i:], "$(("):
+			end := matchParen(script, i+1)
+			if end < 0 || !isReadOnlyArithmetic(script[i+3:end-1]) {
+				return nil, false
+			}
+			word.WriteByte('0')
+			i = end
+		case strings.HasPrefix(script[i:], "$(") || ch == '`':
+			return nil, false
+		case ch == '>':
+			// Only discarding or merging output is allowed
+			target := strings.TrimLeft(script[i+1:], "> \t")
+			switch {
+			case strings.HasPrefix(target, "/dev/null") && (len(target) == len("/dev/null") || strings.IndexByte(" \t\n|;&)", target[len("/dev/null")]) >= 0):
+				i = len(script) - len(target) + len("/dev/null") - 1
+			case len(target) > 1 && target[0] == '&' && target[1] >= '0' && target[1] <= '9':
+				i = len(script) - len(target) + 1
+			default:
+				return nil, false
+			}
+			// Drop the file descriptor of the redirection
+			if s := word.String(); s != "" && strings.Trim(s, "0123456789&") == "" {
+				word.Reset()
+			}
+		case strings.IndexByte("|;&\n()", ch) >= 0:
+			endCommand()
+		case ch == ' ' || ch == '\t':
+			endWord()
+		default:
+			word.WriteByte(ch)
+		}
+	}
+	endCommand()
+	return commands, true
+}
+
+// matchParen returns the index of the parenthesis closing the one at open, or -1
+func matchParen(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isReadOnlyArithmetic reports if the command substitutions of an arithmetic
+// expression are read-only.
+func isReadOnlyArithmetic(expr string) bool {
+	if strings.ContainsRune(expr, '`') {
+		return false
+	}
+	for {
+		start := strings.Index(expr, "$(")
+		if start < 0 {
+			return true
+		}
+		end := matchParen(expr, start+1)
+		if end < 0 || !isReadOnlyScript(expr[start+2:end]) {
+			return false
+		}
+		expr = expr[end+1:]
+	}
+}
+
+// skipSudoOptions returns the words following the options of sudo, and false
+// when the options edit files.
+func skipSudoOptions(words []string) ([]string, bool) {
+	for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+		opt := words[0]
+		words = words[1:]
+		if opt == "--" {
+			break
+		}
+		if strings.HasPrefix(opt, "--") {
+			if opt == "--edit" {
+				return nil, false
+			}
+			continue
+		}
+		for i := 1; i < len(opt); i++ {
+			if opt[i] == 'e' {
+				return nil, false
+			}
+			if strings.IndexByte(sudoValueOptions, opt[i]) >= 0 {
+				// The value is the rest of the option or the next word
+				if i == len(opt)-1 && len(words) > 0 {
+					words = words[1:]
+				}
+				break
+			}
+		}
+	}
+	return words, true
+}
+
+func isReadOnlyWords(words []string) bool {
+	// Skip environment assignments, sudo and its options and the braces of groups
+	for len(words) > 0 && (words[0] == "sudo" || words[0] == "{" || words[0] == "}" || words[0] == "!" || strings.Contains(words[0], "=")) {
+		if words[0] == "sudo" {
+			var ok bool
+			if words, ok = skipSudoOptions(words[1:]); !ok {
+				return false
+			}
+			continue
+		}
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return true
+	}
+	cmd, args := words[0], words[1:]
+	if cmd == "xargs" {
+		return len(args) == 0
+	}
+	if re, ok := mutatingArgs[cmd]; ok {
+		for _, arg := range args {
+			if re.MatchString(arg) {
+				return false
+			}
+		}
+		return true
+	}
+	if readOnlyCommands[cmd] {
+		return true
+	}
+	if subcommands, ok := readOnlySubcommands[cmd]; ok {
+		return len(args) > 0 && subcommands[args[0]]
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package cmdexec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsReadOnly(t *testing.T) {
+	cases := map[string]struct {
+		name     string
+		args     []string
+		readOnly bool
+	}{
+		"os release":          {"cat", []string{"/etc/os-release"}, true},
+		"pipeline":            {"bash", []string{"-c", "cat /etc/*os-release | grep -i pretty_name | cut -d ' ' -f 2"}, true},
+		"arithmetic":          {"bash", []string{"-c", "echo $(($(getconf _PHYS_PAGES) * $(getconf _PAGE_SIZE) / (1024 * 1024)))"}, true},
+		"discarded output":    {"bash", []string{"-c", "lsof /var/lib/dpkg/lock 2>&1 >/dev/null"}, true},
+		"service status":      {"bash", []string{"-c", "systemctl is-active firewalld"}, true},
+		"package status":      {"bash", []string{"-c", "dpkg -s pf9-hostagent"}, true},
+		"sudo check":          {"-l | grep '(ALL) PASSWD: ALL'", nil, true},
+		"host id":             {"bash", []string{"-c", "grep host_id /etc/pf9/host_id.conf | cut -d '=' -f2"}, true},
+		"xargs trim":          {"bash", []string{"-c", "df -k / --output=size | sed 1d | xargs | tr -d '\\n'"}, true},
+		"group":               {"bash", []string{"-c", "dpkg -l | { grep -i 'pf9-kube' || true; }"}, true},
+		"quoted separators":   {"bash", []string{"-c", "grep 'a; rm -rf /' /tmp/file"}, true},
+		"awk print":           {"bash", []string{"-c", "netstat -tupna | awk '{print $4}' | sed -e 's/.*://' | sort | uniq"}, true},
+		"date":                {"bash", []string{"-c", "date -u +%s"}, true},
+		"swapoff":             {"bash", []string{"-c", "swapoff -a"}, false},
+		"sed in place":        {"sed", []string{"-E", "-i.bak", "s/a/b/", "/etc/fstab"}, false},
+		"service restart":     {"bash", []string{"-c", "systemctl restart pf9-hostagent"}, false},
+		"package install":     {"bash", []string{"-c", "apt install -qq -y curl"}, false},
+		"quiet yum install":   {"bash", []string{"-c", "yum -q -y install curl"}, false},
+		"download":            {"bash", []string{"-c", "curl --silent https://du/installer.sh -o /home/u/pf9/installer.sh"}, false},
+		"write to file":       {"bash", []string{"-c", "grep -iv _proxy /opt/pf9/env > /opt/pf9/env.tmp"}, false},
+		"tee":                 {"bash", []string{"-c", "echo '{}' 2>&1 | tee /etc/pf9/comms_proxy_cfg.json"}, false},
+		"after a probe":       {"bash", []string{"-c", "cat /etc/hosts && rm -rf /etc/pf9"}, false},
+		"awk system":          {"bash", []string{"-c", "awk 'BEGIN { system(\"reboot\") }'"}, false},
+		"awk write":           {"awk", []string{"{ print > \"/etc/hosts\" }", "/tmp/hosts"}, false},
+		"awk program file":    {"awk", []string{"-f", "/tmp/prog.awk"}, false},
+		"set the clock":       {"bash", []string{"-c", "date -s '2020-01-01 00:00'"}, false},
+		"sudo user":           {"sudo", []string{"-u", "root", "cat", "/etc/hosts"}, true},
+		"sudo list":           {"sudo", []string{"-l"}, true},
+		"sudo user rm":        {"sudo", []string{"-u", "root", "rm", "-rf", "/x"}, false},
+		"script sudo user rm": {"bash", []string{"-c", "sudo -u root rm -rf /x"}, false},
+		"sudo edit":           {"bash", []string{"-c", "sudo -e /etc/hosts"}, false},
+		"leading number":      {"bash", []string{"-c", "10 rm"}, false},
+		"leading option":      {"bash", []string{"-c", "-l rm"}, false},
+		"leading operator":    {"bash", []string{"-c", "* rm"}, false},
+		"substituted command": {"bash", []string{"-c", "$(echo rm) -rf /"}, false},
+		"backquoted command":  {"bash", []string{"-c", "`echo rm` -rf /"}, false},
+		"substituted arg":     {"bash", []string{"-c", "cat $(echo /etc/hosts)"}, false},
+		"arithmetic rm":       {"bash", []string{"-c", "echo $(($(rm -rf /x) + 1))"}, false},
+		"arithmetic command":  {"bash", []string{"-c", "$((1 + 1)) rm"}, false},
+		"sed quiet in place":  {"sed", []string{"-ni", "s/a/b/p", "/etc/fstab"}, false},
+		"find fls":            {"find", []string{"/", "-fls", "/tmp/list"}, false},
+		"find fprintf":        {"find", []string{"/", "-fprintf", "/tmp/list", "%p"}, false},
+		"append to null":      {"bash", []string{"-c", "cat /etc/hosts >> /dev/null"}, true},
+		"null prefix":         {"bash", []string{"-c", "cat x >> /dev/nullfoo"}, false},
+		"substitution":        {"sudo pkill -9 `pidof kubelet`", nil, false},
+		"quoted substitution": {"bash", []string{"-c", "echo \"$(rm -rf /tmp/x)\""}, false},
+		"unknown command":     {"mkdir", []string{"-p", "/home/u/pf9"}, false},
+		"script path":         {"bash", []string{"-c", "/home/u/pf9/installer.sh --no-proxy"}, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.readOnly, IsReadOnly(tc.name, tc.args...))
+		})
+	}
+}
+
+func TestDryRunExecutor(t *testing.T) {
+	ResetPlan()
+	defer ResetPlan()
+	var ran []string
+	mock := &MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			ran = append(ran, args[len(args)-1])
+			return "inactive\n", nil
+		},
+	}
+	exec := DryRunExecutor{Executor: mock, Host: "10.0.0.1"}
+
+	out, err := exec.RunWithStdout("bash", "-c", "systemctl is-active unattended-upgrades")
+	assert.Nil(t, err)
+	assert.Equal(t, "inactive\n", out)
+	out, err = exec.RunWithStdout("bash", "-c", "systemctl stop unattended-upgrades")
+	assert.Nil(t, err)
+	assert.Equal(t, "", out)
+	assert.Nil(t, exec.Run("bash", "-c", "installer.sh --password=secret --no-proxy"))
+	DryRunExecutor{Executor: mock, Host: "10.0.0.2"}.Record("deauthorize host h2")
+	exec.Record("authorize host h1 with the pf9-kube role")
+
+	assert.Equal(t, []string{"systemctl is-active unattended-upgrades"}, ran)
+	assert.Equal(t, []PlannedStep{
+		{"10.0.0.1", `bash -c "systemctl stop unattended-upgrades"`},
+		{"10.0.0.1", `bash -c "installer.sh --password='*****' --no-proxy"`},
+		{"10.0.0.2", "deauthorize host h2"},
+		{"10.0.0.1", "authorize host h1 with the pf9-kube role"},
+	}, Plan())
+
+	buf := &bytes.Buffer{}
+	PrintPlan(buf)
+	assert.Equal(t, `
+Dry run: nothing was changed, the plan is
+10.0.0.1:
+  1. bash -c "systemctl stop unattended-upgrades"
+  2. bash -c "installer.sh --password='*****' --no-proxy"
+  3. authorize host h1 with the pf9-kube role
+10.0.0.2:
+  1. deauthorize host h2
+`, buf.String())
+}
+
+func TestDryRunExecutorClose(t *testing.T) {
+	closed := false
+	d := DryRunExecutor{Executor: &MockExecutor{MockClose: func() error {
+		closed = true
+		return nil
+	}}}
+	assert.Nil(t, d.Close())
+	assert.True(t, closed)
+}
+
+func TestGetExecutorDryRun(t *testing.T) {
+	DryRun = true
+	defer func() { DryRun = false }()
+
+	executor, err := GetExecutor("", objects.NodeConfig{})
+	assert.Nil(t, err)
+	assert.Equal(t, DryRunExecutor{Executor: LocalExecutor{}, Host: "localhost"}, executor)
+}
@@ -3,12 +3,15 @@ package cmdexec
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -20,9 +23,6 @@ import (
 	"go.uber.org/zap"
 )
 
-// To fetch the stderr after executing command
-var StdErrSudoPassword string
-
 const (
 	httpsProxy = "https_proxy"
 	env_path   = "PATH"
@@ -34,6 +34,11 @@ type Executor interface {
 	RunWithStdout(name string, args ...string) (string, error)
 	RunCommandWait(command string) string
 	RunWithProgressStages(name string, args ...string) (string, error)
+	// RunContext runs a command until it exits or ctx is done, whichever comes
+	// first, the command is killed in the latter case.
+	RunContext(ctx context.Context, name string, args ...string) (Result, error)
+	// Close releases the connections to the host, the executor is not used afterwards
+	Close() error
 }
 
 // LocalExecutor as the name implies executes commands locally
@@ -64,36 +69,43 @@ func (r *RemoteExecutor) RunCommandWait(command string) string {
 
 // Run runs a command locally returning just success or failure
 func (c LocalExecutor) Run(name string, args ...string) error {
-	if c.ProxyUrl != "" {
-		args = append([]string{httpsProxy + "=" + c.ProxyUrl, name}, args...)
-	} else {
-		args = append([]string{name}, args...)
-	}
-	cmd := exec.Command("sudo", args...)
-	cmd.Env = append(cmd.Env, httpsProxy+"="+c.ProxyUrl)
-	cmd.Env = append(cmd.Env, env_path+"="+os.Getenv("PATH"))
-	return cmd.Run()
+	_, err := c.RunContext(context.Background(), name, args...)
+	return err
 }
 
 // RunWithStdout runs a command locally returning stdout and err
 func (c LocalExecutor) RunWithStdout(name string, args ...string) (string, error) {
-	if c.ProxyUrl != "" {
-		args = append([]string{httpsProxy + "=" + c.ProxyUrl, name}, args...)
-	} else {
-		args = append([]string{name}, args...)
+	res, err := c.RunContext(context.Background(), name, args...)
+	return res.Stdout, err
+}
+
+// RunContext runs a command locally, it is sent SIGTERM when ctx is done, which
+// sudo relays to the command, and killed if it is still running after killDelay.
+func (c LocalExecutor) RunContext(ctx context.Context, name string, args ...string) (Result, error) {
+	running.Add(1)
+	defer running.Done()
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
+	cmd := c.command(ctx, name, args...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = killDelay
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	start := time.Now()
+	err := cmd.Run()
+	res := Result{Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: -1, Duration: time.Since(start)}
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
 	}
-	cmd := exec.Command("sudo", args...)
-	cmd.Env = append(cmd.Env, httpsProxy+"="+c.ProxyUrl)
-	cmd.Env = append(cmd.Env, env_path+"="+os.Getenv("PATH"))
-	byt, err := cmd.Output()
-	stderr := ""
 	if exitError, ok := err.(*exec.ExitError); ok {
-		stderr = string(exitError.Stderr)
+		// Keep the stderr for ExitCodeChecker like cmd.Output() does
+		exitError.Stderr = stderr.Bytes()
 	}
 
 	// To append args to a single command
 	command := ""
-	for _, arg := range args {
+	for _, arg := range cmd.Args[1:] {
 		command = fmt.Sprintf("%s \"%s\"", command, arg)
 	}
 
@@ -101,20 +113,41 @@ func (c LocalExecutor) RunWithStdout(name string, args ...string) (string, error
 	command = ConfidentialInfoRemover(command)
 	zap.S().Debug("Ran command sudo", command)
 
-	zap.S().Debug("stdout:", string(byt), "stderr:", stderr)
-	return string(byt), err
+	zap.S().Debug("stdout:", res.Stdout, "stderr:", res.Stderr, "exit code:", res.ExitCode, "duration:", res.Duration)
+	if ctx.Err() != nil {
+		return res, contextError(ctx, "sudo"+command)
+	}
+	return res, err
+}
+
+// Close does nothing, no connection is kept to run commands locally
+func (c LocalExecutor) Close() error {
+	return nil
 }
 
-// RunWithProgressBar runs a command locally displaying the progress status along with stdout and err
-func (c LocalExecutor) RunWithProgressStages(name string, args ...string) (string, error) {
+// command returns the sudo command running name with the proxy of the executor
+func (c LocalExecutor) command(ctx context.Context, name string, args ...string) *exec.Cmd {
 	if c.ProxyUrl != "" {
 		args = append([]string{httpsProxy + "=" + c.ProxyUrl, name}, args...)
 	} else {
 		args = append([]string{name}, args...)
 	}
-	cmd := exec.Command("sudo", args...)
+	cmd := exec.CommandContext(ctx, "sudo", args...)
 	cmd.Env = append(cmd.Env, httpsProxy+"="+c.ProxyUrl)
 	cmd.Env = append(cmd.Env, env_path+"="+os.Getenv("PATH"))
+	return cmd
+}
+
+// RunWithProgressBar runs a command locally displaying the progress status along with stdout and err
+func (c LocalExecutor) RunWithProgressStages(name string, args ...string) (string, error) {
+	running.Add(1)
+	defer running.Done()
+	ctx, cancel := commandContext(context.Background())
+	defer cancel()
+	cmd := c.command(ctx, name, args...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = killDelay
+	args = cmd.Args[1:]
 
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -123,7 +156,7 @@ func (c LocalExecutor) RunWithProgressStages(name string, args ...string) (strin
 	var stdout string = ""
 
 	stdoutScanner := bufio.NewScanner(stdoutPipe)
-	
+
 	// Configurations for progressbar
 	bar := progressbar.NewOptions(100,
 		progressbar.OptionSetDescription("Downloading Hostagent...	"),
@@ -148,8 +181,8 @@ func (c LocalExecutor) RunWithProgressStages(name string, args ...string) (strin
 	s.Color("red")
 
 	//pre-install checks
-	s.Start()
 	s.Suffix = "Setting Up Required Components..."
+	s.Start()
 	for stdoutScanner.Scan() {
 		outputLine := stdoutScanner.Text()
 		stdout += outputLine
@@ -162,7 +195,9 @@ func (c LocalExecutor) RunWithProgressStages(name string, args ...string) (strin
 
 	//Hostagent Download progressbar incrementation(Fake progress)
 	var wg sync.WaitGroup
-	quit := make(chan bool)
+	// Buffered, the fake progress may already have stopped with the command
+	quit := make(chan bool, 1)
+	exited := make(chan struct{})
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -170,6 +205,8 @@ func (c LocalExecutor) RunWithProgressStages(name string, args ...string) (strin
 			select {
 			case <-quit:
 				return
+			case <-exited:
+				return
 			default:
 				if currentProgress < nextStageProgress-1 {
 					currentProgress++
@@ -203,8 +240,10 @@ func (c LocalExecutor) RunWithProgressStages(name string, args ...string) (strin
 		}
 
 		//Check for Hostagent installation
-		s.Start()
+		s.Lock()
 		s.Suffix = "Installing Platform9 hostagent..."
+		s.Unlock()
+		s.Start()
 		for stdoutScanner.Scan() {
 			outputLine := stdoutScanner.Text()
 			if strings.Contains(outputLine, "post_install_routine executed successfully") {
@@ -222,15 +261,22 @@ func (c LocalExecutor) RunWithProgressStages(name string, args ...string) (strin
 	command = ConfidentialInfoRemover(command)
 	zap.S().Debug("Ran command sudo", command)
 
-	if err := cmd.Wait(); err != nil {
+	err = cmd.Wait()
+	close(exited)
+	wg.Wait()
+	s.Stop()
+
+	if ctx.Err() != nil {
+		zap.S().Debug("stdout:", stdout, "stderr:")
+		return stdout, contextError(ctx, "sudo"+command)
+	}
+	if err != nil {
 		zap.S().Debug("stdout:", stdout, "stderr:")
 		zap.S().Errorf("Error: %s", err.Error())
 		fmt.Println(color.Red("x "), "Package installation failed")
 		return stdout, err
 	}
 
-	wg.Wait()
-
 	zap.S().Debug("stdout:", stdout, "stderr:")
 	return stdout, nil
 }
@@ -249,6 +295,18 @@ func (r *RemoteExecutor) Run(name string, args ...string) error {
 
 // RunWithStdout runs a command locally returning stdout and err
 func (r *RemoteExecutor) RunWithStdout(name string, args ...string) (string, error) {
+	res, err := r.RunContext(context.Background(), name, args...)
+	return res.Stdout, err
+}
+
+// RunContext runs a command on the remote host, the remote session is killed
+// when ctx is done.
+func (r *RemoteExecutor) RunContext(ctx context.Context, name string, args ...string) (Result, error) {
+	running.Add(1)
+	defer running.Done()
+	ctx, cancel := commandContext(ctx)
+	defer cancel()
+
 	cmd := name
 	for _, arg := range args {
 		cmd = fmt.Sprintf("%s \"%s\"", cmd, arg)
@@ -256,15 +314,23 @@ func (r *RemoteExecutor) RunWithStdout(name string, args ...string) (string, err
 	if r.proxyURL != "" {
 		cmd = fmt.Sprintf("%s=%s %s", httpsProxy, r.proxyURL, cmd)
 	}
-	stdout, stderr, err := r.Client.RunCommand(cmd)
-	// To fetch the stderr after executing command.
-	StdErrSudoPassword = string(stderr)
+	start := time.Now()
+	stdout, stderr, err := r.Client.RunCommandContext(ctx, cmd)
+	res := Result{Stdout: string(stdout), Stderr: string(stderr), ExitCode: ssh.ExitStatus(err), Duration: time.Since(start)}
 
 	// Avoid confidential info in the command from getting logged
 	command := ConfidentialInfoRemover(cmd)
 
-	zap.S().Debug("Running command ", command, "stdout:", string(stdout), "stderr:", string(stderr))
-	return string(stdout), err
+	zap.S().Debug("Running command ", command, "stdout:", res.Stdout, "stderr:", res.Stderr, "exit code:", res.ExitCode, "duration:", res.Duration)
+	if ctx.Err() != nil {
+		return res, contextError(ctx, command)
+	}
+	return res, err
+}
+
+// Close closes the connection to the remote host and the jump hosts on the way
+func (r *RemoteExecutor) Close() error {
+	return r.Client.Close()
 }
 
 // RunWithProgressBar runs a command remote host displaying the progress status along with stdout
@@ -275,8 +341,8 @@ func (r *RemoteExecutor) RunWithProgressStages(name string, args ...string) (str
 }
 
 // NewRemoteExecutor create an Executor interface to execute commands remotely
-func NewRemoteExecutor(host string, port int, username string, privateKey []byte, password, proxyURL string) (Executor, error) {
-	client, err := ssh.NewClient(host, port, username, privateKey, password, proxyURL)
+func NewRemoteExecutor(host string, port int, username string, privateKey []byte, password, proxyURL, jumpHost string) (Executor, error) {
+	client, err := ssh.NewClient(host, port, username, privateKey, password, proxyURL, jumpHost)
 	if err != nil {
 		return nil, err
 	}
@@ -309,18 +375,48 @@ func ConfidentialInfoRemover(cmd string) string {
 
 func GetExecutor(proxyURL string, nc objects.NodeConfig) (Executor, error) {
 	if CheckRemote(nc) {
-		var pKey []byte
-		var err error
-		if nc.SshKey != "" {
-			pKey, err = ioutil.ReadFile(nc.SshKey)
-			if err != nil {
-				zap.S().Fatalf("Unable to read the sshKey %s, %s", nc.SshKey, err.Error())
-			}
-		}
-		return NewRemoteExecutor(nc.IPs[0], 22, nc.User, pKey, nc.Password, proxyURL)
+		return newRemoteExecutorForHost(proxyURL, nc, nc.IPs[0])
 	}
 	zap.S().Debug("Using local executor")
-	return LocalExecutor{ProxyUrl: proxyURL}, nil
+	return dryRunIfEnabled(LocalExecutor{ProxyUrl: proxyURL}, "localhost"), nil
+}
+
+// GetExecutorForHost returns an executor for one of the hosts of the node config
+func GetExecutorForHost(proxyURL string, nc objects.NodeConfig, host string) (Executor, error) {
+	if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+		zap.S().Debug("Using local executor")
+		return dryRunIfEnabled(LocalExecutor{ProxyUrl: proxyURL}, "localhost"), nil
+	}
+	return newRemoteExecutorForHost(proxyURL, nc, host)
+}
+
+// dryRunIfEnabled wraps the executor in a DryRunExecutor when DryRun is set
+func dryRunIfEnabled(exec Executor, host string) Executor {
+	if !DryRun {
+		return exec
+	}
+	zap.S().Debugf("Dry run, only recording the commands changing %s", host)
+	return DryRunExecutor{Executor: exec, Host: host}
+}
+
+func newRemoteExecutorForHost(proxyURL string, nc objects.NodeConfig, host string) (Executor, error) {
+	var pKey []byte
+	var err error
+	if nc.SshKey != "" {
+		pKey, err = ioutil.ReadFile(nc.SshKey)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the sshKey %s, %s", nc.SshKey, err.Error())
+		}
+	}
+	port := nc.SshPort
+	if port == 0 {
+		port = 22
+	}
+	exec, err := NewRemoteExecutor(host, port, nc.User, pKey, nc.Password, proxyURL, nc.JumpHost)
+	if err != nil {
+		return nil, err
+	}
+	return dryRunIfEnabled(exec, host), nil
 }
 
 func CheckRemote(nc objects.NodeConfig) bool {
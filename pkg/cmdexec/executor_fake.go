@@ -1,5 +1,10 @@
 package cmdexec
 
+import (
+	"context"
+	"time"
+)
+
 var _ Executor = (*MockExecutor)(nil)
 
 type MockExecutor struct {
@@ -7,6 +12,8 @@ type MockExecutor struct {
 	MockRunWithStdout         func(name string, args ...string) (string, error)
 	MockRunCommandWait        func(name string) string
 	MockRunWithProgressStages func(name string, args ...string) (string, error)
+	MockRunContext            func(ctx context.Context, name string, args ...string) (Result, error)
+	MockClose                 func() error
 }
 
 func (m *MockExecutor) Run(name string, args ...string) error {
@@ -18,9 +25,31 @@ func (m *MockExecutor) RunWithStdout(name string, args ...string) (string, error
 }
 
 func (m *MockExecutor) RunCommandWait(name string) string {
-	return m.RunCommandWait(name)
+	return m.MockRunCommandWait(name)
 }
 
 func (m *MockExecutor) RunWithProgressStages(name string, args ...string) (string, error) {
 	return m.MockRunWithProgressStages(name, args...)
-}
\ No newline at end of file
+}
+
+// Close uses MockClose when it is set
+func (m *MockExecutor) Close() error {
+	if m.MockClose != nil {
+		return m.MockClose()
+	}
+	return nil
+}
+
+// RunContext uses MockRunContext when it is set and MockRunWithStdout otherwise
+func (m *MockExecutor) RunContext(ctx context.Context, name string, args ...string) (Result, error) {
+	if m.MockRunContext != nil {
+		return m.MockRunContext(ctx, name, args...)
+	}
+	start := time.Now()
+	stdout, err := m.MockRunWithStdout(name, args...)
+	res := Result{Stdout: stdout, Duration: time.Since(start)}
+	if err != nil {
+		res.ExitCode = 1
+	}
+	return res, err
+}
@@ -0,0 +1,61 @@
+package cmdexec
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// WriteFile replaces file on the host of the executor with data, owned by owner,
+// as user:group, with the mode. The data is installed next to file and renamed
+// over it, so readers never see a partial file. It is uploaded through sftp first
+// on a remote host, no shell ever sees the data.
+func WriteFile(exec Executor, file string, data []byte, mode os.FileMode, owner string) error {
+	user, group, ok := strings.Cut(owner, ":")
+	if !ok || user == "" || group == "" {
+		return fmt.Errorf("Invalid owner %q of %s, expected user:group", owner, file)
+	}
+	if d, ok := exec.(DryRunExecutor); ok {
+		d.Record(fmt.Sprintf("write %s owned by %s with mode %04o", file, owner, mode))
+		return nil
+	}
+
+	local, err := os.CreateTemp("", "pf9ctl-")
+	if err != nil {
+		return fmt.Errorf("Unable to create a temporary file: %w", err)
+	}
+	defer os.Remove(local.Name())
+	_, err = local.Write(data)
+	if closeErr := local.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to write %s: %w", local.Name(), err)
+	}
+
+	src := local.Name()
+	if remote, ok := exec.(*RemoteExecutor); ok {
+		src = path.Join("/tmp", path.Base(local.Name()))
+		if err := remote.Client.UploadFile(local.Name(), src, 0600, nil); err != nil {
+			return fmt.Errorf("Unable to upload %s: %w", file, err)
+		}
+		defer func() {
+			if _, err := exec.RunWithStdout("rm", "-f", src); err != nil {
+				zap.S().Debugf("File %s not removed: %s", src, err.Error())
+			}
+		}()
+	}
+
+	tmp := file + ".pf9ctl.tmp"
+	if _, err := exec.RunWithStdout("install", "-m", fmt.Sprintf("%04o", mode), "-o", user, "-g", group, src, tmp); err != nil {
+		return fmt.Errorf("Unable to write %s: %w", file, err)
+	}
+	if _, err := exec.RunWithStdout("mv", "-f", tmp, file); err != nil {
+		exec.RunWithStdout("rm", "-f", tmp)
+		return fmt.Errorf("Unable to replace %s: %w", file, err)
+	}
+	return nil
+}
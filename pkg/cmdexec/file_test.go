@@ -0,0 +1,78 @@
+package cmdexec
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// uploadClient is an ssh.Client recording the commands run and the files uploaded
+type uploadClient struct {
+	hangingClient
+	ran      []string
+	uploaded map[string]string
+}
+
+func (u *uploadClient) RunCommandContext(ctx context.Context, cmd string) ([]byte, []byte, error) {
+	u.ran = append(u.ran, cmd)
+	return nil, nil, nil
+}
+
+func (u *uploadClient) UploadFile(src, dst string, mode os.FileMode, cb func(int64, int64)) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	u.uploaded[dst] = string(data)
+	return nil
+}
+
+func TestWriteFile(t *testing.T) {
+	data := []byte(`{"pass":"a'b\"c$d"}`)
+
+	var ran []string
+	var installed string
+	local := &MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			ran = append(ran, strings.Join(append([]string{name}, args...), " "))
+			if name == "install" {
+				content, err := os.ReadFile(args[len(args)-2])
+				assert.Nil(t, err)
+				installed = string(content)
+			}
+			return "", nil
+		},
+	}
+	assert.Nil(t, WriteFile(local, "/etc/pf9/file.json", data, 0640, "pf9:pf9group"))
+	assert.Equal(t, string(data), installed)
+	assert.Len(t, ran, 2)
+	assert.Regexp(t, `^install -m 0640 -o pf9 -g pf9group \S+ /etc/pf9/file.json.pf9ctl.tmp$`, ran[0])
+	assert.Equal(t, "mv -f /etc/pf9/file.json.pf9ctl.tmp /etc/pf9/file.json", ran[1])
+
+	client := &uploadClient{uploaded: map[string]string{}}
+	remote := &RemoteExecutor{Client: client}
+	assert.Nil(t, WriteFile(remote, "/etc/pf9/file.json", data, 0600, "root:root"))
+	assert.Len(t, client.uploaded, 1)
+	for src, content := range client.uploaded {
+		assert.True(t, strings.HasPrefix(src, "/tmp/pf9ctl-"))
+		assert.Equal(t, string(data), content)
+		assert.Equal(t, []string{
+			`install "-m" "0600" "-o" "root" "-g" "root" "` + src + `" "/etc/pf9/file.json.pf9ctl.tmp"`,
+			`mv "-f" "/etc/pf9/file.json.pf9ctl.tmp" "/etc/pf9/file.json"`,
+			`rm "-f" "` + src + `"`,
+		}, client.ran)
+	}
+
+	ResetPlan()
+	defer ResetPlan()
+	dryRun := DryRunExecutor{Executor: local, Host: "10.0.0.1"}
+	ran = nil
+	assert.Nil(t, WriteFile(dryRun, "/etc/pf9/file.json", data, 0640, "pf9:pf9group"))
+	assert.Empty(t, ran)
+	assert.Equal(t, []PlannedStep{{Host: "10.0.0.1", Step: "write /etc/pf9/file.json owned by pf9:pf9group with mode 0640"}}, Plan())
+
+	assert.ErrorContains(t, WriteFile(local, "/etc/pf9/file.json", data, 0640, "pf9"), "expected user:group")
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/keystone"
 	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/ssh"
 
 	"github.com/jinzhu/copier"
 	"github.com/platform9/pf9ctl/pkg/util"
@@ -30,30 +31,41 @@ var (
 	MAX_ATTEMPTS_ERROR       = errors.New("Invalid credentials entered multiple times (Platform9 Account URL/Username/Password/Region/Tenant/Proxy URL/MFA Token)")
 )
 
-// StoreConfig simply updates the in-memory object
+// StoreConfig saves the config, the password goes to the secret store and
+// the config only keeps a reference to it.
 func StoreConfig(cfg *objects.Config, loc string) error {
 	zap.S().Debug("Storing configuration details")
 
+	if err := writeConfig(cfg, loc); err != nil {
+		return err
+	}
+	fmt.Println(color.Green("✓ ") + "Stored configuration details successfully")
+	return nil
+}
+
+func writeConfig(cfg *objects.Config, loc string) error {
 	var cfgCopy objects.Config
 
 	copier.CopyWithOption(&cfgCopy, cfg, copier.Option{IgnoreEmpty: true, DeepCopy: true})
 
-	// obscure the password
-	cfgCopy.Password = base64.StdEncoding.EncodeToString([]byte(cfg.Password))
+	ref, err := StoreSecret(secretKey(cfg.Fqdn, cfg.Username), cfg.Password)
+	if err != nil {
+		return err
+	}
+	cfgCopy.Password = ""
+	cfgCopy.SecretRef = ref
 
 	// Clear the MFA token as it will be required afresh every time
 	cfgCopy.MfaToken = ""
 
-	f, err := os.Create(loc)
+	f, err := os.OpenFile(loc, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return err
 	}
 
 	defer f.Close()
 
-	encoder := json.NewEncoder(f)
-	fmt.Println(color.Green("✓ ") + "Stored configuration details successfully")
-	return encoder.Encode(cfgCopy)
+	return json.NewEncoder(f).Encode(cfgCopy)
 }
 
 // LoadConfig returns the information for communication with PF9 controller.
@@ -76,14 +88,19 @@ func LoadConfig(loc string, cfg *objects.Config, nc objects.NodeConfig) error {
 
 	var fileConfig objects.Config
 	err = json.NewDecoder(f).Decode(&fileConfig)
-	// decode the password
-	// Decoding base64 encoded password
-	decodedBytePassword, err := base64.StdEncoding.DecodeString(fileConfig.Password)
-	if err != nil {
-		return err
+	if fileConfig.SecretRef != "" {
+		if fileConfig.Password, err = LoadSecret(fileConfig.SecretRef); err != nil {
+			return err
+		}
+	} else if fileConfig.Password != "" {
+		// Configs stored by older versions hold the base64 encoded password
+		decodedBytePassword, err := base64.StdEncoding.DecodeString(fileConfig.Password)
+		if err != nil {
+			return err
+		}
+		fileConfig.Password = string(decodedBytePassword)
+		migrateConfig(&fileConfig, loc)
 	}
-	fileConfig.Password = string(decodedBytePassword)
-	//s.Stop()
 
 	copier.CopyWithOption(cfg, &fileConfig, copier.Option{IgnoreEmpty: true})
 
@@ -94,6 +111,16 @@ func LoadConfig(loc string, cfg *objects.Config, nc objects.NodeConfig) error {
 	return ValidateUserCredentials(cfg, nc)
 }
 
+// migrateConfig moves the password of an old config to the secret store, the
+// config keeps working from memory if that fails.
+func migrateConfig(cfg *objects.Config, loc string) {
+	if err := writeConfig(cfg, loc); err != nil {
+		zap.S().Warnf("Unable to move the stored password to the secret store: %s", err.Error())
+		return
+	}
+	fmt.Println(color.Green("✓ ") + "Moved the stored password to the secret store")
+}
+
 func LoadConfigInteractive(loc string, cfg *objects.Config, nc objects.NodeConfig) error {
 
 	err := LoadConfig(loc, cfg, nc)
@@ -168,10 +195,11 @@ func ValidateUserCredentials(cfg *objects.Config, nc objects.NodeConfig) error {
 	}
 
 	c, err := createClient(cfg, nc)
-	defer c.Segment.Close()
 	if err != nil {
 		return fmt.Errorf("Error validating credentials %w", err)
 	}
+	defer c.Segment.Close()
+	defer c.Executor.Close()
 
 	auth, err := c.Keystone.GetAuth(
 		cfg.Username,
@@ -361,9 +389,8 @@ func ConfigCmdCreateRun(cfg *objects.Config) error {
 func createClient(cfg *objects.Config, nc objects.NodeConfig) (client.Client, error) {
 	executor, err := cmdexec.GetExecutor(cfg.ProxyURL, nc)
 	if err != nil {
-		//debug first since Fatalf calls os.Exit
 		zap.S().Debug("Error connecting to host %s", err.Error())
-		zap.S().Fatalf(" Invalid (Username/Password/IP), use 'single quotes' to pass password")
+		return client.Client{}, fmt.Errorf("Invalid (Username/Password/IP), use 'single quotes' to pass password: %w", err)
 	}
 
 	return client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false)
@@ -377,7 +404,9 @@ func clearContext(v interface{}) {
 
 func ValidateNodeConfig(nc *objects.NodeConfig, interactive bool) bool {
 
-	if nc.User == "" || (nc.SshKey == "" && nc.Password == "") {
+	// Keys loaded in ssh-agent can be used instead of a password or key file
+	noCredentials := nc.SshKey == "" && nc.Password == "" && !ssh.AgentAvailable()
+	if nc.User == "" || noCredentials {
 		if !interactive {
 			return false
 		}
@@ -388,7 +417,7 @@ func ValidateNodeConfig(nc *objects.NodeConfig, interactive bool) bool {
 			nc.User, _ = reader.ReadString('\n')
 			nc.User = strings.TrimSpace(nc.User)
 		}
-		if nc.SshKey == "" && nc.Password == "" {
+		if noCredentials {
 			var choice int
 			fmt.Println("You can choose either password or sshKey")
 			fmt.Println("Enter 1 for password and 2 for sshKey")
@@ -405,7 +434,8 @@ func ValidateNodeConfig(nc *objects.NodeConfig, interactive bool) bool {
 				nc.SshKey, _ = reader.ReadString('\n')
 				nc.SshKey = strings.TrimSpace(nc.SshKey)
 			default:
-				zap.S().Fatalf("Wrong choice please try again")
+				fmt.Println("Wrong choice please try again")
+				return false
 			}
 			fmt.Printf("\n")
 		}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// DefaultContext is the context stored in the original config.json
+const DefaultContext = "default"
+
+var (
+	INVALID_CONTEXT_NAME = errors.New("Invalid context name, only letters, digits, '.', '_' and '-' are allowed")
+
+	contextNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+// ValidateContextName makes sure the name can be used as a file name
+func ValidateContextName(name string) error {
+	if !contextNameRe.MatchString(name) {
+		return INVALID_CONTEXT_NAME
+	}
+	return nil
+}
+
+// ContextLoc returns the location of the config file of the named context
+func ContextLoc(name string) string {
+	if name == DefaultContext {
+		return filepath.Join(util.Pf9DBDir, "config.json")
+	}
+	return filepath.Join(util.Pf9ContextDir, name+".json")
+}
+
+// ContextExists reports if a config has been stored for the named context
+func ContextExists(name string) bool {
+	_, err := os.Stat(ContextLoc(name))
+	return err == nil
+}
+
+// CurrentContext returns the name of the context in use
+func CurrentContext() (string, error) {
+	byt, err := os.ReadFile(util.Pf9CurrentContextLoc)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultContext, nil
+		}
+		return "", err
+	}
+	name := strings.TrimSpace(string(byt))
+	if name == "" {
+		return DefaultContext, nil
+	}
+	return name, nil
+}
+
+// UseContext makes the named context the one in use
+func UseContext(name string) error {
+	if err := ValidateContextName(name); err != nil {
+		return err
+	}
+	if !ContextExists(name) {
+		return fmt.Errorf("Context %s not found, create it with `%s config set --context %s`", name, util.ExeName, name)
+	}
+	return os.WriteFile(util.Pf9CurrentContextLoc, []byte(name+"\n"), 0600)
+}
+
+// ListContexts returns the names of all the stored contexts
+func ListContexts() ([]string, error) {
+	var names []string
+	if ContextExists(DefaultContext) {
+		names = append(names, DefaultContext)
+	}
+
+	entries, err := os.ReadDir(util.Pf9ContextDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if entry.IsDir() || name == entry.Name() || name == DefaultContext || ValidateContextName(name) != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ResolveContext returns the config file location for the named context,
+// the context in use is resolved when name is empty.
+func ResolveContext(name string) (string, error) {
+	var err error
+	if name == "" {
+		if name, err = CurrentContext(); err != nil {
+			return "", fmt.Errorf("Unable to read current context: %w", err)
+		}
+		if err = ValidateContextName(name); err != nil {
+			return "", fmt.Errorf("Invalid current context in %s: %w", util.Pf9CurrentContextLoc, err)
+		}
+	} else if err = ValidateContextName(name); err != nil {
+		return "", err
+	}
+	zap.S().Debugf("Using context %s", name)
+	return ContextLoc(name), nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDBDirs(t *testing.T) {
+	dbDir, contextDir, currentLoc, secretsLoc := util.Pf9DBDir, util.Pf9ContextDir, util.Pf9CurrentContextLoc, util.Pf9SecretsLoc
+	t.Cleanup(func() {
+		util.Pf9DBDir, util.Pf9ContextDir, util.Pf9CurrentContextLoc, util.Pf9SecretsLoc = dbDir, contextDir, currentLoc, secretsLoc
+	})
+
+	util.Pf9DBDir = t.TempDir()
+	util.Pf9ContextDir = filepath.Join(util.Pf9DBDir, "contexts")
+	util.Pf9CurrentContextLoc = filepath.Join(util.Pf9DBDir, "current-context")
+	util.Pf9SecretsLoc = filepath.Join(util.Pf9DBDir, "secrets.age")
+	assert.Nil(t, os.MkdirAll(util.Pf9ContextDir, 0700))
+}
+
+func TestContexts(t *testing.T) {
+	setupDBDirs(t)
+
+	// Nothing stored yet, the default context is in use
+	current, err := CurrentContext()
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultContext, current)
+	assert.NotNil(t, UseContext("prod"))
+
+	assert.Nil(t, os.WriteFile(ContextLoc(DefaultContext), []byte("{}"), 0600))
+	assert.Nil(t, os.WriteFile(ContextLoc("prod"), []byte("{}"), 0600))
+	assert.Nil(t, os.WriteFile(ContextLoc("dev"), []byte("{}"), 0600))
+
+	names, err := ListContexts()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"default", "dev", "prod"}, names)
+
+	assert.Nil(t, UseContext("prod"))
+	current, err = CurrentContext()
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", current)
+
+	loc, err := ResolveContext("")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(util.Pf9ContextDir, "prod.json"), loc)
+
+	loc, err = ResolveContext(DefaultContext)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(util.Pf9DBDir, "config.json"), loc)
+
+	// A malformed current context does not prevent naming a context
+	assert.Nil(t, os.WriteFile(util.Pf9CurrentContextLoc, []byte("../prod\n"), 0600))
+	_, err = ResolveContext("")
+	assert.ErrorIs(t, err, INVALID_CONTEXT_NAME)
+	assert.ErrorContains(t, err, util.Pf9CurrentContextLoc)
+	loc, err = ResolveContext("dev")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(util.Pf9ContextDir, "dev.json"), loc)
+}
+
+func TestValidateContextName(t *testing.T) {
+	cases := map[string]struct {
+		name string
+		err  error
+	}{
+		"Valid":      {name: "prod-us.east_1", err: nil},
+		"Empty":      {name: "", err: INVALID_CONTEXT_NAME},
+		"Path":       {name: "../prod", err: INVALID_CONTEXT_NAME},
+		"LeadingDot": {name: ".prod", err: INVALID_CONTEXT_NAME},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.err, ValidateContextName(tc.name))
+		})
+	}
+}
@@ -0,0 +1,363 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	// KeyringBackend stores the secrets in the OS keyring using the Secret Service API
+	KeyringBackend = "keyring"
+	// FileBackend stores the secrets in an age encrypted file
+	FileBackend = "file"
+
+	// Environment variables used by the file backend on headless hosts
+	SecretBackendEnv    = "PF9CTL_SECRET_BACKEND"
+	SecretPassphraseEnv = "PF9CTL_SECRET_PASSPHRASE"
+	SecretKeyFileEnv    = "PF9CTL_SECRET_KEY_FILE"
+
+	keyringService = "pf9ctl"
+)
+
+var (
+	UNKNOWN_SECRET_BACKEND = fmt.Errorf("Unknown secret backend, supported backends are %s and %s", KeyringBackend, FileBackend)
+	INVALID_SECRET_REF     = errors.New("Invalid secret reference in config")
+	SECRET_NOT_FOUND       = errors.New("Secret not found in the secret store")
+	KEYRING_UNAVAILABLE    = errors.New("OS keyring is not available, secret-tool and a D-Bus session are required")
+	NO_PASSPHRASE          = fmt.Errorf("No passphrase to unlock %s, set %s or %s", util.Pf9SecretsLoc, SecretPassphraseEnv, SecretKeyFileEnv)
+	WRONG_PASSPHRASE       = errors.New("Unable to decrypt the secrets file, wrong passphrase or age key")
+
+	// SecretBackend is the backend used for storing new secrets, it is picked
+	// automatically when empty.
+	SecretBackend string
+)
+
+// SecretStore keeps the credentials outside of config.json, the config only
+// holds a reference to the secret.
+type SecretStore interface {
+	Name() string
+	Get(key string) (string, error)
+	Set(key, secret string) error
+	Delete(key string) error
+}
+
+// GetSecretStore returns the named secret backend
+func GetSecretStore(name string) (SecretStore, error) {
+	switch name {
+	case KeyringBackend:
+		return keyringStore{}, nil
+	case FileBackend:
+		return &fileStore{loc: util.Pf9SecretsLoc}, nil
+	}
+	return nil, UNKNOWN_SECRET_BACKEND
+}
+
+// DefaultSecretStore returns the backend selected with the flag or the environment,
+// the keyring is preferred when it is available.
+func DefaultSecretStore() (SecretStore, error) {
+	name := SecretBackend
+	if name == "" {
+		name = os.Getenv(SecretBackendEnv)
+	}
+	if name == "" {
+		name = FileBackend
+		if keyringAvailable() {
+			name = KeyringBackend
+		}
+	}
+	return GetSecretStore(name)
+}
+
+// StoreSecret saves the secret in the default backend and returns its reference
+func StoreSecret(key, secret string) (string, error) {
+	store, err := DefaultSecretStore()
+	if err != nil {
+		return "", err
+	}
+	if err = store.Set(key, secret); err != nil {
+		return "", fmt.Errorf("Unable to store secret in %s: %w", store.Name(), err)
+	}
+	zap.S().Debugf("Stored secret %s in %s", key, store.Name())
+	return store.Name() + ":" + key, nil
+}
+
+// LoadSecret returns the secret pointed to by the reference
+func LoadSecret(ref string) (string, error) {
+	store, key, err := parseSecretRef(ref)
+	if err != nil {
+		return "", err
+	}
+	secret, err := store.Get(key)
+	if err != nil {
+		return "", fmt.Errorf("Unable to load secret from %s: %w", store.Name(), err)
+	}
+	return secret, nil
+}
+
+// DeleteSecret removes the secret pointed to by the reference
+func DeleteSecret(ref string) error {
+	store, key, err := parseSecretRef(ref)
+	if err != nil {
+		return err
+	}
+	return store.Delete(key)
+}
+
+func parseSecretRef(ref string) (SecretStore, string, error) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, "", INVALID_SECRET_REF
+	}
+	store, err := GetSecretStore(parts[0])
+	if err != nil {
+		return nil, "", err
+	}
+	return store, parts[1], nil
+}
+
+// secretKey identifies the DU password of a config in the secret store
+func secretKey(fqdn, username string) string {
+	return username + "@" + strings.TrimPrefix(strings.TrimPrefix(fqdn, "https://"), "http://")
+}
+
+// keyringStore talks to the Secret Service D-Bus API (gnome-keyring, KWallet) through secret-tool
+type keyringStore struct{}
+
+func keyringAvailable() bool {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return false
+	}
+	return os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+}
+
+func (keyringStore) Name() string {
+	return KeyringBackend
+}
+
+func (keyringStore) Get(key string) (string, error) {
+	if !keyringAvailable() {
+		return "", KEYRING_UNAVAILABLE
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", keyringService, "account", key)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		// secret-tool exits with 1 and prints nothing when the secret is missing
+		if stderr.Len() == 0 {
+			return "", SECRET_NOT_FOUND
+		}
+		return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func (keyringStore) Set(key, secret string) error {
+	if !keyringAvailable() {
+		return KEYRING_UNAVAILABLE
+	}
+	cmd := exec.Command("secret-tool", "store", "--label", "pf9ctl "+key, "service", keyringService, "account", key)
+	cmd.Stdin = strings.NewReader(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (keyringStore) Delete(key string) error {
+	if !keyringAvailable() {
+		return KEYRING_UNAVAILABLE
+	}
+	if out, err := exec.Command("secret-tool", "clear", "service", keyringService, "account", key).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// fileStore keeps all the secrets in a single age encrypted file, for a passphrase
+// or for the age key in the key file.
+type fileStore struct {
+	loc        string
+	passphrase string
+}
+
+// scryptWorkFactor is the log2 of the scrypt work factor of passphrase encrypted files
+var scryptWorkFactor = 18
+
+func (f *fileStore) Name() string {
+	return FileBackend
+}
+
+func (f *fileStore) Get(key string) (string, error) {
+	secrets, err := f.read()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[key]
+	if !ok {
+		return "", SECRET_NOT_FOUND
+	}
+	return secret, nil
+}
+
+func (f *fileStore) Set(key, secret string) error {
+	secrets, err := f.read()
+	if err != nil {
+		return err
+	}
+	secrets[key] = secret
+	return f.write(secrets)
+}
+
+func (f *fileStore) Delete(key string) error {
+	secrets, err := f.read()
+	if err != nil {
+		return err
+	}
+	delete(secrets, key)
+	return f.write(secrets)
+}
+
+// getPassphrase reads the passphrase from the environment or the terminal
+func (f *fileStore) getPassphrase(confirm bool) (string, error) {
+	if f.passphrase != "" {
+		return f.passphrase, nil
+	}
+
+	if pass := os.Getenv(SecretPassphraseEnv); pass != "" {
+		f.passphrase = pass
+	} else if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Printf("Passphrase for %s: ", f.loc)
+		pass, _ := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if confirm {
+			fmt.Printf("Confirm passphrase: ")
+			again, _ := terminal.ReadPassword(int(os.Stdin.Fd()))
+			fmt.Println()
+			if string(pass) != string(again) {
+				return "", errors.New("Passphrases do not match")
+			}
+		}
+		f.passphrase = string(pass)
+	}
+
+	if f.passphrase == "" {
+		return "", NO_PASSPHRASE
+	}
+	return f.passphrase, nil
+}
+
+// keys returns the age identities decrypting the file and the recipients encrypting
+// it. The age key file is used when no passphrase is given.
+func (f *fileStore) keys(confirm bool) ([]age.Identity, []age.Recipient, error) {
+	keyFile := os.Getenv(SecretKeyFileEnv)
+	if f.passphrase == "" && os.Getenv(SecretPassphraseEnv) == "" && keyFile != "" {
+		file, err := os.Open(keyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unable to read key file: %w", err)
+		}
+		defer file.Close()
+		identities, err := age.ParseIdentities(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Unable to parse age key file %s: %w", keyFile, err)
+		}
+		var recipients []age.Recipient
+		for _, identity := range identities {
+			if x25519, ok := identity.(*age.X25519Identity); ok {
+				recipients = append(recipients, x25519.Recipient())
+			}
+		}
+		return identities, recipients, nil
+	}
+
+	passphrase, err := f.getPassphrase(confirm)
+	if err != nil {
+		return nil, nil, err
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	identity.SetMaxWorkFactor(max(scryptWorkFactor, 22))
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	recipient.SetWorkFactor(scryptWorkFactor)
+	return []age.Identity{identity}, []age.Recipient{recipient}, nil
+}
+
+func (f *fileStore) read() (map[string]string, error) {
+	secrets := map[string]string{}
+	file, err := os.Open(f.loc)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return secrets, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	identities, _, err := f.keys(false)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(file, identities...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, WRONG_PASSPHRASE
+		}
+		return nil, fmt.Errorf("Unable to decrypt %s: %w", f.loc, err)
+	}
+	if err = json.NewDecoder(r).Decode(&secrets); err != nil {
+		return nil, fmt.Errorf("Unable to parse %s: %w", f.loc, err)
+	}
+	return secrets, nil
+}
+
+func (f *fileStore) write(secrets map[string]string) error {
+	_, err := os.Stat(f.loc)
+	_, recipients, err := f.keys(os.IsNotExist(err))
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("No X25519 age key in %s", os.Getenv(SecretKeyFileEnv))
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so an interrupted write does not lose the secrets
+	tmp, err := os.CreateTemp(filepath.Dir(f.loc), ".secrets-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w, err := age.Encrypt(tmp, recipients...)
+	if err == nil {
+		_, err = w.Write(plain)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to encrypt %s: %w", f.loc, err)
+	}
+	return os.Rename(tmp.Name(), f.loc)
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/stretchr/testify/assert"
+)
+
+// fastScrypt lowers the scrypt work factor so the passphrase tests run quickly
+func fastScrypt(t *testing.T) {
+	workFactor := scryptWorkFactor
+	scryptWorkFactor = 10
+	t.Cleanup(func() { scryptWorkFactor = workFactor })
+}
+
+func TestFileStore(t *testing.T) {
+	fastScrypt(t)
+	loc := filepath.Join(t.TempDir(), "secrets.age")
+	store := &fileStore{loc: loc, passphrase: "correct horse"}
+
+	assert.Nil(t, store.Set("admin@du.platform9.net", "s3cret"))
+	assert.Nil(t, store.Set("ops@du.platform9.net", "other"))
+
+	secret, err := store.Get("admin@du.platform9.net")
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cret", secret)
+
+	// The password must not be readable from the file
+	byt, err := os.ReadFile(loc)
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(byt), "s3cret"))
+	info, err := os.Stat(loc)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, err = (&fileStore{loc: loc, passphrase: "wrong"}).Get("admin@du.platform9.net")
+	assert.Equal(t, WRONG_PASSPHRASE, err)
+
+	// The file is a standard age file
+	identity, err := age.NewScryptIdentity("correct horse")
+	assert.Nil(t, err)
+	file, err := os.Open(loc)
+	assert.Nil(t, err)
+	defer file.Close()
+	r, err := age.Decrypt(file, identity)
+	assert.Nil(t, err)
+	plain, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Contains(t, string(plain), `"admin@du.platform9.net":"s3cret"`)
+
+	assert.Nil(t, store.Delete("admin@du.platform9.net"))
+	_, err = store.Get("admin@du.platform9.net")
+	assert.Equal(t, SECRET_NOT_FOUND, err)
+}
+
+func TestFileStoreKeyFile(t *testing.T) {
+	fastScrypt(t)
+	loc := filepath.Join(t.TempDir(), "secrets.age")
+	t.Setenv(SecretPassphraseEnv, "")
+	identity, err := age.GenerateX25519Identity()
+	assert.Nil(t, err)
+	keyFile := filepath.Join(t.TempDir(), "key")
+	assert.Nil(t, os.WriteFile(keyFile, []byte("# created: 2026-10-17\n"+identity.String()+"\n"), 0600))
+	t.Setenv(SecretKeyFileEnv, keyFile)
+
+	assert.Nil(t, (&fileStore{loc: loc}).Set("key", "value"))
+	secret, err := (&fileStore{loc: loc}).Get("key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value", secret)
+
+	file, err := os.Open(loc)
+	assert.Nil(t, err)
+	defer file.Close()
+	r, err := age.Decrypt(file, identity)
+	assert.Nil(t, err)
+	plain, err := io.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"key":"value"}`, string(plain))
+
+	// The passphrase takes precedence over the key file
+	_, err = (&fileStore{loc: loc, passphrase: "passphrase"}).Get("key")
+	assert.Equal(t, WRONG_PASSPHRASE, err)
+
+	other, err := age.GenerateX25519Identity()
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(keyFile, []byte(other.String()+"\n"), 0600))
+	_, err = (&fileStore{loc: loc}).Get("key")
+	assert.Equal(t, WRONG_PASSPHRASE, err)
+
+	assert.Nil(t, os.WriteFile(keyFile, []byte("not a key\n"), 0600))
+	_, err = (&fileStore{loc: loc}).Get("key")
+	assert.ErrorContains(t, err, "Unable to parse age key file")
+}
+
+func TestParseSecretRef(t *testing.T) {
+	cases := map[string]struct {
+		ref string
+		err error
+	}{
+		"File":    {ref: "file:admin@du", err: nil},
+		"Keyring": {ref: "keyring:admin@du", err: nil},
+		"NoKey":   {ref: "file:", err: INVALID_SECRET_REF},
+		"NoStore": {ref: "admin@du", err: INVALID_SECRET_REF},
+		"Unknown": {ref: "vault:admin@du", err: UNKNOWN_SECRET_BACKEND},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := parseSecretRef(tc.ref)
+			assert.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	fastScrypt(t)
+	setupDBDirs(t)
+	SecretBackend = FileBackend
+	t.Cleanup(func() { SecretBackend = "" })
+	t.Setenv(SecretPassphraseEnv, "passphrase")
+
+	loc := ContextLoc(DefaultContext)
+	cfg := objects.Config{
+		Fqdn:     "https://du.platform9.net",
+		Username: "admin@example.com",
+		Password: base64.StdEncoding.EncodeToString([]byte("s3cret")),
+		Region:   "RegionOne",
+		Tenant:   "service",
+	}
+	byt, _ := json.Marshal(cfg)
+	assert.Nil(t, os.WriteFile(loc, byt, 0600))
+
+	cfg.Password = "s3cret"
+	migrateConfig(&cfg, loc)
+
+	var stored objects.Config
+	byt, err := os.ReadFile(loc)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(byt, &stored))
+	assert.Equal(t, "", stored.Password)
+	assert.Equal(t, "file:admin@example.com@du.platform9.net", stored.SecretRef)
+
+	secret, err := LoadSecret(stored.SecretRef)
+	assert.Nil(t, err)
+	assert.Equal(t, "s3cret", secret)
+}
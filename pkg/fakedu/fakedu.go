@@ -0,0 +1,754 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+
+// Package fakedu is a stand-in for the Platform9 control plane (DU) used for end
+// to end tests and local demos. It implements the keystone, resmgr and qbert APIs
+// called by pf9ctl and keeps the hosts, clusters and nodes in memory.
+package fakedu
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"go.uber.org/zap"
+)
+
+// Credentials and settings accepted by a new fake DU
+const (
+	DefaultUsername = "admin@platform9.net"
+	DefaultPassword = "password"
+	DefaultTenant   = "service"
+	DefaultRegion   = "RegionOne"
+
+	// KubeRoleVersion is the PMK version offered by the fake DU
+	KubeRoleVersion = "1.29.2-pmk.1"
+
+	regionInfoServiceID = "regioninfo-service"
+	kubeRole            = "pf9-kube"
+)
+
+// Host is a host registered with resmgr by its hostagent
+type Host struct {
+	ID         string
+	Hostname   string
+	IP         string
+	Responding bool
+	Roles      []string
+}
+
+// Server is a fake DU served over TLS by httptest. The credentials and the region
+// can be changed before the server is started.
+type Server struct {
+	*httptest.Server
+
+	Username  string
+	Password  string
+	Tenant    string
+	ProjectID string
+	UserID    string
+	Region    string
+	// RoleVersions are the PMK versions clusters can be created with or upgraded to
+	RoleVersions []string
+
+	mu           sync.Mutex
+	tokens       map[string]bool
+	hosts        map[string]*Host
+	clusters     map[string]*qbert.Cluster
+	nodes        map[string]*qbert.Node
+	nodePoolUuid string
+}
+
+// New starts a fake DU on a random local port
+func New() *Server {
+	s := NewUnstarted()
+	s.StartTLS()
+	return s
+}
+
+// NewUnstarted returns a fake DU which is not started yet, call StartTLS to serve it
+func NewUnstarted() *Server {
+	s := &Server{
+		Username:     DefaultUsername,
+		Password:     DefaultPassword,
+		Tenant:       DefaultTenant,
+		ProjectID:    uuid.New().String(),
+		UserID:       uuid.New().String(),
+		Region:       DefaultRegion,
+		RoleVersions: []string{KubeRoleVersion},
+		tokens:       map[string]bool{},
+		hosts:        map[string]*Host{},
+		clusters:     map[string]*qbert.Cluster{},
+		nodes:        map[string]*qbert.Node{},
+		nodePoolUuid: uuid.New().String(),
+	}
+	s.Server = httptest.NewUnstartedServer(s.handler())
+	return s
+}
+
+// Listen makes an unstarted server listen on addr instead of a random port
+func (s *Server) Listen(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.Listener.Close()
+	s.Listener = l
+	return nil
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /keystone/v3/auth/tokens", s.createToken)
+	mux.HandleFunc("GET /keystone/v3/services", s.authorized(s.listServices))
+	mux.HandleFunc("GET /keystone/v3/endpoints", s.authorized(s.listEndpoints))
+
+	mux.HandleFunc("GET /clarity/{script}", s.installer)
+	mux.HandleFunc("GET /protected/{list}", s.authorized(s.packageList))
+	mux.HandleFunc("PUT /fakedu/hosts/{id}", s.registerHost)
+
+	mux.HandleFunc("GET /resmgr/v1/hosts", s.authorized(s.listHosts))
+	mux.HandleFunc("GET /resmgr/v1/hosts/{id}", s.authorized(s.getHost))
+	mux.HandleFunc("DELETE /resmgr/v1/hosts/{id}", s.authorized(s.deleteHost))
+	mux.HandleFunc("PUT /resmgr/v1/hosts/{id}/roles/{role}", s.authorized(s.addRole))
+	mux.HandleFunc("PUT /resmgr/v1/hosts/{id}/roles/{role}/versions/{version}", s.authorized(s.addRole))
+
+	mux.HandleFunc("GET /qbert/v3/{project}/cloudProviders", s.project(s.listCloudProviders))
+	mux.HandleFunc("GET /qbert/v3/{project}/clusters", s.project(s.listClusters))
+	mux.HandleFunc("GET /qbert/v3/{project}/clusters/{uuid}", s.project(s.getCluster))
+	mux.HandleFunc("PUT /qbert/v3/{project}/clusters/{uuid}", s.project(s.updateCluster))
+	mux.HandleFunc("DELETE /qbert/v3/{project}/clusters/{uuid}", s.project(s.deleteCluster))
+	mux.HandleFunc("POST /qbert/v3/{project}/clusters/{uuid}/attach", s.project(s.attachNodes))
+	mux.HandleFunc("POST /qbert/v3/{project}/clusters/{uuid}/detach", s.project(s.detachNodes))
+	mux.HandleFunc("GET /qbert/v3/{project}/kubeconfig/{uuid}", s.project(s.kubeconfig))
+	mux.HandleFunc("GET /qbert/v3/{project}/nodes", s.project(s.listNodes))
+	mux.HandleFunc("GET /qbert/v3/{project}/nodes/{uuid}", s.project(s.getNode))
+	mux.HandleFunc("POST /qbert/v4/{project}/clusters", s.project(s.createCluster))
+	mux.HandleFunc("GET /qbert/v4/{project}/clusters/supportedRoleVersions", s.project(s.supportedRoleVersions))
+	mux.HandleFunc("POST /qbert/v4/{project}/clusters/{uuid}/upgrade", s.project(s.upgradeCluster))
+
+	return logRequests(mux)
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zap.S().Debugf("fake DU: %s %s", r.Method, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, map[string]interface{}{"code": status, "message": fmt.Sprintf(format, args...)})
+}
+
+// authorized rejects requests without a token issued by the fake DU
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		ok := s.tokens[r.Header.Get("X-Auth-Token")]
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "The request you have made requires authentication.")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// project rejects requests for projects other than the one of the fake DU
+func (s *Server) project(next http.HandlerFunc) http.HandlerFunc {
+	return s.authorized(func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("project") != s.ProjectID {
+			writeError(w, http.StatusForbidden, "Token is not scoped to project %s", r.PathValue("project"))
+			return
+		}
+		next(w, r)
+	})
+}
+
+type authRequest struct {
+	Auth struct {
+		Identity struct {
+			Methods  []string `json:"methods"`
+			Password struct {
+				User struct {
+					Name     string `json:"name"`
+					Password string `json:"password"`
+				} `json:"user"`
+			} `json:"password"`
+		} `json:"identity"`
+		Scope struct {
+			Project struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"project"`
+		} `json:"scope"`
+	} `json:"auth"`
+}
+
+func (s *Server) createToken(w http.ResponseWriter, r *http.Request) {
+	var req authRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid auth request: %s", err)
+		return
+	}
+	user := req.Auth.Identity.Password.User
+	if user.Name != s.Username || user.Password != s.Password {
+		writeError(w, http.StatusUnauthorized, "The request you have made requires authentication.")
+		return
+	}
+	project := req.Auth.Scope.Project
+	if project.ID != s.ProjectID && project.Name != s.Tenant {
+		writeError(w, http.StatusUnauthorized, "User has no access to project")
+		return
+	}
+
+	token := uuid.New().String()
+	s.mu.Lock()
+	s.tokens[token] = true
+	s.mu.Unlock()
+
+	w.Header().Set("X-Subject-Token", token)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"token": map[string]interface{}{
+			"methods":    req.Auth.Identity.Methods,
+			"expires_at": time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339),
+			"project":    map[string]string{"id": s.ProjectID, "name": s.Tenant},
+			"user":       map[string]string{"id": s.UserID, "name": s.Username},
+		},
+	})
+}
+
+func (s *Server) listServices(w http.ResponseWriter, r *http.Request) {
+	services := []map[string]interface{}{}
+	if t := r.URL.Query().Get("type"); t == "" || t == "regionInfo" {
+		services = append(services, map[string]interface{}{
+			"id": regionInfoServiceID, "name": "regionInfo", "type": "regionInfo", "enabled": true,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"services": services})
+}
+
+func (s *Server) listEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints := []map[string]interface{}{}
+	if id := r.URL.Query().Get("service_id"); id == "" || id == regionInfoServiceID {
+		for _, iface := range []string{"internal", "public"} {
+			endpoints = append(endpoints, map[string]interface{}{
+				"id": iface + "-" + s.Region, "interface": iface, "region": s.Region, "region_id": s.Region,
+				"service_id": regionInfoServiceID, "url": s.URL + "/regionInfo", "enabled": true,
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"endpoints": endpoints})
+}
+
+// installer serves a hostagent installer which only records the host id and
+// registers the host with the fake DU.
+func (s *Server) installer(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.PathValue("script"), "platform9-install-") {
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprintf(w, `#!/bin/bash
+# Hostagent installer of the pf9ctl fake DU, it does not install any package
+set -e
+mkdir -p /etc/pf9
+if ! grep -q host_id /etc/pf9/host_id.conf 2>/dev/null; then
+    printf '[hostagent]\nhost_id = %%s\n' "$(cat /proc/sys/kernel/random/uuid)" > /etc/pf9/host_id.conf
+fi
+host_id=$(grep host_id /etc/pf9/host_id.conf | cut -d = -f2 | tr -d ' ')
+ip=$(hostname -I | cut -d ' ' -f1)
+curl --silent --show-error --insecure -X PUT "%s/fakedu/hosts/${host_id}" \
+    -d "{\"hostname\": \"$(hostname)\", \"ip\": \"${ip}\"}"
+`, s.URL)
+}
+
+func (s *Server) packageList(w http.ResponseWriter, r *http.Request) {
+	ext, ok := strings.CutPrefix(r.PathValue("list"), "nocert-packagelist")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	fmt.Fprintf(w, "pf9-hostagent-5.10.0-1234.x86_64%s\n", ext)
+}
+
+// registerHost is called by the installer, like a hostagent checking in with resmgr
+func (s *Server) registerHost(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Hostname string `json:"hostname"`
+		IP       string `json:"ip"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IP == "" {
+		writeError(w, http.StatusBadRequest, "hostname and ip are required")
+		return
+	}
+	s.AddHost(r.PathValue("id"), req.Hostname, req.IP)
+	writeJSON(w, http.StatusOK, map[string]string{"id": r.PathValue("id")})
+}
+
+// AddHost registers a responding host, as its hostagent would after being installed
+func (s *Server) AddHost(id, hostname, ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h, ok := s.hosts[id]; ok {
+		h.Hostname, h.IP, h.Responding = hostname, ip, true
+		return
+	}
+	s.hosts[id] = &Host{ID: id, Hostname: hostname, IP: ip, Responding: true}
+}
+
+// SetResponding marks the hostagent of a host as connected or disconnected
+func (s *Server) SetResponding(id string, responding bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h, ok := s.hosts[id]; ok {
+		h.Responding = responding
+	}
+}
+
+// Hosts returns the hosts registered with resmgr
+func (s *Server) Hosts() []Host {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var hosts []Host
+	for _, h := range s.hosts {
+		hosts = append(hosts, *h)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].ID < hosts[j].ID })
+	return hosts
+}
+
+// Clusters returns the clusters known to qbert
+func (s *Server) Clusters() []qbert.Cluster {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clusterList()
+}
+
+// SetClusterStatus changes the status reported for a cluster, clusters are ok once created
+func (s *Server) SetClusterStatus(uuid, status, taskStatus, taskError string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.clusters[uuid]; ok {
+		c.Status, c.TaskStatus, c.TaskError = status, taskStatus, taskError
+	}
+}
+
+// Nodes returns the hosts authorized with the pf9-kube role
+func (s *Server) Nodes() []qbert.Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nodeList()
+}
+
+func resmgrHost(h *Host) map[string]interface{} {
+	roles := h.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return map[string]interface{}{
+		"id": h.ID,
+		"info": map[string]interface{}{
+			"hostname":   h.Hostname,
+			"responding": h.Responding,
+			"os_family":  "Linux",
+		},
+		"extensions": map[string]interface{}{
+			"ip_address": map[string]interface{}{"data": []string{h.IP}},
+		},
+		"roles":       roles,
+		"role_status": "ok",
+	}
+}
+
+func (s *Server) listHosts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hosts := []map[string]interface{}{}
+	for _, h := range s.hosts {
+		hosts = append(hosts, resmgrHost(h))
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i]["id"].(string) < hosts[j]["id"].(string) })
+	writeJSON(w, http.StatusOK, hosts)
+}
+
+func (s *Server) getHost(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.hosts[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Host %s not found", r.PathValue("id"))
+		return
+	}
+	writeJSON(w, http.StatusOK, resmgrHost(h))
+}
+
+// deleteHost deauthorizes the host, it is also removed from qbert
+func (s *Server) deleteHost(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	h, ok := s.hosts[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Host %s not found", id)
+		return
+	}
+	if n, ok := s.nodes[id]; ok && n.ClusterUuid != "" {
+		writeError(w, http.StatusConflict, "Host %s is attached to cluster %s, detach it first", id, n.ClusterName)
+		return
+	}
+	h.Roles = nil
+	delete(s.nodes, id)
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+// addRole authorizes the host, with the pf9-kube role it becomes a qbert node
+func (s *Server) addRole(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, role := r.PathValue("id"), r.PathValue("role")
+	h, ok := s.hosts[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Host %s not found", id)
+		return
+	}
+	if role != kubeRole {
+		writeError(w, http.StatusNotFound, "Role %s not found", role)
+		return
+	}
+	if !containsString(h.Roles, role) {
+		h.Roles = append(h.Roles, role)
+	}
+	if _, ok := s.nodes[id]; !ok {
+		s.nodes[id] = &qbert.Node{Uuid: id, PrimaryIp: h.IP, ActualKubeRoleVersion: KubeRoleVersion}
+	}
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+func (s *Server) listCloudProviders(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, []map[string]string{
+		{"name": "defaultCloudProvider", "type": "local", "uuid": s.nodePoolUuid, "nodePoolUuid": s.nodePoolUuid},
+	})
+}
+
+// clusterList returns the clusters sorted by name, must be called with the lock held
+func (s *Server) clusterList() []qbert.Cluster {
+	clusters := []qbert.Cluster{}
+	for _, c := range s.clusters {
+		clusters = append(clusters, *c)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+	return clusters
+}
+
+// nodeList returns the nodes sorted by uuid, must be called with the lock held
+func (s *Server) nodeList() []qbert.Node {
+	nodes := []qbert.Node{}
+	for _, n := range s.nodes {
+		nodes = append(nodes, *n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Uuid < nodes[j].Uuid })
+	return nodes
+}
+
+func (s *Server) listClusters(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.clusterList())
+}
+
+func (s *Server) getCluster(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clusters[r.PathValue("uuid")]
+	if !ok {
+		// qbert answers 400 for unknown clusters
+		writeError(w, http.StatusBadRequest, "Cluster %s not found", r.PathValue("uuid"))
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func (s *Server) createCluster(w http.ResponseWriter, r *http.Request) {
+	var req qbert.ClusterCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid cluster: %s", err)
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "Cluster name is required")
+		return
+	}
+	if req.NodePoolUUID != s.nodePoolUuid {
+		writeError(w, http.StatusBadRequest, "Node pool %s not found", req.NodePoolUUID)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.clusters {
+		if c.Name == req.Name {
+			writeError(w, http.StatusConflict, "Cluster %s already exists", req.Name)
+			return
+		}
+	}
+	version := req.PmkVersion
+	if version == "" {
+		version = KubeRoleVersion
+	}
+	c := &qbert.Cluster{
+		Uuid:            uuid.New().String(),
+		Name:            req.Name,
+		Status:          "ok",
+		TaskStatus:      "success",
+		KubeRoleVersion: version,
+		NetworkPlugin:   req.NetworkPlugin,
+		ContainerCIDR:   req.ContainerCIDR,
+		ServiceCIDR:     req.ServiceCIDR,
+		MasterVirtualIP: req.MasterVirtualIP,
+		ExternalDNSName: req.ExternalDNSName,
+		NodePoolUuid:    req.NodePoolUUID,
+		ProjectId:       s.ProjectID,
+		EtcdBackup:      req.EtcdBackup,
+	}
+	s.clusters[c.Uuid] = c
+	writeJSON(w, http.StatusOK, map[string]string{"uuid": c.Uuid})
+}
+
+// updateCluster changes the etcd backup settings, the only ones pf9ctl updates
+func (s *Server) updateCluster(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		EtcdBackup *qbert.EtcdBackup `json:"etcdBackup"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid cluster: %s", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clusters[r.PathValue("uuid")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Cluster %s not found", r.PathValue("uuid"))
+		return
+	}
+	if req.EtcdBackup != nil {
+		c.EtcdBackup = *req.EtcdBackup
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// deleteCluster removes the cluster, its nodes are detached
+func (s *Server) deleteCluster(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("uuid")
+	if _, ok := s.clusters[id]; !ok {
+		writeError(w, http.StatusBadRequest, "Cluster %s not found", id)
+		return
+	}
+	for _, n := range s.nodes {
+		if n.ClusterUuid == id {
+			n.ClusterUuid, n.ClusterName, n.IsMaster = "", "", 0
+		}
+	}
+	delete(s.clusters, id)
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+type nodeRequest struct {
+	Uuid     string `json:"uuid"`
+	IsMaster bool   `json:"isMaster"`
+}
+
+func (s *Server) attachNodes(w http.ResponseWriter, r *http.Request) {
+	var req []nodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid nodes: %s", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clusters[r.PathValue("uuid")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Cluster %s not found", r.PathValue("uuid"))
+		return
+	}
+	// Validate all the nodes first so a failed request changes nothing
+	for _, nr := range req {
+		n, ok := s.nodes[nr.Uuid]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "Node %s is not authorized with the %s role", nr.Uuid, kubeRole)
+			return
+		}
+		if n.ClusterUuid != "" {
+			writeError(w, http.StatusBadRequest, "Node %s is already attached to cluster %s", nr.Uuid, n.ClusterName)
+			return
+		}
+		if !s.hosts[nr.Uuid].Responding {
+			writeError(w, http.StatusBadRequest, "Node %s is not responding", nr.Uuid)
+			return
+		}
+	}
+	for _, nr := range req {
+		n := s.nodes[nr.Uuid]
+		n.ClusterUuid, n.ClusterName, n.ActualKubeRoleVersion = c.Uuid, c.Name, c.KubeRoleVersion
+		if nr.IsMaster {
+			n.IsMaster = 1
+			c.NumMasters++
+		} else {
+			c.NumWorkers++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+func (s *Server) detachNodes(w http.ResponseWriter, r *http.Request) {
+	var req []nodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid nodes: %s", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clusters[r.PathValue("uuid")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Cluster %s not found", r.PathValue("uuid"))
+		return
+	}
+	for _, nr := range req {
+		if n, ok := s.nodes[nr.Uuid]; !ok || n.ClusterUuid != c.Uuid {
+			writeError(w, http.StatusBadRequest, "Node %s is not attached to cluster %s", nr.Uuid, c.Name)
+			return
+		}
+	}
+	for _, nr := range req {
+		n := s.nodes[nr.Uuid]
+		if n.IsMaster == 1 {
+			c.NumMasters--
+		} else {
+			c.NumWorkers--
+		}
+		n.ClusterUuid, n.ClusterName, n.IsMaster = "", "", 0
+	}
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+// kubeconfig returns the kubeconfig of the cluster with the token placeholder qbert uses
+func (s *Server) kubeconfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clusters[r.PathValue("uuid")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Cluster %s not found", r.PathValue("uuid"))
+		return
+	}
+	server := c.ExternalDNSName
+	if server == "" {
+		server = c.MasterVirtualIP
+	}
+	if server == "" {
+		server = "127.0.0.1"
+	}
+	fmt.Fprintf(w, `apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: https://%[2]s
+    insecure-skip-tls-verify: true
+users:
+- name: %[3]s
+  user:
+    token: __INSERT_BEARER_TOKEN_HERE__
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: %[3]s
+    namespace: default
+current-context: %[1]s
+`, c.Name, server, s.Username)
+}
+
+func (s *Server) listNodes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.nodeList())
+}
+
+func (s *Server) getNode(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nodes[r.PathValue("uuid")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Node %s not found", r.PathValue("uuid"))
+		return
+	}
+	writeJSON(w, http.StatusOK, n)
+}
+
+func (s *Server) supportedRoleVersions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	roles := []map[string]string{}
+	for _, version := range s.RoleVersions {
+		roles = append(roles, map[string]string{"roleVersion": version})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"roles": roles})
+}
+
+// upgradeCluster moves the cluster and its nodes to the new version at once
+func (s *Server) upgradeCluster(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		KubeRoleVersion string `json:"kubeRoleVersion"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid upgrade: %s", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clusters[r.PathValue("uuid")]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Cluster %s not found", r.PathValue("uuid"))
+		return
+	}
+	if !containsString(s.RoleVersions, req.KubeRoleVersion) {
+		writeError(w, http.StatusBadRequest, "Version %s is not supported", req.KubeRoleVersion)
+		return
+	}
+	c.KubeRoleVersion = req.KubeRoleVersion
+	for _, n := range s.nodes {
+		if n.ClusterUuid == c.Uuid {
+			n.ActualKubeRoleVersion = req.KubeRoleVersion
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]string{})
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
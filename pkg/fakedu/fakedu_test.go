@@ -0,0 +1,115 @@
+package fakedu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// do sends a request to the fake DU and returns the status and body
+func do(t *testing.T, s *Server, method, path, token, body string) (int, string) {
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	assert.Nil(t, err)
+	if token != "" {
+		req.Header.Set("X-Auth-Token", token)
+	}
+	resp, err := s.Client().Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	byt, err := io.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp.StatusCode, string(byt)
+}
+
+func login(t *testing.T, s *Server) string {
+	body := fmt.Sprintf(`{"auth": {"identity": {"methods": ["password"], "password": {"user": {"name": %q, "password": %q}}},
+		"scope": {"project": {"name": %q}}}}`, s.Username, s.Password, s.Tenant)
+	req, err := http.NewRequest("POST", s.URL+"/keystone/v3/auth/tokens", strings.NewReader(body))
+	assert.Nil(t, err)
+	resp, err := s.Client().Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	return resp.Header.Get("X-Subject-Token")
+}
+
+func TestAuthorization(t *testing.T) {
+	s := New()
+	defer s.Close()
+	token := login(t, s)
+	clusters := fmt.Sprintf("/qbert/v3/%s/clusters", s.ProjectID)
+
+	cases := map[string]struct {
+		path   string
+		token  string
+		status int
+	}{
+		"no token":      {clusters, "", http.StatusUnauthorized},
+		"unknown token": {clusters, "not-a-token", http.StatusUnauthorized},
+		"other project": {"/qbert/v3/other-project/clusters", token, http.StatusForbidden},
+		"valid token":   {clusters, token, http.StatusOK},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			status, _ := do(t, s, "GET", tc.path, tc.token, "")
+			assert.Equal(t, tc.status, status)
+		})
+	}
+}
+
+func TestHostLifecycle(t *testing.T) {
+	s := New()
+	defer s.Close()
+	token := login(t, s)
+
+	// The installer registers the host with the fake DU
+	status, script := do(t, s, "GET", "/clarity/platform9-install-debian.sh", "", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, script, s.URL+"/fakedu/hosts/")
+	status, _ = do(t, s, "PUT", "/fakedu/hosts/host-1", "", `{"hostname": "node1", "ip": "10.0.0.1"}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, body := do(t, s, "GET", "/resmgr/v1/hosts", token, "")
+	assert.Equal(t, http.StatusOK, status)
+	var hosts []map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(body), &hosts))
+	assert.Equal(t, 1, len(hosts))
+	assert.Equal(t, "host-1", hosts[0]["id"])
+
+	// Only hosts with the pf9-kube role are qbert nodes
+	assert.Equal(t, 0, len(s.Nodes()))
+	status, _ = do(t, s, "PUT", "/resmgr/v1/hosts/unknown/roles/pf9-kube", token, "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = do(t, s, "PUT", "/resmgr/v1/hosts/host-1/roles/pf9-kube/versions/"+KubeRoleVersion, token, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, len(s.Nodes()))
+
+	status, body = do(t, s, "POST", fmt.Sprintf("/qbert/v4/%s/clusters", s.ProjectID), token,
+		fmt.Sprintf(`{"name": "c1", "nodePoolUuid": %q}`, s.nodePoolUuid))
+	assert.Equal(t, http.StatusOK, status)
+	var created map[string]string
+	assert.Nil(t, json.Unmarshal([]byte(body), &created))
+	attach := fmt.Sprintf("/qbert/v3/%s/clusters/%s/attach", s.ProjectID, created["uuid"])
+
+	// A disconnected host can not be attached
+	s.SetResponding("host-1", false)
+	status, body = do(t, s, "POST", attach, token, `[{"uuid": "host-1", "isMaster": true}]`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "not responding")
+	s.SetResponding("host-1", true)
+	status, _ = do(t, s, "POST", attach, token, `[{"uuid": "host-1", "isMaster": true}]`)
+	assert.Equal(t, http.StatusOK, status)
+	status, body = do(t, s, "POST", attach, token, `[{"uuid": "host-1", "isMaster": true}]`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "already attached")
+	assert.Equal(t, 1, s.Clusters()[0].NumMasters)
+
+	// Attached hosts can not be deauthorized
+	status, _ = do(t, s, "DELETE", "/resmgr/v1/hosts/host-1", token, "")
+	assert.Equal(t, http.StatusConflict, status)
+}
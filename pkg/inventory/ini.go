@@ -0,0 +1,157 @@
+package inventory
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseINI reads an Ansible INI inventory. The connection variables of Ansible
+// map to the ssh settings, pf9_role and pf9_jump_host set the role and the
+// bastion, other variables are ignored.
+func parseINI(data []byte) (*builder, error) {
+	b := newBuilder()
+	section, kind := "", "hosts"
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' || text[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section %s", line, text)
+			}
+			section, kind = text[1:len(text)-1], "hosts"
+			if i := strings.LastIndex(section, ":"); i >= 0 {
+				section, kind = section[:i], section[i+1:]
+			}
+			if kind != "hosts" && kind != "vars" && kind != "children" {
+				return nil, fmt.Errorf("line %d: unknown section type %s", line, kind)
+			}
+			if section != "all" && section != "ungrouped" {
+				b.group(section)
+			}
+			continue
+		}
+
+		fields, err := splitINIFields(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch kind {
+		case "vars":
+			key, value, ok := strings.Cut(text, "=")
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected key=value", line)
+			}
+			vars := &b.group(section).vars
+			if section == "all" {
+				vars = &b.all
+			}
+			if err := setINIVar(vars, strings.TrimSpace(key), unquote(strings.TrimSpace(value))); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		case "children":
+			g := b.group(section)
+			g.children = append(g.children, fields[0])
+			b.group(fields[0])
+		default:
+			name := fields[0]
+			if strings.ContainsAny(name, "[]") {
+				return nil, fmt.Errorf("line %d: host ranges like %s are not supported", line, name)
+			}
+			var vars Settings
+			var ip string
+			for _, field := range fields[1:] {
+				key, value, ok := strings.Cut(field, "=")
+				if !ok {
+					return nil, fmt.Errorf("line %d: expected key=value, got %s", line, field)
+				}
+				if key == "ansible_host" || key == "ansible_ssh_host" {
+					ip = value
+				} else if err := setINIVar(&vars, key, value); err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+			group := section
+			if group == "all" || group == "ungrouped" {
+				group = ""
+			}
+			b.addHost(group, name, ip, vars)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func setINIVar(s *Settings, key, value string) error {
+	switch key {
+	case "ansible_user", "ansible_ssh_user":
+		s.User = value
+	case "ansible_password", "ansible_ssh_pass", "ansible_ssh_password":
+		s.Password = value
+	case "ansible_ssh_private_key_file":
+		s.SshKey = value
+	case "ansible_port", "ansible_ssh_port":
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %s", key, value)
+		}
+		s.SshPort = port
+	case "ansible_become_password", "ansible_become_pass", "ansible_sudo_pass", "ansible_sudo_password":
+		s.SudoPassword = value
+	case "pf9_jump_host":
+		s.JumpHost = value
+	case "pf9_role":
+		s.Role = value
+	}
+	return nil
+}
+
+// splitINIFields splits a line in whitespace separated fields, quoted values
+// can contain spaces.
+func splitINIFields(text string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	inField := false
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case ch == '\'' || ch == '"':
+			end := strings.IndexByte(text[i+1:], ch)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in %s", text)
+			}
+			field.WriteString(text[i+1 : i+1+end])
+			i += end + 1
+			inField = true
+		case ch == ' ' || ch == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		case ch == '#' && !inField:
+			i = len(text)
+		default:
+			field.WriteByte(ch)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
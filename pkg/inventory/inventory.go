@@ -0,0 +1,291 @@
+// Copyright © 2020 The Platform9 Systems Inc.
+
+// Package inventory loads the hosts pf9ctl runs node commands against from a
+// YAML or Ansible INI file, with their ssh settings and roles.
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/objects"
+)
+
+const (
+	RoleMaster = "master"
+	RoleWorker = "worker"
+)
+
+// Settings are the ssh settings and the role of a host, the empty ones are
+// inherited from the groups of the host and then from the command line.
+type Settings struct {
+	User         string `yaml:"user,omitempty"`
+	Password     string `yaml:"password,omitempty"`
+	SshKey       string `yaml:"ssh_key,omitempty"`
+	SshPort      int    `yaml:"ssh_port,omitempty"`
+	JumpHost     string `yaml:"jump_host,omitempty"`
+	SudoPassword string `yaml:"sudo_password,omitempty"`
+	Role         string `yaml:"role,omitempty"`
+}
+
+// merge overrides the settings with the ones set in o
+func (s *Settings) merge(o Settings) {
+	if o.User != "" {
+		s.User = o.User
+	}
+	if o.Password != "" {
+		s.Password = o.Password
+	}
+	if o.SshKey != "" {
+		s.SshKey = o.SshKey
+	}
+	if o.SshPort != 0 {
+		s.SshPort = o.SshPort
+	}
+	if o.JumpHost != "" {
+		s.JumpHost = o.JumpHost
+	}
+	if o.SudoPassword != "" {
+		s.SudoPassword = o.SudoPassword
+	}
+	if o.Role != "" {
+		s.Role = o.Role
+	}
+}
+
+// Host is a host of the inventory with its resolved settings
+type Host struct {
+	Name   string
+	IP     string
+	Groups []string
+	Settings
+}
+
+// NodeConfig returns nc with the IP and the ssh settings of the host, the
+// settings the inventory leaves empty keep the values of nc.
+func (h Host) NodeConfig(nc objects.NodeConfig) objects.NodeConfig {
+	nc.IPs = []string{h.IP}
+	if h.User != "" {
+		nc.User = h.User
+	}
+	if h.Password != "" {
+		nc.Password = h.Password
+	}
+	if h.SshKey != "" {
+		nc.SshKey = h.SshKey
+	}
+	if h.SshPort != 0 {
+		nc.SshPort = h.SshPort
+	}
+	if h.JumpHost != "" {
+		nc.JumpHost = h.JumpHost
+	}
+	if h.SudoPassword != "" {
+		nc.SudoPassword = h.SudoPassword
+	}
+	return nc
+}
+
+// Inventory is the list of hosts of an inventory file, in the order of the file
+type Inventory struct {
+	Hosts []Host
+}
+
+// Load reads an inventory file, .yaml and .yml files are YAML, .ini files are
+// Ansible INI and the format of other files is detected.
+func Load(path string) (*Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the inventory: %w", err)
+	}
+	var b *builder
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		b, err = parseYAML(data)
+	case ".ini":
+		b, err = parseINI(data)
+	default:
+		if b, err = parseYAML(data); err != nil {
+			b, err = parseINI(data)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid inventory %s: %w", path, err)
+	}
+	inv, err := b.build()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid inventory %s: %w", path, err)
+	}
+	return inv, nil
+}
+
+// Select returns the hosts matching limit, a comma separated list of group
+// names, host names and IPs. All the hosts are returned when limit is empty.
+func (inv *Inventory) Select(limit string) ([]Host, error) {
+	if strings.TrimSpace(limit) == "" {
+		return inv.Hosts, nil
+	}
+	selected := map[string]bool{}
+	for _, pattern := range strings.Split(limit, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		matched := false
+		for _, h := range inv.Hosts {
+			if h.matches(pattern) {
+				selected[h.IP] = true
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("No host or group %s in the inventory", pattern)
+		}
+	}
+	var hosts []Host
+	for _, h := range inv.Hosts {
+		if selected[h.IP] {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, nil
+}
+
+func (h Host) matches(pattern string) bool {
+	if pattern == "all" || pattern == h.Name || pattern == h.IP {
+		return true
+	}
+	for _, g := range h.Groups {
+		if g == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// group is a group of hosts as read from a file, before resolution
+type group struct {
+	name     string
+	vars     Settings
+	hosts    []string
+	children []string
+}
+
+// builder collects the hosts and groups of an inventory file and resolves the
+// settings of the hosts, host settings win over group settings which win over
+// the settings of the parent groups and then of all the hosts.
+type builder struct {
+	all       Settings
+	groups    []*group
+	hostNames []string
+	hostVars  map[string]Settings
+	hostIPs   map[string]string
+}
+
+func newBuilder() *builder {
+	return &builder{hostVars: map[string]Settings{}, hostIPs: map[string]string{}}
+}
+
+func (b *builder) group(name string) *group {
+	for _, g := range b.groups {
+		if g.name == name {
+			return g
+		}
+	}
+	g := &group{name: name}
+	b.groups = append(b.groups, g)
+	return g
+}
+
+// addHost adds a host to a group, with its IP and own settings when it has some
+func (b *builder) addHost(groupName, name, ip string, vars Settings) {
+	if _, ok := b.hostIPs[name]; !ok {
+		b.hostNames = append(b.hostNames, name)
+		b.hostIPs[name] = name
+	}
+	if ip != "" {
+		b.hostIPs[name] = ip
+	}
+	own := b.hostVars[name]
+	own.merge(vars)
+	b.hostVars[name] = own
+	if groupName != "" {
+		g := b.group(groupName)
+		g.hosts = append(g.hosts, name)
+	}
+}
+
+func (b *builder) build() (*Inventory, error) {
+	parents := map[string][]string{}
+	for _, g := range b.groups {
+		for _, child := range g.children {
+			parents[child] = append(parents[child], g.name)
+		}
+	}
+	inv := &Inventory{}
+	seenIPs := map[string]string{}
+	for _, name := range b.hostNames {
+		h := Host{Name: name, IP: b.hostIPs[name]}
+		if other, ok := seenIPs[h.IP]; ok {
+			return nil, fmt.Errorf("hosts %s and %s have the same IP %s", other, name, h.IP)
+		}
+		seenIPs[h.IP] = name
+
+		h.Settings = b.all
+		applied := map[string]bool{}
+		var apply func(name string)
+		apply = func(name string) {
+			if applied[name] {
+				return
+			}
+			applied[name] = true
+			for _, parent := range parents[name] {
+				apply(parent)
+			}
+			vars := b.group(name).vars
+			if vars.Role == "" {
+				vars.Role = groupRole(name)
+			}
+			h.Settings.merge(vars)
+			h.Groups = append(h.Groups, name)
+		}
+		for _, g := range b.groups {
+			for _, member := range g.hosts {
+				if member == name {
+					apply(g.name)
+				}
+			}
+		}
+		h.Settings.merge(b.hostVars[name])
+
+		switch h.Role {
+		case "", RoleMaster, RoleWorker:
+		default:
+			return nil, fmt.Errorf("host %s has the role %s, it must be %s or %s", name, h.Role, RoleMaster, RoleWorker)
+		}
+		if strings.HasPrefix(h.SshKey, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				h.SshKey = filepath.Join(home, h.SshKey[2:])
+			}
+		}
+		inv.Hosts = append(inv.Hosts, h)
+	}
+	if len(inv.Hosts) == 0 {
+		return nil, fmt.Errorf("no hosts")
+	}
+	return inv, nil
+}
+
+// groupRole is the role given by the usual names of groups, including the
+// ones of kubespray inventories
+func groupRole(name string) string {
+	switch name {
+	case "master", "masters", "kube_control_plane", "kube-master":
+		return RoleMaster
+	case "worker", "workers", "kube_node", "kube-node":
+		return RoleWorker
+	}
+	return ""
+}
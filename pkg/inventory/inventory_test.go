@@ -0,0 +1,168 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlFile = `
+vars:
+  user: ubuntu
+  ssh_key: /keys/default
+groups:
+  masters:
+    vars:
+      sudo_password: secret
+    hosts:
+      - ip: 10.0.0.1
+        name: m1
+  workers:
+    vars:
+      user: centos
+    hosts:
+      - ip: 10.0.0.2
+      - ip: 10.0.0.3
+        user: admin
+        ssh_port: 2222
+  gpu:
+    vars:
+      role: worker
+      jump_host: bastion
+    hosts:
+      - ip: 10.0.0.4
+`
+
+const iniFile = `
+# kubespray style groups
+[kube_control_plane]
+m1 ansible_host=10.0.0.1 ansible_become_password='secret'
+
+[kube_node]
+10.0.0.2
+10.0.0.3 ansible_user=admin ansible_port=2222
+
+[kube_node:vars]
+ansible_user=centos
+
+[gpu]
+10.0.0.4
+
+[gpu:vars]
+pf9_role=worker
+pf9_jump_host=bastion
+
+[k8s_cluster:children]
+kube_control_plane
+kube_node
+
+[all:vars]
+ansible_user=ubuntu
+ansible_ssh_private_key_file=/keys/default
+`
+
+func writeInventory(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	cases := map[string]struct {
+		file    string
+		content string
+		groups  map[string][]string
+	}{
+		"yaml": {"hosts.yaml", yamlFile, map[string][]string{
+			"10.0.0.1": {"masters"}, "10.0.0.2": {"workers"}, "10.0.0.3": {"workers"}, "10.0.0.4": {"gpu"},
+		}},
+		"ini": {"hosts.ini", iniFile, map[string][]string{
+			"10.0.0.1": {"k8s_cluster", "kube_control_plane"}, "10.0.0.2": {"k8s_cluster", "kube_node"},
+			"10.0.0.3": {"k8s_cluster", "kube_node"}, "10.0.0.4": {"gpu"},
+		}},
+		"detected ini":  {"hosts", iniFile, nil},
+		"detected yaml": {"hosts", yamlFile, nil},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			inv, err := Load(writeInventory(t, tc.file, tc.content))
+			assert.Nil(t, err)
+			assert.Equal(t, 4, len(inv.Hosts))
+			h := inv.Hosts
+			assert.Equal(t, Settings{User: "ubuntu", SshKey: "/keys/default", SudoPassword: "secret", Role: RoleMaster}, h[0].Settings)
+			assert.Equal(t, "m1", h[0].Name)
+			assert.Equal(t, "10.0.0.1", h[0].IP)
+			assert.Equal(t, Settings{User: "centos", SshKey: "/keys/default", Role: RoleWorker}, h[1].Settings)
+			assert.Equal(t, Settings{User: "admin", SshKey: "/keys/default", SshPort: 2222, Role: RoleWorker}, h[2].Settings)
+			assert.Equal(t, Settings{User: "ubuntu", SshKey: "/keys/default", JumpHost: "bastion", Role: RoleWorker}, h[3].Settings)
+			for _, host := range h {
+				if tc.groups != nil {
+					assert.Equal(t, tc.groups[host.IP], host.Groups)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := map[string]struct {
+		file    string
+		content string
+		err     string
+	}{
+		"unknown field": {"hosts.yaml", "vars:\n  usr: ubuntu\nhosts:\n  - ip: 10.0.0.1\n", "field usr not found"},
+		"bad role":      {"hosts.yaml", "hosts:\n  - ip: 10.0.0.1\n    role: etcd\n", "it must be master or worker"},
+		"no hosts":      {"hosts.yaml", "vars:\n  user: ubuntu\n", "no hosts"},
+		"same ip":       {"hosts.ini", "a ansible_host=10.0.0.1\nb ansible_host=10.0.0.1\n", "have the same IP"},
+		"range":         {"hosts.ini", "[workers]\nnode[01:10]\n", "host ranges"},
+		"bad port":      {"hosts.ini", "10.0.0.1 ansible_port=ssh\n", "invalid ansible_port"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(writeInventory(t, tc.file, tc.content))
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	inv, err := Load(writeInventory(t, "hosts.ini", iniFile))
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		limit string
+		ips   []string
+		err   string
+	}{
+		"everything":    {"", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}, ""},
+		"group":         {"kube_node", []string{"10.0.0.2", "10.0.0.3"}, ""},
+		"parent group":  {"k8s_cluster", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, ""},
+		"host name":     {"m1", []string{"10.0.0.1"}, ""},
+		"hosts and ips": {"gpu, 10.0.0.2", []string{"10.0.0.2", "10.0.0.4"}, ""},
+		"unknown":       {"kube_node,etcd", nil, "No host or group etcd"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			hosts, err := inv.Select(tc.limit)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			var ips []string
+			for _, h := range hosts {
+				ips = append(ips, h.IP)
+			}
+			assert.Equal(t, tc.ips, ips)
+		})
+	}
+}
+
+func TestHostNodeConfig(t *testing.T) {
+	h := Host{IP: "10.0.0.3", Settings: Settings{User: "admin", SshPort: 2222}}
+	nc := h.NodeConfig(objects.NodeConfig{User: "ubuntu", SshKey: "/keys/flag", SshPort: 22, IPs: []string{"10.0.0.1"}, MFA: "123"})
+	assert.Equal(t, objects.NodeConfig{User: "admin", SshKey: "/keys/flag", SshPort: 2222, IPs: []string{"10.0.0.3"}, MFA: "123"}, nc)
+}
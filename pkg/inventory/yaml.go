@@ -0,0 +1,81 @@
+package inventory
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlHost is a host of a YAML inventory, it is named after its IP by default
+type yamlHost struct {
+	Name     string `yaml:"name,omitempty"`
+	IP       string `yaml:"ip,omitempty"`
+	Settings `yaml:",inline"`
+}
+
+type yamlGroup struct {
+	Vars     Settings   `yaml:"vars,omitempty"`
+	Hosts    []yamlHost `yaml:"hosts,omitempty"`
+	Children []string   `yaml:"children,omitempty"`
+}
+
+// yamlInventory is the layout of a YAML inventory, the groups are a mapping
+// node to keep the hosts in the order of the file.
+type yamlInventory struct {
+	Vars   Settings   `yaml:"vars,omitempty"`
+	Hosts  []yamlHost `yaml:"hosts,omitempty"`
+	Groups yaml.Node  `yaml:"groups,omitempty"`
+}
+
+func parseYAML(data []byte) (*builder, error) {
+	var inv yamlInventory
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&inv); err != nil {
+		return nil, err
+	}
+
+	b := newBuilder()
+	b.all = inv.Vars
+	addHosts := func(group string, hosts []yamlHost) error {
+		for _, h := range hosts {
+			name := h.Name
+			if name == "" {
+				name = h.IP
+			}
+			if name == "" {
+				return fmt.Errorf("a host of %s has neither a name nor an ip", groupOrAll(group))
+			}
+			b.addHost(group, name, h.IP, h.Settings)
+		}
+		return nil
+	}
+	if err := addHosts("", inv.Hosts); err != nil {
+		return nil, err
+	}
+	if inv.Groups.Kind != 0 && inv.Groups.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: groups must be a mapping of group names", inv.Groups.Line)
+	}
+	for i := 0; i+1 < len(inv.Groups.Content); i += 2 {
+		name := inv.Groups.Content[i].Value
+		var yg yamlGroup
+		if err := inv.Groups.Content[i+1].Decode(&yg); err != nil {
+			return nil, fmt.Errorf("group %s: %w", name, err)
+		}
+		g := b.group(name)
+		g.vars = yg.Vars
+		g.children = yg.Children
+		if err := addHosts(name, yg.Hosts); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func groupOrAll(group string) string {
+	if group == "" {
+		return "the inventory"
+	}
+	return "group " + group
+}
@@ -1,12 +1,18 @@
 package objects
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
 
 // objects stores information to contact with the pf9 controller.
 type Config struct {
 	Fqdn               string        `json:"fqdn"`
 	Username           string        `json:"username"`
 	Password           string        `json:"password"`
+	SecretRef          string        `json:"secret_ref,omitempty"`
 	Tenant             string        `json:"tenant"`
 	Region             string        `json:"region"`
 	WaitPeriod         time.Duration `json:"wait_period"`
@@ -30,18 +36,49 @@ type NodeConfig struct {
 	User               string
 	Password           string
 	SshKey             string
+	SshPort            int
+	JumpHost           string
 	IPs                []string
 	MFA                string
 	SudoPassword       string
 	RemoveExistingPkgs bool
 }
 
+// HttpProxy is the proxy of pf9-comms, as stored in comms_proxy_cfg.json
 type HttpProxy struct {
 	Protocol string `json:"protocol"`
 	Host     string `json:"host"`
 	Port     string `json:"port"`
-	User     string `json:"user"`
-	Pass     string `json:"pass"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	NoProxy  string `json:"no_proxy,omitempty"`
+}
+
+// MarshalJSON writes the port as a number, as pf9-comms expects it
+func (p HttpProxy) MarshalJSON() ([]byte, error) {
+	type httpProxy HttpProxy
+	port, err := strconv.Atoi(p.Port)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid proxy port %q", p.Port)
+	}
+	return json.Marshal(struct {
+		httpProxy
+		Port int `json:"port"`
+	}{httpProxy(p), port})
+}
+
+// UnmarshalJSON reads the port as a number or a string
+func (p *HttpProxy) UnmarshalJSON(data []byte) error {
+	type httpProxy HttpProxy
+	aux := struct {
+		*httpProxy
+		Port json.Number `json:"port"`
+	}{httpProxy: (*httpProxy)(p)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	p.Port = aux.Port.String()
+	return nil
 }
 
 type ProxySetting struct {
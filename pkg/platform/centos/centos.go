@@ -16,28 +16,42 @@ import (
 )
 
 var (
-	packages                   = []string{"ntp", "curl", "policycoreutils", "policycoreutils-python", "selinux-policy", "selinux-policy-targeted", "libselinux-utils", "net-tools"}
-	packageInstallError        = "Packages not found and could not be installed"
-	MissingPkgsInstalledCentos bool
-	centos                     bool
-	version                    string
-	k8sPresentError            = errors.New("A Kubernetes cluster is already running on node")
+	packages            = []string{"ntp", "curl", "policycoreutils", "policycoreutils-python", "selinux-policy", "selinux-policy-targeted", "libselinux-utils", "net-tools"}
+	packageInstallError = "Packages not found and could not be installed"
+	k8sPresentError     = errors.New("A Kubernetes cluster is already running on node")
 )
 
 // CentOS reprents centos based host machine
 type CentOS struct {
 	exec cmdexec.Executor
+	// centos and version are set by Version
+	centos  bool
+	version string
+	// missingPkgsInstalled is set when Check installed missing OS packages
+	missingPkgsInstalled bool
 }
 
 // NewCentOS creates and returns a new instance of CentOS
 func NewCentOS(exec cmdexec.Executor) *CentOS {
-	return &CentOS{exec}
+	return &CentOS{exec: exec}
+}
+
+// MissingPkgsInstalled reports whether Check installed missing OS packages
+func (c *CentOS) MissingPkgsInstalled() bool {
+	return c.missingPkgsInstalled
 }
 
 // Check inspects if a host machine meets all the requirements to be a cluster node
 func (c *CentOS) Check() []platform.Check {
 	var checks []platform.Check
 
+	// The package and repository checks depend on the OS version
+	if c.version == "" {
+		if _, err := c.Version(); err != nil {
+			zap.S().Debugf("Unable to get the OS version: %s", err)
+		}
+	}
+
 	result, err := c.RemovePyCli()
 	checks = append(checks, platform.Check{"Removal of existing CLI", false, result, err, util.PyCliErr})
 
@@ -135,15 +149,15 @@ func (c *CentOS) CheckOSPackages() (bool, error) {
 	errLines := []string{packageInstallError}
 	zap.S().Debug("Checking OS Packages")
 
-	rhel8, _ = regexp.MatchString(`.*8\.([5-9]|1[0])\.*`, string(version))
-	rocky9, _ = regexp.MatchString(`.*9\.[1-5]\.*`, string(version))
+	rhel8, _ = regexp.MatchString(`.*8\.([5-9]|1[0])\.*`, string(c.version))
+	rocky9, _ = regexp.MatchString(`.*9\.[1-5]\.*`, string(c.version))
 
 	if platform.SkipOSChecks {
-		rhel8, _ = regexp.MatchString(`8\.\d{1,2}`, string(version))
-		rocky9, _ = regexp.MatchString(`9\.\d{1,2}`, string(version))
+		rhel8, _ = regexp.MatchString(`8\.\d{1,2}`, string(c.version))
+		rocky9, _ = regexp.MatchString(`9\.\d{1,2}`, string(c.version))
 	}
 	for _, p := range packages {
-		if !centos && (rhel8 || rocky9) {
+		if !c.centos && (rhel8 || rocky9) {
 			switch p {
 			case "policycoreutils-python":
 				if rhel8 {
@@ -165,7 +179,7 @@ func (c *CentOS) CheckOSPackages() (bool, error) {
 				zap.S().Debugf("Error installing package %s: %s", p, err)
 				errLines = append(errLines, p)
 			} else {
-				MissingPkgsInstalledCentos = true
+				c.missingPkgsInstalled = true
 				zap.S().Debugf("Missing package %s installed", p)
 			}
 		}
@@ -180,12 +194,12 @@ func (c *CentOS) CheckOSPackages() (bool, error) {
 func (c *CentOS) CheckEnabledRepos() (bool, error) {
 
 	var centos, rhel8 bool
-	centos, _ = regexp.MatchString(`.*7\.[3-9]\.*`, string(version))
-	rhel8, _ = regexp.MatchString(`.*8\.([5-9]|1[0])\.*`, string(version))
+	centos, _ = regexp.MatchString(`.*7\.[3-9]\.*`, string(c.version))
+	rhel8, _ = regexp.MatchString(`.*8\.([5-9]|1[0])\.*`, string(c.version))
 
 	if platform.SkipOSChecks {
-		centos, _ = regexp.MatchString(`7\.\d{1,2}`, string(version))
-		rhel8, _ = regexp.MatchString(`8\.\d{1,2}`, string(version))
+		centos, _ = regexp.MatchString(`7\.\d{1,2}`, string(c.version))
+		rhel8, _ = regexp.MatchString(`8\.\d{1,2}`, string(c.version))
 	}
 
 	output, err := c.exec.RunWithStdout("bash", "-c", "yum repolist")
@@ -365,24 +379,24 @@ func (c *CentOS) Version() (string, error) {
 	if err != nil {
 		cmd = fmt.Sprintf("grep -oP '(?<=^VERSION_ID=).+' /etc/os-release")
 	} else {
-		centos = true
+		c.centos = true
 		cmd = fmt.Sprintf("cat /etc/*release | grep 'CentOS Linux release' -m 1 | cut -f4 -d ' '")
 	}
 
-	version, err = c.exec.RunWithStdout("bash", "-c", cmd)
+	c.version, err = c.exec.RunWithStdout("bash", "-c", cmd)
 	if err != nil {
 		return "", fmt.Errorf("Couldn't read the OS configuration file os-release: %s", err.Error())
 	}
-	if centos {
+	if c.centos {
 		//comparing because we are not supporting centos 8.x
-		if match, _ := regexp.MatchString(`.*7\.[3-9]\.*`, string(version)); match {
+		if match, _ := regexp.MatchString(`.*7\.[3-9]\.*`, string(c.version)); match {
 			return "redhat", nil
 		}
 	}
-	if match, _ := regexp.MatchString(`.*7\.[3-9]\.*|.*8\.([5-9]|1[0])\.*|.*9\.[1-5]\.*`, string(version)); match {
+	if match, _ := regexp.MatchString(`.*7\.[3-9]\.*|.*8\.([5-9]|1[0])\.*|.*9\.[1-5]\.*`, string(c.version)); match {
 		return "redhat", nil
 	}
-	return "", fmt.Errorf("Unable to determine OS type: %s", string(version))
+	return "", fmt.Errorf("Unable to determine OS type: %s", string(c.version))
 
 }
 
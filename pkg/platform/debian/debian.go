@@ -15,20 +15,26 @@ import (
 )
 
 var (
-	packages                   = []string{"curl", "uuid-runtime", "net-tools"}
-	packageInstallError        = "Packages not found and could not be installed"
-	MissingPkgsInstalledDebian bool
-	k8sPresentError            = errors.New("A Kubernetes cluster is already running on node")
+	packages            = []string{"curl", "uuid-runtime", "net-tools"}
+	packageInstallError = "Packages not found and could not be installed"
+	k8sPresentError     = errors.New("A Kubernetes cluster is already running on node")
 )
 
 // Debian represents debian based host machine
 type Debian struct {
 	exec cmdexec.Executor
+	// missingPkgsInstalled is set when Check installed missing OS packages
+	missingPkgsInstalled bool
 }
 
 // NewDebian creates and returns a new instance of Debian
 func NewDebian(exec cmdexec.Executor) *Debian {
-	return &Debian{exec}
+	return &Debian{exec: exec}
+}
+
+// MissingPkgsInstalled reports whether Check installed missing OS packages
+func (d *Debian) MissingPkgsInstalled() bool {
+	return d.missingPkgsInstalled
 }
 
 // Check inspects if a host machine meets all the requirements to be a cluster node
@@ -146,7 +152,7 @@ func (d *Debian) checkOSPackages() (bool, error) {
 				zap.S().Debugf("Error installing package %s: %s", p, err)
 				errLines = append(errLines, p)
 			} else {
-				MissingPkgsInstalledDebian = true
+				d.missingPkgsInstalled = true
 				zap.S().Debugf("Missing package %s installed", p)
 			}
 		}
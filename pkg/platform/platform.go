@@ -7,4 +7,6 @@ type Platform interface {
 	Version() (string, error)
 	CheckExistingInstallation() (bool, error)
 	CheckKubernetesCluster() (bool, error)
+	// MissingPkgsInstalled reports whether Check installed missing OS packages
+	MissingPkgsInstalled() bool
 }
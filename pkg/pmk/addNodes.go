@@ -0,0 +1,36 @@
+package pmk
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"go.uber.org/zap"
+)
+
+// Interval between two polls of resmgr while waiting for a host
+var hostPollInterval = 10 * time.Second
+
+// WaitForHost polls resmgr until the host with the IP is known and responding,
+// it returns the ID of the host. The last error is returned when the timeout runs out.
+func WaitForHost(c client.Client, ip string, keystoneAuth keystone.KeystoneAuth, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		var hostID string
+		hostIDs, err := c.Resmgr.GetHostId(keystoneAuth.Token, []string{ip})
+		if err == nil {
+			hostID = hostIDs[0]
+			if err = c.Resmgr.HostStatus(keystoneAuth.Token, hostID); err == nil {
+				return hostID, nil
+			}
+		}
+		zap.S().Debugf("Host %s is not ready: %s", ip, err.Error())
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return "", fmt.Errorf("Timed out after %s waiting for host %s to respond: %w", timeout, ip, err)
+		}
+		time.Sleep(min(hostPollInterval, remaining))
+	}
+}
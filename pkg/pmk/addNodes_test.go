@@ -0,0 +1,57 @@
+package pmk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/fakedu"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForHost(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+	interval := hostPollInterval
+	t.Cleanup(func() { hostPollInterval = interval })
+	hostPollInterval = time.Millisecond
+
+	c, err := client.NewClient(du.URL, nil, true, true)
+	assert.Nil(t, err)
+	auth, err := c.Keystone.GetAuth(fakedu.DefaultUsername, fakedu.DefaultPassword, fakedu.DefaultTenant, "")
+	assert.Nil(t, err)
+
+	du.AddHost("host-ready", "ready", "10.0.0.1")
+	du.AddHost("host-down", "down", "10.0.0.2")
+	du.SetResponding("host-down", false)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		du.AddHost("host-late", "late", "10.0.0.3")
+	}()
+
+	cases := map[string]struct {
+		ip      string
+		timeout time.Duration
+		id      string
+		err     error
+	}{
+		"responding":     {ip: "10.0.0.1", timeout: time.Second, id: "host-ready"},
+		"registers late": {ip: "10.0.0.3", timeout: time.Second, id: "host-late"},
+		"not responding": {ip: "10.0.0.2", timeout: 10 * time.Millisecond, err: resmgr.ErrHostNotResponding},
+		"unknown":        {ip: "10.0.0.9", timeout: 10 * time.Millisecond, err: resmgr.ErrHostNotFound},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			id, err := WaitForHost(c, tc.ip, auth, tc.timeout)
+			if tc.err == nil {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.id, id)
+			} else {
+				assert.True(t, errors.Is(err, tc.err))
+				assert.ErrorContains(t, err, "Timed out")
+			}
+		})
+	}
+}
@@ -4,12 +4,12 @@ package pmk
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/briandowns/spinner"
 	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
 	"github.com/platform9/pf9ctl/pkg/color"
 	"github.com/platform9/pf9ctl/pkg/keystone"
 	"github.com/platform9/pf9ctl/pkg/objects"
@@ -32,6 +32,7 @@ const (
 	RequiredFail     CheckNodeResult = "requiredFail"
 	OptionalFail     CheckNodeResult = "optionalFail"
 	CleanInstallFail CheckNodeResult = "cleanInstallFail"
+	AlreadyConnected CheckNodeResult = "alreadyConnected"
 )
 
 /*
@@ -41,31 +42,28 @@ when user passes --skip-checks and optional checks fails.
 */
 var WarningOptionalChecks bool
 
-// CheckNode checks the prerequisites for k8s stack
-func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.KeystoneAuth, nc objects.NodeConfig) (CheckNodeResult, error) {
+// CheckNode checks the prerequisites for k8s stack, it returns the overall result
+// along with the result of every check.
+func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.KeystoneAuth, nc objects.NodeConfig) (CheckNodeResult, []platform.Check, error) {
 	// Building our new spinner
-	s := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
+	out := allClients.Output()
+	s := spinner.New(spinner.CharSets[9], 100*time.Millisecond, spinner.WithWriter(out))
 	s.Color("red")
 
 	zap.S().Debug("Received a call to check node.")
 
 	isSudo := CheckSudo(allClients.Executor)
 	if !isSudo {
-		return RequiredFail, fmt.Errorf("User executing this CLI is not allowed to switch to privileged (sudo) mode")
+		return RequiredFail, nil, fmt.Errorf("User executing this CLI is not allowed to switch to privileged (sudo) mode")
 	}
 	hostOS, err := ValidatePlatform(allClients.Executor)
 	if err != nil {
-		return RequiredFail, err
+		return RequiredFail, nil, err
 	}
 
-	var platform platform.Platform
-	switch hostOS {
-	case "debian":
-		platform = debian.NewDebian(allClients.Executor)
-	case "redhat":
-		platform = centos.NewCentOS(allClients.Executor)
-	default:
-		return RequiredFail, fmt.Errorf("This OS is not supported. Supported operating systems are: Ubuntu (20.04, 22.04,24.04), CentOS 7.[3-9], RHEL 7.[3-9], RHEL 8.[5-10] & Rocky 9.[1-5]")
+	platform, err := newPlatform(hostOS, allClients.Executor)
+	if err != nil {
+		return RequiredFail, nil, err
 	}
 
 	if err = allClients.Segment.SendEvent("Starting CheckNode", auth, checkPass, ""); err != nil {
@@ -75,7 +73,7 @@ func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.Keyst
 	s.Start() // Start the spinner
 	defer s.Stop()
 	zap.S().Debug("Running pre-requisite checks and installing any missing OS packages")
-	s.Suffix = " Running pre-requisite checks and installing any missing OS packages"
+	setSpinnerSuffix(s, " Running pre-requisite checks and installing any missing OS packages")
 	checks := platform.Check()
 	s.Stop()
 
@@ -97,12 +95,12 @@ func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.Keyst
 					hostID = strings.TrimSpace(hostID)
 					connected := false
 					if len(hostID) != 0 {
-						connected = allClients.Resmgr.HostStatus(auth.Token, hostID)
+						connected = allClients.Resmgr.HostStatus(auth.Token, hostID) == nil
 					}
 					if connected {
 						zap.S().Debug("Node is already connected")
-						zap.S().Info(color.Green("✓ ") + "Node is already connected\n")
-						os.Exit(0)
+						fmt.Fprintln(out, color.Green("✓ ")+"Node is already connected")
+						return AlreadyConnected, checks, nil
 					} else {
 						//case where hostagent is installed but host is in disconnected sate
 						zap.S().Debug("Hostagent is installed but host is not connected to DU. Installing hostagent again")
@@ -115,8 +113,8 @@ func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.Keyst
 		}
 	}
 	//We will print console if any missing os packages installed
-	if debian.MissingPkgsInstalledDebian || centos.MissingPkgsInstalledCentos {
-		fmt.Printf(color.Green("✓ ") + "Missing package(s) installed successfully\n")
+	if platform.MissingPkgsInstalled() {
+		fmt.Fprintf(out, color.Green("✓ ")+"Missing package(s) installed successfully\n")
 	}
 
 	mandatoryCheck := true
@@ -129,7 +127,7 @@ func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.Keyst
 			if err := allClients.Segment.SendEvent(segment_str, auth, checkPass, ""); err != nil {
 				zap.S().Debugf("Unable to send Segment event for check node. Error: %s", err.Error())
 			}
-			fmt.Printf(color.Green("✓ ")+"%s\n", check.Name)
+			fmt.Fprintf(out, color.Green("✓ ")+"%s\n", check.Name)
 
 		} else {
 			segment_str := "CheckNode: " + check.Name
@@ -138,9 +136,9 @@ func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.Keyst
 			}
 			// To print warning "!", if --skipchecks flag passed and optional checks failed.
 			if WarningOptionalChecks && !check.Mandatory {
-				fmt.Printf(color.Yellow("! ")+"%s - %s\n", check.Name, check.UserErr)
+				fmt.Fprintf(out, color.Yellow("! ")+"%s - %s\n", check.Name, check.UserErr)
 			} else {
-				fmt.Printf(color.Red("x ")+"%s - %s\n", check.Name, check.UserErr)
+				fmt.Fprintf(out, color.Red("x ")+"%s - %s\n", check.Name, check.UserErr)
 			}
 
 			if check.Mandatory {
@@ -162,35 +160,64 @@ func CheckNode(ctx objects.Config, allClients client.Client, auth keystone.Keyst
 	if err = allClients.Segment.SendEvent("CheckNode complete", auth, checkPass, ""); err != nil {
 		zap.S().Debugf("Unable to send Segment event for check node. Error: %s", err.Error())
 	}
-	fmt.Printf("\n")
+	fmt.Fprintf(out, "\n")
 	if mandatoryCheck {
-		fmt.Println(color.Green("✓ ") + "Completed Pre-Requisite Checks successfully\n")
+		fmt.Fprintln(out, color.Green("✓ ")+"Completed Pre-Requisite Checks successfully\n")
 		zap.S().Debug("Completed Pre-Requisite Checks successfully")
 	}
 
 	removeCurrentInstallation := ""
 	if !cleanInstallCheck {
 		if !WarningOptionalChecks {
-			fmt.Println(color.Yellow("\nPrevious installation found"))
+			fmt.Fprintln(out, color.Yellow("\nPrevious installation found"))
 			if !nc.RemoveExistingPkgs {
-				fmt.Println(color.Yellow("Reinstall Required..."))
-				fmt.Print("Remove Current Installation Type ('yes'/'no'):")
+				fmt.Fprintln(out, color.Yellow("Reinstall Required..."))
+				fmt.Fprint(out, "Remove Current Installation Type ('yes'/'no'):")
 				fmt.Scanf("%s", &removeCurrentInstallation)
 			}
 			if nc.RemoveExistingPkgs || strings.ToLower(removeCurrentInstallation) == "yes" {
-				DecommissionNode(&ctx, nc, false)
-				return CleanInstallFail, nil
+				if err := DecommissionNode(&ctx, nc, false); err != nil {
+					return CleanInstallFail, checks, err
+				}
+				return CleanInstallFail, checks, nil
 			}
 		}
-		return OptionalFail, nil
+		return OptionalFail, checks, nil
 	}
 
 	if !mandatoryCheck {
-		return RequiredFail, nil
+		return RequiredFail, checks, nil
 	} else if !optionalCheck {
-		return OptionalFail, nil
+		return OptionalFail, checks, nil
 	} else {
-		return PASS, nil
+		return PASS, checks, nil
+	}
+
+}
+
+func newPlatform(hostOS string, exec cmdexec.Executor) (platform.Platform, error) {
+	switch hostOS {
+	case "debian":
+		return debian.NewDebian(exec), nil
+	case "redhat":
+		return centos.NewCentOS(exec), nil
 	}
+	return nil, fmt.Errorf("This OS is not supported. Supported operating systems are: Ubuntu (20.04, 22.04,24.04), CentOS 7.[3-9], RHEL 7.[3-9], RHEL 8.[5-10] & Rocky 9.[1-5]")
+}
 
+// RunChecks only runs the pre-requisite checks of the host, unlike CheckNode
+// it does not act on the results.
+func RunChecks(exec cmdexec.Executor) ([]platform.Check, error) {
+	if !CheckSudo(exec) {
+		return nil, fmt.Errorf("User executing this CLI is not allowed to switch to privileged (sudo) mode")
+	}
+	hostOS, err := ValidatePlatform(exec)
+	if err != nil {
+		return nil, err
+	}
+	platform, err := newPlatform(hostOS, exec)
+	if err != nil {
+		return nil, err
+	}
+	return platform.Check(), nil
 }
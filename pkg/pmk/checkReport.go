@@ -0,0 +1,149 @@
+package pmk
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported machine readable output formats
+const (
+	OutputText  = "text"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+	OutputJUnit = "junit"
+)
+
+var OutputFormats = []string{OutputText, OutputJSON, OutputYAML, OutputJUnit}
+
+// CheckReport is the outcome of check-node on one host
+type CheckReport struct {
+	Host   string          `json:"host" yaml:"host"`
+	Result CheckNodeResult `json:"result" yaml:"result"`
+	Error  string          `json:"error,omitempty" yaml:"error,omitempty"`
+	Checks []CheckStatus   `json:"checks" yaml:"checks"`
+}
+
+// CheckStatus is a platform.Check which can be serialized
+type CheckStatus struct {
+	Name      string `json:"name" yaml:"name"`
+	Mandatory bool   `json:"mandatory" yaml:"mandatory"`
+	Result    bool   `json:"result" yaml:"result"`
+	UserErr   string `json:"userError,omitempty" yaml:"userError,omitempty"`
+	Err       string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// NewCheckReport builds the report of a check-node run
+func NewCheckReport(host string, result CheckNodeResult, checks []platform.Check, err error) CheckReport {
+	report := CheckReport{Host: host, Result: result, Checks: []CheckStatus{}}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	for _, check := range checks {
+		status := CheckStatus{Name: check.Name, Mandatory: check.Mandatory, Result: check.Result, UserErr: check.UserErr}
+		if check.Err != nil {
+			status.Err = check.Err.Error()
+		}
+		report.Checks = append(report.Checks, status)
+	}
+	return report
+}
+
+// ValidateOutputFormat makes sure the format is one of the supported ones
+func ValidateOutputFormat(format string) error {
+	if !containsString(OutputFormats, format) {
+		return fmt.Errorf("Invalid output format %q, supported formats are %s", format, strings.Join(OutputFormats, ", "))
+	}
+	return nil
+}
+
+// WriteCheckReports renders the reports in a machine readable format
+func WriteCheckReports(w io.Writer, format string, reports []CheckReport) error {
+	switch format {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	case OutputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		return encoder.Encode(reports)
+	case OutputJUnit:
+		return writeJUnit(w, reports)
+	}
+	return ValidateOutputFormat(format)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit renders a test suite per host and a test case per check
+func writeJUnit(w io.Writer, reports []CheckReport) error {
+	suites := junitTestSuites{}
+	for _, report := range reports {
+		suite := junitTestSuite{Name: "check-node " + report.Host}
+		className := "check-node." + strings.ReplaceAll(report.Host, ".", "_")
+		if report.Error != "" {
+			suite.Errors++
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      "Run check-node",
+				ClassName: className,
+				Error:     &junitFailure{Message: report.Error, Type: "error"},
+			})
+		}
+		for _, check := range report.Checks {
+			tc := junitTestCase{Name: check.Name, ClassName: className}
+			if !check.Result {
+				failureType := "optional"
+				if check.Mandatory {
+					failureType = "mandatory"
+				}
+				suite.Failures++
+				tc.Failure = &junitFailure{Message: check.UserErr, Type: failureType, Text: check.Err}
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
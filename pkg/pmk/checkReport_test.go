@@ -0,0 +1,122 @@
+package pmk
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/platform"
+	"github.com/stretchr/testify/assert"
+)
+
+var reportChecks = []platform.Check{
+	{Name: "Sudo Access Check", Mandatory: true, Result: true},
+	{Name: "Disk Space Check", Mandatory: true, Result: false, Err: errors.New("df reported 12G"), UserErr: "At least 30 GB of total disk space is needed"},
+	{Name: "Swap Check", Mandatory: false, Result: false, UserErr: "Swap should be disabled"},
+}
+
+func TestWriteCheckReports(t *testing.T) {
+	reports := []CheckReport{
+		NewCheckReport("10.0.0.1", RequiredFail, reportChecks, nil),
+		NewCheckReport("10.0.0.2", RequiredFail, nil, errors.New("unable to dial 10.0.0.2:22")),
+	}
+
+	cases := map[string]struct {
+		format string
+		want   string
+	}{
+		"JSON": {
+			format: OutputJSON,
+			want: `[
+  {
+    "host": "10.0.0.1",
+    "result": "requiredFail",
+    "checks": [
+      {
+        "name": "Sudo Access Check",
+        "mandatory": true,
+        "result": true
+      },
+      {
+        "name": "Disk Space Check",
+        "mandatory": true,
+        "result": false,
+        "userError": "At least 30 GB of total disk space is needed",
+        "error": "df reported 12G"
+      },
+      {
+        "name": "Swap Check",
+        "mandatory": false,
+        "result": false,
+        "userError": "Swap should be disabled"
+      }
+    ]
+  },
+  {
+    "host": "10.0.0.2",
+    "result": "requiredFail",
+    "error": "unable to dial 10.0.0.2:22",
+    "checks": []
+  }
+]
+`,
+		},
+		"YAML": {
+			format: OutputYAML,
+			want: `- host: 10.0.0.1
+  result: requiredFail
+  checks:
+    - name: Sudo Access Check
+      mandatory: true
+      result: true
+    - name: Disk Space Check
+      mandatory: true
+      result: false
+      userError: At least 30 GB of total disk space is needed
+      error: df reported 12G
+    - name: Swap Check
+      mandatory: false
+      result: false
+      userError: Swap should be disabled
+- host: 10.0.0.2
+  result: requiredFail
+  error: unable to dial 10.0.0.2:22
+  checks: []
+`,
+		},
+		"JUnit": {
+			format: OutputJUnit,
+			want: `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="check-node 10.0.0.1" tests="3" failures="2" errors="0">
+    <testcase name="Sudo Access Check" classname="check-node.10_0_0_1"></testcase>
+    <testcase name="Disk Space Check" classname="check-node.10_0_0_1">
+      <failure message="At least 30 GB of total disk space is needed" type="mandatory">df reported 12G</failure>
+    </testcase>
+    <testcase name="Swap Check" classname="check-node.10_0_0_1">
+      <failure message="Swap should be disabled" type="optional"></failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="check-node 10.0.0.2" tests="1" failures="0" errors="1">
+    <testcase name="Run check-node" classname="check-node.10_0_0_2">
+      <error message="unable to dial 10.0.0.2:22" type="error"></error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.Nil(t, WriteCheckReports(&buf, tc.format, reports))
+			assert.Equal(t, tc.want, buf.String())
+		})
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	assert.Nil(t, ValidateOutputFormat(OutputJSON))
+	assert.NotNil(t, ValidateOutputFormat("xml"))
+}
@@ -19,8 +19,60 @@ import (
 	"go.uber.org/zap"
 )
 
+// Time given to the host to settle after authorization before it is attached
+var attachWaitPeriod = 30 * time.Second
+
+// Bounds of the exponential backoff between two polls of the cluster status
+var (
+	clusterPollInterval    = 5 * time.Second
+	clusterPollMaxInterval = time.Minute
+)
+
+// WaitForCluster polls the status of the cluster with an exponential backoff until
+// it is ok, fails or the timeout runs out. Phase transitions are printed.
+func WaitForCluster(c client.Client, name, clusterID string, keystoneAuth keystone.KeystoneAuth, timeout time.Duration) error {
+	out := c.Output()
+	deadline := time.Now().Add(timeout)
+	interval := clusterPollInterval
+	phase := ""
+	for {
+		status, err := c.Qbert.GetClusterStatus(clusterID, keystoneAuth.ProjectID, keystoneAuth.Token)
+		if err != nil {
+			// The status is polled again, qbert may be briefly unavailable
+			zap.S().Debugf("Unable to get the status of cluster %s: %s", name, err.Error())
+		} else if status.Phase() != phase {
+			phase = status.Phase()
+			zap.S().Debugf("Cluster %s is %s (status %s, task status %s)", name, phase, status.Status, status.TaskStatus)
+			switch phase {
+			case qbert.ClusterOk:
+				fmt.Fprintln(out, color.Green("✓")+" Cluster "+name+" is ok")
+				return nil
+			case qbert.ClusterError:
+				fmt.Fprintln(out, color.Red("x")+" Cluster "+name+" is in error")
+				if status.TaskError != "" {
+					return fmt.Errorf("Cluster %s failed: %s", name, status.TaskError)
+				}
+				return fmt.Errorf("Cluster %s failed", name)
+			default:
+				fmt.Fprintln(out, "  Cluster "+name+" is "+phase)
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if phase == "" {
+				return fmt.Errorf("Timed out after %s waiting for cluster %s: %w", timeout, name, err)
+			}
+			return fmt.Errorf("Timed out after %s waiting for cluster %s, it is still %s", timeout, name, phase)
+		}
+		time.Sleep(min(interval, remaining))
+		interval = min(2*interval, clusterPollMaxInterval)
+	}
+}
+
 // Bootstrap simply onboards the local node and attaches it as master to a newly created cluster.
-func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateRequest, keystoneAuth keystone.KeystoneAuth, bootConfig objects.NodeConfig) error {
+// It returns the ID of the cluster.
+func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateRequest, keystoneAuth keystone.KeystoneAuth, bootConfig objects.NodeConfig) (string, error) {
 
 	if err1 := c.Segment.SendEvent("Starting Cluster creation(Bootstrap)", keystoneAuth, checkPass, ""); err1 != nil {
 		zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err1.Error())
@@ -33,7 +85,7 @@ func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateReque
 	s.Color("red")
 	s.Start() // Start the spinner
 	defer s.Stop()
-	s.Suffix = clustername
+	setSpinnerSuffix(s, clustername)
 
 	clusterID, err := c.Qbert.CreateCluster(
 		req,
@@ -48,7 +100,7 @@ func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateReque
 		if err = c.Segment.SendEvent("Cluster creation(Bootstrap)", keystoneAuth, checkFail, ""); err != nil {
 			zap.S().Debugf("Unable to send Segment event for bootstrap node. Error: %s", err.Error())
 		}
-		return fmt.Errorf("Unable to create cluster " + req.Name)
+		return "", fmt.Errorf("Unable to create cluster " + req.Name)
 	}
 
 	fmt.Println(color.Green("✓") + " Cluster creation completed")
@@ -60,20 +112,19 @@ func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateReque
 	s.Color("red")
 	s.Start() // Start the spinner
 	defer s.Stop()
-	s.Suffix = " Checking Host Status"
+	setSpinnerSuffix(s, " Checking Host Status")
 	zap.S().Debug("Checking Host Status")
 	cmd := `grep ^host_id /etc/pf9/host_id.conf | cut -d = -f2 | cut -d ' ' -f2`
 	output, err := c.Executor.RunWithStdout("bash", "-c", cmd)
 	if err != nil {
-		return fmt.Errorf("Unable to execute command: %w", err)
+		return "", fmt.Errorf("Unable to execute command: %w", err)
 	}
 	nodeID := strings.TrimSpace(string(output))
 
 	LoopVariable := 1
 	for LoopVariable <= util.MaxLoopValue {
-		hostStatus := c.Resmgr.HostStatus(token, nodeID)
-		if !hostStatus {
-			zap.S().Debugf("Host is Down...Trying again")
+		if err := c.Resmgr.HostStatus(token, nodeID); err != nil {
+			zap.S().Debugf("Host is Down...Trying again: %s", err)
 		} else {
 			util.HostDown = false
 			break
@@ -102,16 +153,16 @@ func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateReque
 		}
 		//Deleting the cluster if the host is disconnected
 		DeleteClusterBootstrap(clusterID, c, keystoneAuth, token)
-		return fmt.Errorf("Host is disconnected. Unable to attach this node to the cluster " + req.Name + " Run prep-node/authorize-node and try again")
+		return "", fmt.Errorf("Host is disconnected. Unable to attach this node to the cluster " + req.Name + " Run prep-node/authorize-node and try again")
 	}
 
 	attachname := fmt.Sprintf(" Attaching node to the cluster %s", req.Name)
 	s.Color("red")
 	s.Start() // Start the spinner
 	defer s.Stop()
-	s.Suffix = attachname
+	setSpinnerSuffix(s, attachname)
 	zap.S().Debug(attachname)
-	time.Sleep(30 * time.Second)
+	time.Sleep(attachWaitPeriod)
 	var nodeIDs []string
 	nodeIDs = append(nodeIDs, nodeID)
 
@@ -131,7 +182,7 @@ func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateReque
 		//Deleting the cluster if the node is not attached to the cluster
 		DeleteClusterBootstrap(clusterID, c, keystoneAuth, token)
 		zap.S().Debug("Unable to attach node to cluster " + req.Name + "Run bootstrap again")
-		return fmt.Errorf("Unable to attach node to cluster " + req.Name + "Run bootstrap again")
+		return "", fmt.Errorf("Unable to attach node to cluster " + req.Name + "Run bootstrap again")
 	}
 
 	fmt.Println(color.Green("✓") + " Attached node to the cluster")
@@ -145,9 +196,7 @@ func Bootstrap(ctx objects.Config, c client.Client, req qbert.ClusterCreateReque
 	}
 	fmt.Println(color.Green("✓") + " Bootstrap successfully finished")
 	zap.S().Debug("Bootstrap successfully finished")
-	zap.S().Debug("Cluster creation started....This may take a few minutes....Check the latest status in UI")
-	fmt.Println("Cluster creation started....This may take a few minutes....Check the latest status in UI")
-	return nil
+	return clusterID, nil
 }
 
 //Checks Prerequisites for Bootstrap Command
@@ -155,7 +204,7 @@ func PreReqBootstrap(executor cmdexec.Executor) (bool, bool, error) {
 
 	os, err := ValidatePlatform(executor)
 	if err != nil {
-		zap.S().Fatalf("OS version is not supported")
+		return false, false, fmt.Errorf("OS version is not supported: %w", err)
 	}
 
 	var Instance platform.Platform
@@ -171,14 +220,12 @@ func PreReqBootstrap(executor cmdexec.Executor) (bool, bool, error) {
 
 	val, err := Instance.CheckExistingInstallation()
 	if err != nil {
-		//zap.S().Fatalf("Could not run command Installation")
-		zap.S().Fatalf("Error %s", err)
+		return false, false, fmt.Errorf("Unable to check the existing installation: %w", err)
 	}
 
 	val1, err1 := Instance.CheckKubernetesCluster()
 	if err1 != nil {
-		//zap.S().Fatalf("Could not run command Cluster")
-		zap.S().Fatalf("Error %s", err1)
+		return false, false, fmt.Errorf("Unable to check the existing kubernetes cluster: %w", err1)
 	}
 	return val, val1, nil
 }
@@ -0,0 +1,181 @@
+package pmk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"go.uber.org/zap"
+)
+
+// AttachNodes attaches the hosts with the IPs to the cluster with the name, which
+// must be ok. The hosts already attached to a cluster are skipped.
+func AttachNodes(c client.Client, keystoneAuth keystone.KeystoneAuth, clusterName string, masterIPs, workerIPs []string) error {
+	projectID, token := keystoneAuth.ProjectID, keystoneAuth.Token
+	_, clusterID, clusterStatus, err := c.Qbert.CheckClusterExists(clusterName, projectID, token)
+	if err != nil {
+		return fmt.Errorf("Unable to fetch cluster-uuid and cluster-status from cluster-name: %w", err)
+	}
+	if clusterStatus != "ok" {
+		return fmt.Errorf("Cluster is not ready. cluster status is %v", clusterStatus)
+	}
+
+	var masterHostIDs, workerHostIDs []string
+	if len(masterIPs) > 0 {
+		if masterHostIDs, err = c.Resmgr.GetHostId(token, masterIPs); err != nil {
+			return err
+		}
+	}
+	if len(workerIPs) > 0 {
+		if workerHostIDs, err = c.Resmgr.GetHostId(token, workerIPs); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Segment.SendEvent("Starting Attach-node", keystoneAuth, "", ""); err != nil {
+		zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
+	}
+	if err := attachNodesWithRole(c, keystoneAuth, clusterName, clusterID, workerHostIDs, "worker"); err != nil {
+		return err
+	}
+	return attachNodesWithRole(c, keystoneAuth, clusterName, clusterID, masterHostIDs, "master")
+}
+
+// attachNodesWithRole attaches the hosts which are not in a cluster yet with the
+// role, master or worker
+func attachNodesWithRole(c client.Client, keystoneAuth keystone.KeystoneAuth, clusterName, clusterID string, hostIDs []string, role string) error {
+	if len(hostIDs) == 0 {
+		return nil
+	}
+	fmt.Fprintf(c.Output(), "Attaching node to the cluster %s\n", clusterName)
+	var ids []string
+	for _, hostID := range hostIDs {
+		node, err := c.Qbert.GetNodeInfo(keystoneAuth.Token, keystoneAuth.ProjectID, hostID)
+		if err != nil {
+			return fmt.Errorf("Failed to get node info for host %s: %w", hostID, err)
+		}
+		if node.ClusterName != "" {
+			zap.S().Infof("Node with host id %s is connected to %s cluster", hostID, node.ClusterName)
+			continue
+		}
+		ids = append(ids, hostID)
+	}
+	if len(ids) == 0 {
+		zap.S().Infof("No %s node available to attach to the cluster", role)
+		return nil
+	}
+
+	label := strings.ToUpper(role[:1]) + role[1:]
+	if err := c.Qbert.AttachNode(clusterID, keystoneAuth.ProjectID, keystoneAuth.Token, ids, role); err != nil {
+		if err := c.Segment.SendEvent("Attaching-node", keystoneAuth, "Failed to attach "+role+" node", ""); err != nil {
+			zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
+		}
+		return fmt.Errorf("Encountered an error while attaching %s node to a Kubernetes cluster: %w", role, err)
+	}
+	if err := c.Segment.SendEvent("Attaching-node", keystoneAuth, label+" node attached", ""); err != nil {
+		zap.S().Debugf("Unable to send Segment event for attach node. Error: %s", err.Error())
+	}
+	zap.S().Infof("%s node(s) %v attached to cluster", label, ids)
+	return nil
+}
+
+// DetachNodes detaches the hosts with the IPs from their clusters, it fails if one
+// of them is not attached to a cluster.
+func DetachNodes(c client.Client, keystoneAuth keystone.KeystoneAuth, nodeIPs []string) error {
+	projectID, token := keystoneAuth.ProjectID, keystoneAuth.Token
+	projectNodes, err := c.Qbert.GetAllNodes(token, projectID)
+	if err != nil {
+		return fmt.Errorf("Unable to get the nodes: %w", err)
+	}
+	nodeUuids, err := c.Resmgr.GetHostId(token, nodeIPs)
+	if err != nil {
+		return err
+	}
+	detachNodes, err := getNodesFromUuids(nodeUuids, projectNodes)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.Output(), "Starting detaching process")
+	if err := c.Segment.SendEvent("Starting detach-node", keystoneAuth, "", ""); err != nil {
+		zap.S().Debugf("Unable to send Segment event for detach node. Error: %s", err.Error())
+	}
+
+	for _, node := range detachNodes {
+		clusterNodes := 0
+		for _, n := range projectNodes {
+			if n.ClusterUuid == node.ClusterUuid {
+				clusterNodes++
+			}
+		}
+		if clusterNodes == 1 || node.IsMaster == 1 {
+			fmt.Fprintf(c.Output(), "Node %v is either the master node or the last node in the cluster\n", node.Uuid)
+		}
+
+		if err := c.Qbert.DetachNode(node.ClusterUuid, projectID, token, node.Uuid); err != nil {
+			if err := c.Segment.SendEvent("Detaching-node", keystoneAuth, "Failed to detach node", ""); err != nil {
+				zap.S().Debugf("Unable to send Segment event for detach node. Error: %s", err.Error())
+			}
+			return fmt.Errorf("Encountered an error while detaching the %s node from a Kubernetes cluster: %w", node.PrimaryIp, err)
+		}
+		if err := c.Segment.SendEvent("Detaching-node", node.PrimaryIp, "Node detached", ""); err != nil {
+			zap.S().Debugf("Unable to send Segment event for detach node. Error: %s", err.Error())
+		}
+		zap.S().Infof("Node [%v] detached from cluster", node.Uuid)
+	}
+	return nil
+}
+
+// getNodesFromUuids returns the nodes with the IDs, it fails if one of them is not
+// attached to a cluster
+func getNodesFromUuids(nodeUuids []string, allNodes []qbert.Node) ([]qbert.Node, error) {
+	var nodes []qbert.Node
+	for _, node := range allNodes {
+		for _, uuid := range nodeUuids {
+			if uuid != node.Uuid {
+				continue
+			}
+			if node.ClusterUuid == "" {
+				return nodes, fmt.Errorf("The node %v is not connected to any clusters", node.PrimaryIp)
+			}
+			nodes = append(nodes, node)
+			break
+		}
+	}
+	return nodes, nil
+}
+
+// DeleteCluster deletes the cluster with the ID. When the host of the client
+// executor, with the IP localIP, is a node of the cluster its Kubernetes
+// processes are killed first.
+func DeleteCluster(c client.Client, keystoneAuth keystone.KeystoneAuth, clusterID, localIP string) error {
+	projectID, token := keystoneAuth.ProjectID, keystoneAuth.Token
+	hostIDs, err := c.Resmgr.GetHostId(token, []string{localIP})
+	switch {
+	case errors.Is(err, resmgr.ErrHostNotFound):
+		// The local host is not a Platform9 node
+	case err != nil:
+		return fmt.Errorf("Could not delete cluster, error while fetching nodes info: %w", err)
+	default:
+		node, err := c.Qbert.GetNodeInfo(token, projectID, hostIDs[0])
+		if err != nil {
+			return fmt.Errorf("Could not delete cluster, error while fetching nodes info: %w", err)
+		}
+		if node.ClusterUuid == clusterID {
+			c.Executor.RunCommandWait("sudo pkill -9 `pidof kubelet`")
+			c.Executor.RunCommandWait("sudo pkill -9 `pidof etcd`")
+			c.Executor.RunCommandWait("sudo pkill -9 `pidof kube-proxy`")
+		}
+	}
+
+	if err := c.Qbert.DeleteCluster(clusterID, projectID, token); err != nil {
+		return fmt.Errorf("Error deleting cluster: %w", err)
+	}
+	fmt.Fprintln(c.Output(), "Cluster deletion started....This may take a few minutes.")
+	zap.S().Debug("Cluster deletion started....This may take a few minutes.")
+	return nil
+}
@@ -0,0 +1,295 @@
+package pmk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ClusterSpec describes a cluster in a YAML or JSON file. The cluster fields use the
+// same keys as the qbert create cluster request, nodes are listed by role.
+type ClusterSpec struct {
+	qbert.ClusterCreateRequest
+	Monitoring bool      `json:"monitoring"`
+	Nodes      SpecNodes `json:"nodes"`
+}
+
+// SpecNodes lists the IPs of the nodes to be attached to the cluster once it is created
+type SpecNodes struct {
+	Masters []string `json:"masters"`
+	Workers []string `json:"workers"`
+}
+
+var (
+	supportedNetworkPlugins    = []string{string(Calico), string(Flannel)}
+	supportedContainerRuntimes = []string{"containerd", util.Docker}
+)
+
+// NewClusterSpec returns a spec filled with the same defaults bootstrap uses
+func NewClusterSpec() ClusterSpec {
+	return ClusterSpec{
+		ClusterCreateRequest: qbert.ClusterCreateRequest{
+			ContainerCIDR:         "10.20.0.0/16",
+			ServiceCIDR:           "10.21.0.0/16",
+			NetworkPlugin:         qbert.CNIBackend(Calico),
+			AllowWorkloadOnMaster: true,
+			Privileged:            true,
+			EnableProfileAgent:    true,
+			IPEncapsulation:       "Always",
+			InterfaceDetection:    "first-found",
+			MtuSize:               "1440",
+			BlockSize:             "26",
+			ContainerRuntime:      "containerd",
+			TopologyManagerPolicy: "none",
+			CalicoNatOutgoing:     1,
+			EtcdBackup: qbert.EtcdBackup{
+				StorageType:            "local",
+				IsEtcdBackupEnabled:    1,
+				StorageProperties:      qbert.Storageproperties{LocalPath: "/etc/pf9/etcd-backup"},
+				IntervalInMins:         30,
+				MaxIntervalBackupCount: 3,
+			},
+		},
+		Monitoring: true,
+	}
+}
+
+// LoadClusterSpec reads a cluster spec from a YAML or JSON file, fields missing
+// from the file keep their default values.
+func LoadClusterSpec(path string) (ClusterSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ClusterSpec{}, fmt.Errorf("Unable to read cluster spec %s: %w", path, err)
+	}
+	return ParseClusterSpec(data)
+}
+
+// ParseClusterSpec parses a YAML or JSON cluster spec
+func ParseClusterSpec(data []byte) (ClusterSpec, error) {
+	spec := NewClusterSpec()
+
+	// JSON is valid YAML, so decode everything as YAML and go through JSON
+	// to reuse the json tags of the qbert request.
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return spec, fmt.Errorf("Unable to parse cluster spec: %w", err)
+	}
+	byt, err := json.Marshal(raw)
+	if err != nil {
+		return spec, fmt.Errorf("Unable to parse cluster spec: %w", err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(byt)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return spec, fmt.Errorf("Unable to parse cluster spec: %w", err)
+	}
+	return spec, nil
+}
+
+// Validate checks the spec without contacting the controller
+func (s ClusterSpec) Validate() error {
+	var errs []string
+
+	if strings.TrimSpace(s.Name) == "" {
+		errs = append(errs, "name is required")
+	}
+	if s.PmkVersion == "" {
+		errs = append(errs, "kubeRoleVersion is required")
+	}
+
+	_, containersNet, err := net.ParseCIDR(s.ContainerCIDR)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("containersCidr %q is not a valid CIDR", s.ContainerCIDR))
+	}
+	_, servicesNet, err := net.ParseCIDR(s.ServiceCIDR)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("servicesCidr %q is not a valid CIDR", s.ServiceCIDR))
+	}
+	if containersNet != nil && servicesNet != nil &&
+		(containersNet.Contains(servicesNet.IP) || servicesNet.Contains(containersNet.IP)) {
+		errs = append(errs, "containersCidr and servicesCidr must not overlap")
+	}
+
+	if !containsString(supportedNetworkPlugins, string(s.NetworkPlugin)) {
+		errs = append(errs, fmt.Sprintf("networkPlugin must be one of %s", strings.Join(supportedNetworkPlugins, ", ")))
+	}
+	if !containsString(supportedContainerRuntimes, s.ContainerRuntime) {
+		errs = append(errs, fmt.Sprintf("containerRuntime must be one of %s", strings.Join(supportedContainerRuntimes, ", ")))
+	}
+	if s.NetworkStack != 0 && s.NetworkStack != 1 {
+		errs = append(errs, "ipv6 must be 0 or 1")
+	}
+	if s.NetworkStack == 1 && s.NetworkPlugin != qbert.CNIBackend(Calico) {
+		errs = append(errs, "ipv6 clusters only support the calico network plugin")
+	}
+
+	if s.EtcdBackup.IsEtcdBackupEnabled == 1 {
+		if s.EtcdBackup.IntervalInMins < 30 || s.EtcdBackup.IntervalInMins > 60 {
+			errs = append(errs, "etcdBackup.intervalInMins should be between 30 and 60")
+		}
+		if s.EtcdBackup.StorageProperties.LocalPath == "" {
+			errs = append(errs, "etcdBackup.storageProperties.localPath is required when etcd backup is enabled")
+		}
+	}
+
+	if s.MasterVirtualIP != "" && net.ParseIP(s.MasterVirtualIP) == nil {
+		errs = append(errs, fmt.Sprintf("masterVipIpv4 %q is not a valid IP address", s.MasterVirtualIP))
+	}
+	if len(s.Nodes.Masters) > 1 && (s.MasterVirtualIP == "" || s.MasterVirtualIPIface == "") {
+		errs = append(errs, "masterVipIpv4 and masterVipIface are required for clusters with more than one master")
+	}
+
+	for _, flags := range [][]string{s.ApiServerFlags, s.ControllerManagerFlags, s.SchedulerFlags} {
+		for _, flag := range flags {
+			if !strings.HasPrefix(flag, "--") {
+				errs = append(errs, fmt.Sprintf("flag %q must start with --", flag))
+			}
+		}
+	}
+
+	for key := range s.Tags {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, "tags must not have an empty key")
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, ip := range append(append([]string{}, s.Nodes.Masters...), s.Nodes.Workers...) {
+		if net.ParseIP(ip) == nil {
+			errs = append(errs, fmt.Sprintf("node IP %q is not a valid IP address", ip))
+		}
+		if seen[ip] {
+			errs = append(errs, fmt.Sprintf("node IP %s is listed more than once", ip))
+		}
+		seen[ip] = true
+	}
+	if s.Masterless && len(s.Nodes.Masters) > 0 {
+		errs = append(errs, "masterless clusters must not list master nodes")
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid cluster spec: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// CreateRequest converts the spec to the request sent to qbert. It also sets the
+// qbert payload globals, which are shared with bootstrap, to match the spec.
+func (s ClusterSpec) CreateRequest() qbert.ClusterCreateRequest {
+	req := s.ClusterCreateRequest
+	if len(s.Tags) > 0 {
+		req.Tags = map[string]string{}
+		for k, v := range s.Tags {
+			req.Tags[k] = v
+		}
+	}
+
+	qbert.IStag = false
+	qbert.IsPMKversionDefined = true
+	qbert.SplitPMKversion = strings.Split(s.PmkVersion, "-")
+	qbert.IsMonitoringDisabled = !s.Monitoring
+
+	// For pmk version 1.20.11 and below monitoring is a tag, put it with the
+	// other tags so the payload does not carry two tags fields.
+	if s.Monitoring && qbert.SplitPMKversion[0] <= util.PmkVersion && len(req.Tags) > 0 {
+		req.Tags["pf9-system:monitoring"] = "true"
+		qbert.IsMonitoringDisabled = true
+	}
+	return req
+}
+
+// CreateClusterFromSpec creates the cluster described by the spec and attaches its nodes
+func CreateClusterFromSpec(c client.Client, spec ClusterSpec, auth keystone.KeystoneAuth) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	}
+
+	pmkRoles, err := c.Qbert.GetPMKVersions(auth.Token, auth.ProjectID)
+	if err != nil {
+		return err
+	}
+	versionFound := false
+	for _, v := range pmkRoles.Roles {
+		if v.RoleVersion == spec.PmkVersion {
+			versionFound = true
+			break
+		}
+	}
+	if !versionFound {
+		var versions []string
+		for _, v := range pmkRoles.Roles {
+			versions = append(versions, v.RoleVersion)
+		}
+		return fmt.Errorf("%s pmk version is not supported, supported versions are: %s", spec.PmkVersion, strings.Join(versions, ", "))
+	}
+
+	// Resolve the nodes before creating the cluster so a missing host does not leave an empty cluster behind
+	var masterIDs, workerIDs []string
+	if len(spec.Nodes.Masters) > 0 {
+		if masterIDs, err = c.Resmgr.GetHostId(auth.Token, spec.Nodes.Masters); err != nil {
+			return err
+		}
+	}
+	if len(spec.Nodes.Workers) > 0 {
+		if workerIDs, err = c.Resmgr.GetHostId(auth.Token, spec.Nodes.Workers); err != nil {
+			return err
+		}
+	}
+
+	s := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
+	s.Color("red")
+	s.Start()
+	defer s.Stop()
+	setSpinnerSuffix(s, fmt.Sprintf(" Creating a cluster %s", spec.Name))
+	zap.S().Debugf("Creating a cluster %s", spec.Name)
+
+	clusterID, err := c.Qbert.CreateCluster(spec.CreateRequest(), auth.ProjectID, auth.Token)
+	s.Stop()
+	if err != nil {
+		return fmt.Errorf("Unable to create cluster %s: %w", spec.Name, err)
+	}
+	fmt.Println(color.Green("✓") + " Cluster creation completed")
+	zap.S().Debugf("Cluster %s created with uuid %s", spec.Name, clusterID)
+
+	for _, role := range []struct {
+		name string
+		ids  []string
+	}{{"master", masterIDs}, {"worker", workerIDs}} {
+		if len(role.ids) == 0 {
+			continue
+		}
+		s.Restart()
+		setSpinnerSuffix(s, fmt.Sprintf(" Attaching %s node(s) to the cluster %s", role.name, spec.Name))
+		err = c.Qbert.AttachNode(clusterID, auth.ProjectID, auth.Token, role.ids, role.name)
+		s.Stop()
+		if err != nil {
+			return fmt.Errorf("Unable to attach %s node(s) to cluster %s: %w", role.name, spec.Name, err)
+		}
+		fmt.Printf(color.Green("✓")+" Attached %d %s node(s) to the cluster\n", len(role.ids), role.name)
+	}
+
+	fmt.Println("Cluster creation started....This may take a few minutes....Check the latest status in UI")
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package pmk
+
+import (
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/stretchr/testify/assert"
+)
+
+var clusterSpecYaml = `
+name: prod
+kubeRoleVersion: 1.26.14-pmk.123
+masterVipIpv4: 10.0.0.100
+masterVipIface: eth0
+apiServerFlags:
+  - --request-timeout=2m0s
+etcdBackup:
+  intervalInMins: 45
+tags:
+  team: platform
+  env: prod
+nodes:
+  masters: [10.0.0.1, 10.0.0.2, 10.0.0.3]
+  workers: [10.0.0.4]
+`
+
+func TestParseClusterSpec(t *testing.T) {
+	spec, err := ParseClusterSpec([]byte(clusterSpecYaml))
+	assert.Nil(t, err)
+	assert.Nil(t, spec.Validate())
+
+	assert.Equal(t, "prod", spec.Name)
+	assert.Equal(t, "1.26.14-pmk.123", spec.PmkVersion)
+	assert.Equal(t, []string{"--request-timeout=2m0s"}, spec.ApiServerFlags)
+	assert.Equal(t, map[string]string{"team": "platform", "env": "prod"}, spec.Tags)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, spec.Nodes.Masters)
+	assert.Equal(t, []string{"10.0.0.4"}, spec.Nodes.Workers)
+
+	// Fields missing from the spec keep the bootstrap defaults
+	assert.Equal(t, "10.20.0.0/16", spec.ContainerCIDR)
+	assert.Equal(t, qbert.CNIBackend("calico"), spec.NetworkPlugin)
+	assert.Equal(t, 45, spec.EtcdBackup.IntervalInMins)
+	assert.Equal(t, 1, spec.EtcdBackup.IsEtcdBackupEnabled)
+	assert.Equal(t, "/etc/pf9/etcd-backup", spec.EtcdBackup.StorageProperties.LocalPath)
+	assert.True(t, spec.Monitoring)
+}
+
+func TestParseClusterSpecJSON(t *testing.T) {
+	spec, err := ParseClusterSpec([]byte(`{"name": "dev", "kubeRoleVersion": "1.26.14-pmk.123", "networkPlugin": "flannel"}`))
+	assert.Nil(t, err)
+	assert.Nil(t, spec.Validate())
+	assert.Equal(t, qbert.CNIBackend("flannel"), spec.NetworkPlugin)
+}
+
+func TestParseClusterSpecUnknownField(t *testing.T) {
+	_, err := ParseClusterSpec([]byte("name: dev\nnetworkPlugn: calico\n"))
+	assert.NotNil(t, err)
+}
+
+func TestClusterSpecValidate(t *testing.T) {
+	cases := map[string]struct {
+		spec string
+		err  string
+	}{
+		"MissingName": {
+			spec: "kubeRoleVersion: 1.26.14-pmk.123",
+			err:  "invalid cluster spec: name is required",
+		},
+		"OverlappingCIDRs": {
+			spec: "name: dev\nkubeRoleVersion: v\ncontainersCidr: 10.20.0.0/16\nservicesCidr: 10.20.128.0/17",
+			err:  "invalid cluster spec: containersCidr and servicesCidr must not overlap",
+		},
+		"BadEtcdInterval": {
+			spec: "name: dev\nkubeRoleVersion: v\netcdBackup:\n  intervalInMins: 5",
+			err:  "invalid cluster spec: etcdBackup.intervalInMins should be between 30 and 60",
+		},
+		"MultiMasterWithoutVIP": {
+			spec: "name: dev\nkubeRoleVersion: v\nnodes:\n  masters: [10.0.0.1, 10.0.0.2]",
+			err:  "invalid cluster spec: masterVipIpv4 and masterVipIface are required for clusters with more than one master",
+		},
+		"DuplicateNode": {
+			spec: "name: dev\nkubeRoleVersion: v\nnodes:\n  masters: [10.0.0.1]\n  workers: [10.0.0.1, bad-ip]",
+			err:  "invalid cluster spec: node IP 10.0.0.1 is listed more than once; node IP \"bad-ip\" is not a valid IP address",
+		},
+		"BadFlag": {
+			spec: "name: dev\nkubeRoleVersion: v\nschedulerFlags: [kube-api-burst=120]",
+			err:  "invalid cluster spec: flag \"kube-api-burst=120\" must start with --",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			spec, err := ParseClusterSpec([]byte(tc.spec))
+			assert.Nil(t, err)
+			err = spec.Validate()
+			assert.NotNil(t, err)
+			assert.Equal(t, tc.err, err.Error())
+		})
+	}
+}
+
+func TestClusterSpecCreateRequest(t *testing.T) {
+	spec, err := ParseClusterSpec([]byte("name: old\nkubeRoleVersion: 1.20.11-pmk.1\ntags:\n  team: platform"))
+	assert.Nil(t, err)
+
+	req := spec.CreateRequest()
+	assert.Equal(t, map[string]string{"team": "platform", "pf9-system:monitoring": "true"}, req.Tags)
+	assert.True(t, qbert.IsMonitoringDisabled)
+	assert.Equal(t, map[string]string{"team": "platform"}, spec.Tags)
+}
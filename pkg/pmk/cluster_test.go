@@ -0,0 +1,81 @@
+package pmk
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/fakedu"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForCluster(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+	interval, maxInterval := clusterPollInterval, clusterPollMaxInterval
+	t.Cleanup(func() { clusterPollInterval, clusterPollMaxInterval = interval, maxInterval })
+	clusterPollInterval, clusterPollMaxInterval = time.Millisecond, 4*time.Millisecond
+
+	c, err := client.NewClient(du.URL, nil, true, true)
+	assert.Nil(t, err)
+	auth, err := c.Keystone.GetAuth(fakedu.DefaultUsername, fakedu.DefaultPassword, fakedu.DefaultTenant, "")
+	assert.Nil(t, err)
+	clusterID, err := c.Qbert.CreateCluster(qbert.ClusterCreateRequest{Name: "wait"}, auth.ProjectID, auth.Token)
+	assert.Nil(t, err)
+
+	cases := map[string]struct {
+		// statuses the cluster goes through, the last one stays
+		statuses [][3]string
+		timeout  time.Duration
+		err      string
+		output   []string
+	}{
+		"healthy": {
+			statuses: [][3]string{{"pending", "", ""}, {"ok", "converging", ""}, {"ok", "success", ""}},
+			timeout:  time.Minute,
+			output:   []string{"Cluster wait is creating", "Cluster wait is converging", "✓ Cluster wait is ok"},
+		},
+		"failed": {
+			statuses: [][3]string{{"ok", "converging", ""}, {"ok", "error", "etcd failed"}},
+			timeout:  time.Minute,
+			err:      "Cluster wait failed: etcd failed",
+			output:   []string{"Cluster wait is converging", "x Cluster wait is in error"},
+		},
+		"timeout": {
+			statuses: [][3]string{{"ok", "converging", ""}},
+			timeout:  20 * time.Millisecond,
+			err:      "it is still converging",
+			output:   []string{"Cluster wait is converging"},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			first := tc.statuses[0]
+			du.SetClusterStatus(clusterID, first[0], first[1], first[2])
+			go func() {
+				for _, s := range tc.statuses[1:] {
+					time.Sleep(20 * time.Millisecond)
+					du.SetClusterStatus(clusterID, s[0], s[1], s[2])
+				}
+			}()
+
+			out := &bytes.Buffer{}
+			c.Out = out
+			err := WaitForCluster(c, "wait", clusterID, auth, tc.timeout)
+			if tc.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.err)
+			}
+			for _, line := range tc.output {
+				assert.Contains(t, out.String(), line)
+			}
+		})
+	}
+
+	_, err = c.Qbert.GetClusterStatus("missing", auth.ProjectID, auth.Token)
+	assert.NotNil(t, err)
+	assert.NotNil(t, WaitForCluster(c, "missing", "missing", auth, 5*time.Millisecond))
+}
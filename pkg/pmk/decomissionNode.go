@@ -1,6 +1,7 @@
 package pmk
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path"
@@ -15,15 +16,19 @@ import (
 	"go.uber.org/zap"
 )
 
-func removePf9Installation(c client.Client) {
+// ErrNodeAttached is returned when decommissioning a node still attached to a cluster
+var ErrNodeAttached = errors.New("Node is still attached to a cluster. Please run detach-node command first and wait for the node to be completely removed from the cluster and only then run decommision-node command")
+
+func removePf9Installation(c client.Client) error {
 	fmt.Println("Removing pf9 HOME dir")
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		zap.S().Fatalf("Failed to get home dir, could not delete pf9Home dir")
+		return fmt.Errorf("Failed to get home dir, could not delete pf9Home dir: %w", err)
 	}
 	pf9Home := path.Join(homeDir, "pf9")
 	cmd := fmt.Sprintf("rm -rf %s", pf9Home)
 	c.Executor.RunCommandWait(cmd)
+	return nil
 }
 
 func removeHostagent(c client.Client, hostOS string) {
@@ -66,7 +71,7 @@ func removeHostagent(c client.Client, hostOS string) {
 
 }
 
-func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool) {
+func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool) error {
 	//Doc decommission steps
 	//detach-node from cluster
 	//deauthorize-node from controle plane
@@ -79,11 +84,12 @@ func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool
 	var executor cmdexec.Executor
 	var err error
 	if executor, err = cmdexec.GetExecutor(cfg.ProxyURL, nc); err != nil {
-		zap.S().Fatalf("Unable to create executor: %s\n", err.Error())
+		return fmt.Errorf("Unable to create executor: %w", err)
 	}
+	defer executor.Close()
 	var c client.Client
 	if c, err = client.NewClient(cfg.Fqdn, executor, cfg.AllowInsecure, false); err != nil {
-		zap.S().Fatalf("Unable to create client: %s\n", err.Error())
+		return fmt.Errorf("Unable to create client: %w", err)
 	}
 	auth, err := c.Keystone.GetAuth(cfg.Username, cfg.Password, cfg.Tenant, cfg.MfaToken)
 	if err != nil {
@@ -92,7 +98,7 @@ func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool
 
 	hostOS, err := ValidatePlatform(c.Executor)
 	if err != nil {
-		zap.S().Fatalf("Error getting OS version")
+		return fmt.Errorf("Error getting OS version: %w", err)
 	}
 	//check if hostagent is installed on host
 	if hostOS == "debian" {
@@ -115,18 +121,21 @@ func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool
 			nodeConnectedToDU = true
 			nodeInfo, err = c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, hostID)
 			if err != nil {
-				zap.S().Fatalf("Failed to get node info for host %s: %s", hostID, err.Error())
+				return fmt.Errorf("Failed to get node info for host %s: %w", hostID, err)
 			}
 		}
 
 		if nodeInfo.ClusterName == "" {
-			if nodeConnectedToDU {
+			dryRun, isDryRun := c.Executor.(cmdexec.DryRunExecutor)
+			if isDryRun && nodeConnectedToDU {
+				dryRun.Record(fmt.Sprintf("deauthorize host %s", hostID))
+				removeHostagent(c, hostOS)
+			} else if nodeConnectedToDU {
 				err = c.Qbert.DeauthoriseNode(hostID, auth.Token)
 				if err != nil {
-					zap.S().Fatalf("Failed to deauthorize node")
-				} else {
-					fmt.Println("Deauthorized node from UI")
+					return fmt.Errorf("Failed to deauthorize node: %w", err)
 				}
+				fmt.Println("Deauthorized node from UI")
 				removeHostagent(c, hostOS)
 			} else {
 				//case where node is not connected to DU but hostagent is installed partially
@@ -134,14 +143,19 @@ func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool
 			}
 			//remove pf9 dir
 			if removePf9 {
-				removePf9Installation(c)
+				if err := removePf9Installation(c); err != nil {
+					return err
+				}
+			}
+			if isDryRun {
+				return nil
 			}
 			fmt.Println("Node decommissioning started....This may take a few minutes....Check the latest status in UI")
 			time.Sleep(50 * time.Second)
 		} else {
 			// If node is connected to cluster exit, because need to redesign detach and deauthorize flows
 			fmt.Printf("Node is attached to %s cluster\n", nodeInfo.ClusterName)
-			zap.S().Fatalf("Node is still attached to a cluster. Please run detach-node command first and wait for the node to be completely removed from the cluster and only then run decommision-node command")
+			return ErrNodeAttached
 
 			//This code will not be called since we are exiting if node is attached to cluster
 			//TODO : https://platform9.atlassian.net/browse/PMK-5938 https://platform9.atlassian.net/browse/PMK-5784
@@ -175,4 +189,5 @@ func DecommissionNode(cfg *objects.Config, nc objects.NodeConfig, removePf9 bool
 	} else {
 		fmt.Println("Host is not connected to Platform9 Management Plane")
 	}
+	return nil
 }
@@ -0,0 +1,237 @@
+package pmk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/fakedu"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeHost answers the commands run by prep-node and bootstrap like an Ubuntu 22.04
+// host, running the installer registers the host with the fake DU.
+type fakeHost struct {
+	du        *fakedu.Server
+	id        string
+	ip        string
+	installed bool
+	// waited holds the commands run with RunCommandWait
+	waited []string
+}
+
+func (h *fakeHost) executor() cmdexec.Executor {
+	run := func(name string, args ...string) (string, error) {
+		cmd := strings.Join(append([]string{name}, args...), " ")
+		switch {
+		case cmd == "cat /etc/os-release":
+			return "NAME=\"Ubuntu\"\nPRETTY_NAME=\"Ubuntu 22.04.3 LTS\"\n", nil
+		case strings.Contains(cmd, "grep -i pretty_name"):
+			return "22.04.3\n", nil
+		case strings.Contains(cmd, "lsof"):
+			return "", errors.New("exit status 1")
+		case strings.Contains(cmd, "systemctl is-active"):
+			return "inactive\n", nil
+		case strings.Contains(cmd, "echo $HOME"):
+			return "/home/ubuntu\n", nil
+		case strings.Contains(cmd, "installer.sh --no-proxy"):
+			h.du.AddHost(h.id, "node-"+h.ip, h.ip)
+			h.installed = true
+		case strings.Contains(cmd, "grep ^host_id"):
+			return h.id + "\n", nil
+		case strings.Contains(cmd, "grep host_id"):
+			if !h.installed {
+				return "", errors.New("exit status 2")
+			}
+			return "host_id = " + h.id + "\n", nil
+		}
+		return "", nil
+	}
+	return &cmdexec.MockExecutor{
+		MockRun: func(name string, args ...string) error {
+			_, err := run(name, args...)
+			return err
+		},
+		MockRunWithStdout: run,
+		MockRunCommandWait: func(command string) string {
+			h.waited = append(h.waited, command)
+			return ""
+		},
+	}
+}
+
+func TestEndToEndWithFakeDU(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+	waitPeriod := attachWaitPeriod
+	t.Cleanup(func() { attachWaitPeriod = waitPeriod })
+	attachWaitPeriod = 0
+
+	ctx := objects.Config{
+		Fqdn:          du.URL,
+		Username:      fakedu.DefaultUsername,
+		Password:      fakedu.DefaultPassword,
+		Tenant:        fakedu.DefaultTenant,
+		Region:        fakedu.DefaultRegion,
+		AllowInsecure: true,
+	}
+	master := &fakeHost{du: du, id: "11111111-host-master", ip: "10.0.0.1"}
+	worker := &fakeHost{du: du, id: "22222222-host-worker", ip: "10.0.0.2"}
+
+	newClient := func(h *fakeHost) client.Client {
+		c, err := client.NewClient(du.URL, h.executor(), true, true)
+		assert.Nil(t, err)
+		c.Out = io.Discard
+		return c
+	}
+	c := newClient(master)
+
+	_, err := c.Keystone.GetAuth(ctx.Username, "wrong", ctx.Tenant, "")
+	assert.NotNil(t, err)
+	auth, err := c.Keystone.GetAuth(ctx.Username, ctx.Password, ctx.Tenant, "")
+	assert.Nil(t, err)
+	assert.Equal(t, du.ProjectID, auth.ProjectID)
+
+	// prep-node --dry-run leaves the host untouched
+	cmdexec.ResetPlan()
+	defer cmdexec.ResetPlan()
+	dryRun := newClient(master)
+	dryRun.Executor = cmdexec.DryRunExecutor{Executor: dryRun.Executor, Host: master.ip}
+	assert.Nil(t, PrepNode(ctx, dryRun, auth))
+	assert.False(t, master.installed)
+	assert.Equal(t, 0, len(du.Hosts()))
+	plan := cmdexec.Plan()
+	assert.Equal(t, cmdexec.PlannedStep{Host: master.ip, Step: "authorize host <host id> with the pf9-kube role"}, plan[len(plan)-1])
+
+	// prep-node
+	for _, h := range []*fakeHost{master, worker} {
+		assert.Nil(t, PrepNode(ctx, newClient(h), auth))
+	}
+	nodes := du.Nodes()
+	assert.Equal(t, 2, len(nodes))
+	for _, node := range nodes {
+		assert.Equal(t, "", node.ClusterUuid)
+	}
+
+	// bootstrap
+	req := qbert.ClusterCreateRequest{Name: "e2e", ContainerCIDR: "10.20.0.0/16", ServiceCIDR: "10.21.0.0/16", NetworkPlugin: "calico"}
+	clusterID, err := Bootstrap(ctx, c, req, auth, objects.NodeConfig{})
+	assert.Nil(t, err)
+	exists, existingID, status, err := c.Qbert.CheckClusterExists("e2e", auth.ProjectID, auth.Token)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, clusterID, existingID)
+	assert.Equal(t, "ok", status)
+	node, err := c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, master.id)
+	assert.Nil(t, err)
+	assert.Equal(t, clusterID, node.ClusterUuid)
+	assert.Equal(t, 1, node.IsMaster)
+
+	// get kubeconfig
+	data, err := c.Qbert.GetKubeconfig(clusterID, auth.ProjectID, auth.Token)
+	assert.Nil(t, err)
+	kubeconfig, err := ParseKubeconfig(data)
+	assert.Nil(t, err)
+	assert.Nil(t, kubeconfig.EmbedCredentials(KubeconfigAuthToken, auth.Token, ctx.Username, ctx.Password))
+	assert.Nil(t, kubeconfig.Rename("e2e"))
+	assert.Equal(t, auth.Token, kubeconfig.Users[0].User["token"])
+
+	// resmgr host lookups, API-level checks of the fake DU
+	workerIDs, err := c.Resmgr.GetHostId(auth.Token, []string{worker.ip})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{worker.id}, workerIDs)
+	_, err = c.Resmgr.GetHostId(auth.Token, []string{"10.0.0.9"})
+	assert.True(t, errors.Is(err, resmgr.ErrHostNotFound))
+	assert.Nil(t, c.Resmgr.HostStatus(auth.Token, worker.id))
+	du.SetResponding(worker.id, false)
+	assert.True(t, errors.Is(c.Resmgr.HostStatus(auth.Token, worker.id), resmgr.ErrHostNotResponding))
+	du.SetResponding(worker.id, true)
+	assert.True(t, errors.Is(c.Resmgr.HostStatus(auth.Token, "missing"), resmgr.ErrHostNotFound))
+
+	// attach-node
+	assert.Nil(t, AttachNodes(c, auth, "e2e", nil, []string{worker.ip}))
+	clusters, err := c.Qbert.ListClusters(auth.ProjectID, auth.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, clusters[0].NumMasters)
+	assert.Equal(t, 1, clusters[0].NumWorkers)
+	// the worker is attached already so it is skipped
+	assert.Nil(t, AttachNodes(c, auth, "e2e", nil, []string{worker.ip}))
+
+	// detach-node
+	assert.Nil(t, DetachNodes(c, auth, []string{worker.ip}))
+	node, err = c.Qbert.GetNodeInfo(auth.Token, auth.ProjectID, worker.id)
+	assert.Nil(t, err)
+	assert.Equal(t, "", node.ClusterUuid)
+	err = DetachNodes(c, auth, []string{worker.ip})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not connected to any clusters")
+
+	// delete-cluster from the master kills its Kubernetes processes first
+	assert.Nil(t, DeleteCluster(c, auth, clusterID, master.ip))
+	assert.Equal(t, 3, len(master.waited))
+	assert.Contains(t, master.waited[0], "pidof kubelet")
+	assert.Equal(t, 0, len(du.Clusters()))
+	nodes, err = c.Qbert.GetAllNodes(auth.Token, auth.ProjectID)
+	assert.Nil(t, err)
+	for _, node := range nodes {
+		assert.Equal(t, "", node.ClusterUuid)
+	}
+
+	// deauthorize, an API-level check of the fake DU
+	assert.Nil(t, c.Qbert.DeauthoriseNode(worker.id, auth.Token))
+	assert.Equal(t, 1, len(du.Nodes()))
+}
+
+// TestPrepNodeOnHostsInParallel runs check-node and prep-node on several hosts at once
+// like prep-node with several IPs does, run it with -race to catch shared host state.
+func TestPrepNodeOnHostsInParallel(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+
+	ctx := objects.Config{
+		Fqdn:          du.URL,
+		Username:      fakedu.DefaultUsername,
+		Password:      fakedu.DefaultPassword,
+		Tenant:        fakedu.DefaultTenant,
+		Region:        fakedu.DefaultRegion,
+		AllowInsecure: true,
+	}
+	hosts := map[string]*fakeHost{}
+	var ips []string
+	for i := 1; i <= 3; i++ {
+		h := &fakeHost{du: du, id: fmt.Sprintf("%08d-host-parallel", i), ip: fmt.Sprintf("10.0.1.%d", i)}
+		hosts[h.ip] = h
+		ips = append(ips, h.ip)
+	}
+	c, err := client.NewClient(du.URL, nil, true, true)
+	assert.Nil(t, err)
+	auth, err := c.Keystone.GetAuth(ctx.Username, ctx.Password, ctx.Tenant, "")
+	assert.Nil(t, err)
+
+	results := RunOnHosts(io.Discard, ips, len(ips), func(host string, out io.Writer) (string, error) {
+		hc, err := client.NewClient(du.URL, hosts[host].executor(), true, true)
+		if err != nil {
+			return "", err
+		}
+		hc.Out = out
+		result, _, err := CheckNode(ctx, hc, auth, objects.NodeConfig{IPs: []string{host}})
+		if err != nil {
+			return string(result), err
+		}
+		return "prepared", PrepNode(ctx, hc, auth)
+	})
+	for _, r := range results {
+		assert.Nil(t, r.Err, r.Host)
+	}
+	assert.Equal(t, len(ips), len(du.Nodes()))
+	for _, h := range hosts {
+		assert.True(t, h.installed, h.ip)
+	}
+}
@@ -0,0 +1,137 @@
+package pmk
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+)
+
+// Default etcd backup settings, the ones bootstrap uses
+const (
+	DefaultEtcdBackupPath       = "/etc/pf9/etcd-backup"
+	DefaultEtcdBackupInterval   = 30
+	DefaultEtcdBackupMaxBackups = 3
+)
+
+// etcdctl and the client certificates installed on the masters by pf9-kube
+var (
+	etcdctlPath = "/opt/pf9/pf9-kube/bin/etcdctl"
+	etcdCACert  = "/etc/pf9/kube.d/certs/etcdctl/etcd/ca.crt"
+	etcdCert    = "/etc/pf9/kube.d/certs/etcdctl/etcd/request.crt"
+	etcdKey     = "/etc/pf9/kube.d/certs/etcdctl/etcd/request.key"
+)
+
+// EtcdBackupSettings returns the settings of a local etcd backup, backups are
+// taken every interval minutes in dir and the last maxBackups are kept.
+func EtcdBackupSettings(dir string, interval, maxBackups int) (qbert.EtcdBackup, error) {
+	var errs []string
+	if interval < 30 || interval > 60 {
+		errs = append(errs, "the interval should be between 30 and 60 minutes")
+	}
+	if !path.IsAbs(dir) {
+		errs = append(errs, "the backup path should be an absolute path")
+	}
+	if maxBackups < 1 {
+		errs = append(errs, "at least one backup should be kept")
+	}
+	if len(errs) > 0 {
+		return qbert.EtcdBackup{}, fmt.Errorf("Invalid etcd backup settings: %s", strings.Join(errs, ", "))
+	}
+	return qbert.EtcdBackup{
+		StorageType:            "local",
+		IsEtcdBackupEnabled:    1,
+		StorageProperties:      qbert.Storageproperties{LocalPath: dir},
+		IntervalInMins:         interval,
+		MaxIntervalBackupCount: maxBackups,
+	}, nil
+}
+
+// EtcdBackupPath is where the backups of the cluster are stored on its masters
+func EtcdBackupPath(cluster qbert.Cluster) string {
+	if cluster.EtcdBackup.StorageProperties.LocalPath != "" {
+		return cluster.EtcdBackup.StorageProperties.LocalPath
+	}
+	return DefaultEtcdBackupPath
+}
+
+// PrintEtcdBackup prints the etcd backup settings of the cluster
+func PrintEtcdBackup(w io.Writer, cluster qbert.Cluster) {
+	backup := cluster.EtcdBackup
+	if backup.IsEtcdBackupEnabled != 1 {
+		fmt.Fprintf(w, "Etcd backup of cluster %s is disabled\n", cluster.Name)
+		return
+	}
+	fmt.Fprintf(w, "Etcd backup of cluster %s is enabled\n", cluster.Name)
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "  Storage:\t%s %s\n", backup.StorageType, backup.StorageProperties.LocalPath)
+	fmt.Fprintf(tw, "  Interval:\t%d minutes\n", backup.IntervalInMins)
+	fmt.Fprintf(tw, "  Backups kept:\t%d\n", backup.MaxIntervalBackupCount)
+	tw.Flush()
+}
+
+// EtcdBackupFile is a backup stored on a master
+type EtcdBackupFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// ListEtcdBackups returns the backups stored in dir on the master, newest first
+func ListEtcdBackups(exec cmdexec.Executor, dir string) ([]EtcdBackupFile, error) {
+	output, err := exec.RunWithStdout("find", dir, "-maxdepth", "1", "-type", "f", "-printf", `%f\t%s\t%T@\n`)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the backups in %s: %w", dir, err)
+	}
+	var files []EtcdBackupFile
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the size of %s: %w", fields[0], err)
+		}
+		mtime, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the time of %s: %w", fields[0], err)
+		}
+		files = append(files, EtcdBackupFile{Name: fields[0], Size: size, ModTime: time.Unix(int64(mtime), 0)})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime.After(files[j].ModTime) })
+	return files, nil
+}
+
+// PrintEtcdBackups prints the backups stored on a master
+func PrintEtcdBackups(w io.Writer, files []EtcdBackupFile) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSIZE\tCREATED")
+	for _, f := range files {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", f.Name, f.Size, f.ModTime.Format(time.RFC3339))
+	}
+	tw.Flush()
+}
+
+// EtcdSnapshot takes a snapshot of etcd on the master and stores it in dir, it
+// returns the path of the snapshot.
+func EtcdSnapshot(exec cmdexec.Executor, dir string) (string, error) {
+	snapshot := path.Join(dir, fmt.Sprintf("etcd-snapshot-%s.db", time.Now().UTC().Format("2006-01-02T15-04-05Z")))
+	if _, err := exec.RunWithStdout("mkdir", "-p", dir); err != nil {
+		return "", fmt.Errorf("Unable to create %s: %w", dir, err)
+	}
+	_, err := exec.RunWithStdout("env", "ETCDCTL_API=3", etcdctlPath,
+		"--cacert", etcdCACert, "--cert", etcdCert, "--key", etcdKey,
+		"snapshot", "save", snapshot)
+	if err != nil {
+		return "", fmt.Errorf("Unable to take the etcd snapshot: %w", err)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,132 @@
+package pmk
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/fakedu"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtcdBackupSettings(t *testing.T) {
+	cases := map[string]struct {
+		dir        string
+		interval   int
+		maxBackups int
+		err        string
+	}{
+		"defaults":          {dir: DefaultEtcdBackupPath, interval: DefaultEtcdBackupInterval, maxBackups: DefaultEtcdBackupMaxBackups},
+		"interval too long": {dir: "/backup", interval: 90, maxBackups: 1, err: "between 30 and 60 minutes"},
+		"relative path":     {dir: "backup", interval: 45, maxBackups: 1, err: "absolute path"},
+		"no backup kept":    {dir: "/backup", interval: 45, maxBackups: 0, err: "at least one backup"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			backup, err := EtcdBackupSettings(tc.dir, tc.interval, tc.maxBackups)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, qbert.EtcdBackup{
+				StorageType:            "local",
+				IsEtcdBackupEnabled:    1,
+				StorageProperties:      qbert.Storageproperties{LocalPath: tc.dir},
+				IntervalInMins:         tc.interval,
+				MaxIntervalBackupCount: tc.maxBackups,
+			}, backup)
+		})
+	}
+}
+
+func TestUpdateEtcdBackup(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+
+	c, err := client.NewClient(du.URL, nil, true, true)
+	assert.Nil(t, err)
+	auth, err := c.Keystone.GetAuth(fakedu.DefaultUsername, fakedu.DefaultPassword, fakedu.DefaultTenant, "")
+	assert.Nil(t, err)
+	clusterID, err := c.Qbert.CreateCluster(qbert.ClusterCreateRequest{Name: "prod"}, auth.ProjectID, auth.Token)
+	assert.Nil(t, err)
+
+	out := &bytes.Buffer{}
+	PrintEtcdBackup(out, du.Clusters()[0])
+	assert.Equal(t, "Etcd backup of cluster prod is disabled\n", out.String())
+	assert.Equal(t, DefaultEtcdBackupPath, EtcdBackupPath(du.Clusters()[0]))
+
+	backup, err := EtcdBackupSettings("/var/backup", 45, 5)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Qbert.UpdateEtcdBackup(clusterID, auth.ProjectID, auth.Token, backup))
+	clusters, err := c.Qbert.ListClusters(auth.ProjectID, auth.Token)
+	assert.Nil(t, err)
+	assert.Equal(t, backup, clusters[0].EtcdBackup)
+	assert.Equal(t, "/var/backup", EtcdBackupPath(clusters[0]))
+
+	out.Reset()
+	PrintEtcdBackup(out, clusters[0])
+	assert.Contains(t, out.String(), "is enabled")
+	assert.Contains(t, out.String(), "local /var/backup")
+	assert.Contains(t, out.String(), "45 minutes")
+
+	assert.NotNil(t, c.Qbert.UpdateEtcdBackup("missing", auth.ProjectID, auth.Token, backup))
+}
+
+func TestListEtcdBackups(t *testing.T) {
+	var ran string
+	var findArgs []string
+	exec := &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			ran = strings.Join(append([]string{name}, args...), " ")
+			findArgs = args
+			if strings.Contains(ran, "/missing") {
+				return "", errors.New("exit status 1")
+			}
+			return "etcd-1.db\t1024\t1700000000.5\netcd-2.db\t2048\t1700001800.1\n", nil
+		},
+	}
+
+	files, err := ListEtcdBackups(exec, "/etc/pf9/etcd-backup")
+	assert.Nil(t, err)
+	assert.Contains(t, ran, "find /etc/pf9/etcd-backup -maxdepth 1 -type f")
+	assert.Equal(t, []EtcdBackupFile{
+		{Name: "etcd-2.db", Size: 2048, ModTime: time.Unix(1700001800, 0)},
+		{Name: "etcd-1.db", Size: 1024, ModTime: time.Unix(1700000000, 0)},
+	}, files)
+
+	out := &bytes.Buffer{}
+	PrintEtcdBackups(out, files)
+	assert.Contains(t, out.String(), "etcd-2.db")
+
+	_, err = ListEtcdBackups(exec, "/missing")
+	assert.ErrorContains(t, err, "Unable to list the backups in /missing")
+
+	// The directory is passed to find as is, not through a shell
+	_, err = ListEtcdBackups(exec, "/backups/etcd; rm -rf /")
+	assert.Nil(t, err)
+	assert.Equal(t, "/backups/etcd; rm -rf /", findArgs[0])
+	assert.True(t, strings.HasPrefix(ran, "find "))
+}
+
+func TestEtcdSnapshot(t *testing.T) {
+	var ran []string
+	exec := &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			ran = append(ran, strings.Join(append([]string{name}, args...), " "))
+			return "", nil
+		},
+	}
+
+	snapshot, err := EtcdSnapshot(exec, "/var/backup")
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(snapshot, "/var/backup/etcd-snapshot-"))
+	assert.Equal(t, "mkdir -p /var/backup", ran[0])
+	assert.Contains(t, ran[1], "ETCDCTL_API=3 "+etcdctlPath)
+	assert.Contains(t, ran[1], "snapshot save "+snapshot)
+}
@@ -0,0 +1,207 @@
+package pmk
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Ways of authenticating the kubeconfig user with the cluster
+const (
+	// KubeconfigAuthToken embeds the keystone token, it expires with the token
+	KubeconfigAuthToken = "token"
+	// KubeconfigAuthPassword embeds the username and password, it does not expire
+	KubeconfigAuthPassword = "password"
+
+	// kubeconfigTokenPlaceholder is the token of the user in the kubeconfig returned by qbert
+	kubeconfigTokenPlaceholder = "__INSERT_BEARER_TOKEN_HERE__"
+)
+
+var ErrInvalidKubeconfigAuth = fmt.Errorf("Invalid kubeconfig auth, supported values are %s and %s", KubeconfigAuthToken, KubeconfigAuthPassword)
+
+// Kubeconfig holds the parts of a kubeconfig file pf9ctl changes, the content of
+// the clusters and users is kept as is.
+type Kubeconfig struct {
+	APIVersion     string                 `yaml:"apiVersion"`
+	Kind           string                 `yaml:"kind"`
+	Preferences    map[string]interface{} `yaml:"preferences,omitempty"`
+	Clusters       []KubeconfigCluster    `yaml:"clusters"`
+	Users          []KubeconfigUser       `yaml:"users"`
+	Contexts       []KubeconfigContext    `yaml:"contexts"`
+	CurrentContext string                 `yaml:"current-context"`
+	Extensions     []interface{}          `yaml:"extensions,omitempty"`
+}
+
+type KubeconfigCluster struct {
+	Name    string                 `yaml:"name"`
+	Cluster map[string]interface{} `yaml:"cluster"`
+}
+
+type KubeconfigUser struct {
+	Name string                 `yaml:"name"`
+	User map[string]interface{} `yaml:"user"`
+}
+
+type KubeconfigContext struct {
+	Name    string                 `yaml:"name"`
+	Context map[string]interface{} `yaml:"context"`
+}
+
+// ParseKubeconfig reads a kubeconfig file content
+func ParseKubeconfig(data []byte) (*Kubeconfig, error) {
+	k := &Kubeconfig{}
+	if err := yaml.Unmarshal(data, k); err != nil {
+		return nil, fmt.Errorf("Unable to parse kubeconfig: %w", err)
+	}
+	if k.APIVersion == "" {
+		k.APIVersion = "v1"
+	}
+	if k.Kind == "" {
+		k.Kind = "Config"
+	}
+	return k, nil
+}
+
+// LoadKubeconfig reads the kubeconfig file at loc, an empty config is returned when it does not exist
+func LoadKubeconfig(loc string) (*Kubeconfig, error) {
+	data, err := os.ReadFile(loc)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return ParseKubeconfig(data)
+}
+
+// DefaultKubeconfigLoc returns the kubeconfig used by kubectl, the first file of
+// $KUBECONFIG or ~/.kube/config
+func DefaultKubeconfigLoc() (string, error) {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)[0], nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".kube", "config"), nil
+}
+
+// EmbedCredentials replaces the token placeholder of the users with the keystone
+// token, or with the username and password encoded the way the Platform9 API accepts them.
+func (k *Kubeconfig) EmbedCredentials(authType, token, username, password string) error {
+	var bearer string
+	switch authType {
+	case KubeconfigAuthToken:
+		bearer = token
+	case KubeconfigAuthPassword:
+		creds, err := json.Marshal(map[string]string{"username": username, "password": password})
+		if err != nil {
+			return err
+		}
+		bearer = base64.StdEncoding.EncodeToString(creds)
+	default:
+		return ErrInvalidKubeconfigAuth
+	}
+
+	embedded := false
+	for _, user := range k.Users {
+		if user.User["token"] == kubeconfigTokenPlaceholder {
+			user.User["token"] = bearer
+			embedded = true
+		}
+	}
+	if !embedded {
+		return errors.New("No user with a token placeholder found in the kubeconfig")
+	}
+	return nil
+}
+
+// Rename names the cluster, user and context of a single cluster kubeconfig and
+// makes the context the current one.
+func (k *Kubeconfig) Rename(name string) error {
+	if len(k.Clusters) != 1 || len(k.Users) != 1 || len(k.Contexts) != 1 {
+		return errors.New("Only a kubeconfig with one cluster, user and context can be renamed")
+	}
+	k.Clusters[0].Name = name
+	k.Users[0].Name = name
+	k.Contexts[0].Name = name
+	if k.Contexts[0].Context == nil {
+		k.Contexts[0].Context = map[string]interface{}{}
+	}
+	k.Contexts[0].Context["cluster"] = name
+	k.Contexts[0].Context["user"] = name
+	k.CurrentContext = name
+	return nil
+}
+
+// Merge adds the clusters, users and contexts of src, replacing the ones with
+// the same names, and switches to the current context of src.
+func (k *Kubeconfig) Merge(src *Kubeconfig) {
+	k.Clusters = mergeNamed(k.Clusters, src.Clusters, func(c KubeconfigCluster) string { return c.Name })
+	k.Users = mergeNamed(k.Users, src.Users, func(u KubeconfigUser) string { return u.Name })
+	k.Contexts = mergeNamed(k.Contexts, src.Contexts, func(c KubeconfigContext) string { return c.Name })
+	if src.CurrentContext != "" {
+		k.CurrentContext = src.CurrentContext
+	}
+}
+
+// mergeNamed replaces the entries of dst having the name of an entry of src and appends the others
+func mergeNamed[T any](dst, src []T, name func(T) string) []T {
+	for _, entry := range src {
+		replaced := false
+		for i := range dst {
+			if name(dst[i]) == name(entry) {
+				dst[i] = entry
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			dst = append(dst, entry)
+		}
+	}
+	return dst
+}
+
+// Marshal renders the kubeconfig as YAML
+func (k *Kubeconfig) Marshal() ([]byte, error) {
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(k); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+// WriteKubeconfig saves the kubeconfig at loc, readable only by the user as it holds credentials
+func WriteKubeconfig(loc string, k *Kubeconfig) error {
+	data, err := k.Marshal()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(loc), 0700); err != nil {
+		return err
+	}
+	// Write to a temp file first so an interrupted write does not corrupt an existing kubeconfig
+	tmp, err := os.CreateTemp(filepath.Dir(loc), ".kubeconfig-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), loc)
+}
@@ -0,0 +1,106 @@
+package pmk
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const qbertKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster:
+    server: https://10.0.0.10
+    insecure-skip-tls-verify: true
+users:
+- name: admin@platform9.net
+  user:
+    token: __INSERT_BEARER_TOKEN_HERE__
+contexts:
+- name: default
+  context:
+    cluster: prod
+    user: admin@platform9.net
+    namespace: default
+current-context: default
+`
+
+func TestEmbedCredentials(t *testing.T) {
+	creds := base64.StdEncoding.EncodeToString([]byte(`{"password":"secret","username":"admin"}`))
+	cases := map[string]struct {
+		auth  string
+		token string
+		err   bool
+	}{
+		"token":    {KubeconfigAuthToken, "keystone-token", false},
+		"password": {KubeconfigAuthPassword, creds, false},
+		"invalid":  {"cert", "", true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			k, err := ParseKubeconfig([]byte(qbertKubeconfig))
+			assert.Nil(t, err)
+			err = k.EmbedCredentials(tc.auth, "keystone-token", "admin", "secret")
+			if tc.err {
+				assert.Equal(t, ErrInvalidKubeconfigAuth, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.token, k.Users[0].User["token"])
+
+			// The placeholder is gone once the credentials are embedded
+			assert.NotNil(t, k.EmbedCredentials(tc.auth, "keystone-token", "admin", "secret"))
+		})
+	}
+}
+
+func TestRenameAndMerge(t *testing.T) {
+	k, err := ParseKubeconfig([]byte(qbertKubeconfig))
+	assert.Nil(t, err)
+	assert.Nil(t, k.Rename("pmk-prod"))
+	assert.Equal(t, "pmk-prod", k.Clusters[0].Name)
+	assert.Equal(t, "pmk-prod", k.Users[0].Name)
+	assert.Equal(t, "pmk-prod", k.Contexts[0].Context["cluster"])
+	assert.Equal(t, "pmk-prod", k.Contexts[0].Context["user"])
+	assert.Equal(t, "default", k.Contexts[0].Context["namespace"])
+	assert.Equal(t, "pmk-prod", k.CurrentContext)
+
+	existing, err := LoadKubeconfig(filepath.Join(t.TempDir(), "missing"))
+	assert.Nil(t, err)
+	existing.Clusters = []KubeconfigCluster{{Name: "kind"}, {Name: "pmk-prod", Cluster: map[string]interface{}{"server": "https://old"}}}
+	existing.CurrentContext = "kind"
+
+	existing.Merge(k)
+	assert.Equal(t, 2, len(existing.Clusters))
+	assert.Equal(t, "kind", existing.Clusters[0].Name)
+	assert.Equal(t, "https://10.0.0.10", existing.Clusters[1].Cluster["server"])
+	assert.Equal(t, 1, len(existing.Users))
+	assert.Equal(t, 1, len(existing.Contexts))
+	assert.Equal(t, "pmk-prod", existing.CurrentContext)
+
+	k.Clusters = append(k.Clusters, KubeconfigCluster{Name: "other"})
+	assert.NotNil(t, k.Rename("again"))
+}
+
+func TestWriteKubeconfig(t *testing.T) {
+	loc := filepath.Join(t.TempDir(), ".kube", "config")
+	k, err := ParseKubeconfig([]byte(qbertKubeconfig))
+	assert.Nil(t, err)
+	assert.Nil(t, WriteKubeconfig(loc, k))
+
+	info, err := os.Stat(loc)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	read, err := LoadKubeconfig(loc)
+	assert.Nil(t, err)
+	assert.Equal(t, k, read)
+
+	entries, err := os.ReadDir(filepath.Dir(loc))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+}
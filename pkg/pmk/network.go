@@ -1,17 +1,17 @@
 package pmk
 
 import (
-	"log"
 	"net"
 )
 
-func GetIp() net.IP {
+// GetIp returns the IP of the interface with the default route
+func GetIp() (net.IP, error) {
 	conn, err := net.Dial("udp", "8.8.8.8:80")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 	defer conn.Close()
 
 	localAddr := conn.LocalAddr().(*net.UDPAddr)
-	return localAddr.IP
+	return localAddr.IP, nil
 }
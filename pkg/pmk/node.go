@@ -23,12 +23,9 @@ import (
 	"go.uber.org/zap"
 )
 
-// This variable is assigned with StatusCode during hostagent installation
-var HostAgent int
 var IsRemoteExecutor bool
-var homeDir string
-var isCDU bool
 
+// Types of hostagent installers, by the status code of the certless installer URL
 const (
 	// Response Status Codes
 	HostAgentCertless = 200
@@ -63,15 +60,15 @@ func sendSegmentEvent(allClients client.Client, eventStr string, auth keystone.K
 // PrepNode sets up prerequisites for k8s stack
 func PrepNode(ctx objects.Config, allClients client.Client, auth keystone.KeystoneAuth) error {
 	// Building our new spinner
-	isCDU = false
-	s := spinner.New(spinner.CharSets[9], 100*time.Millisecond)
+	out := allClients.Output()
+	s := spinner.New(spinner.CharSets[9], 100*time.Millisecond, spinner.WithWriter(out))
 	s.Color("red")
 
 	zap.S().Debug("Received a call to start preparing node(s).")
 	s.Start() // Start the spinner
 	defer s.Stop()
 	sendSegmentEvent(allClients, "Starting prep-node", auth, false)
-	s.Suffix = " Starting prep-node"
+	setSpinnerSuffix(s, " Starting prep-node")
 
 	hostOS, err := ValidatePlatform(allClients.Executor)
 	if err != nil {
@@ -103,7 +100,7 @@ func PrepNode(ctx objects.Config, allClients client.Client, auth keystone.Keysto
 		}
 	}
 
-	packagesPresent, newPackagesPresent, errStr := pf9PackagesPresent(hostOS, allClients.Executor, auth.Token, ctx.Fqdn)
+	packagesPresent, newPackagesPresent, isCDU, errStr := pf9PackagesPresent(hostOS, allClients.Executor, auth.Token, ctx.Fqdn)
 	if errStr != nil {
 		return fmt.Errorf("error while checking pf9 packages: %w", errStr)
 	}
@@ -120,39 +117,44 @@ func PrepNode(ctx objects.Config, allClients client.Client, auth keystone.Keysto
 	//If new packages are not present, download and install them
 	if !newPackagesPresent {
 		sendSegmentEvent(allClients, "Installing hostagent - 2", auth, false)
-		s.Suffix = " Downloading the Hostagent (this might take a few minutes...)"
-		if err := installHostAgent(ctx, auth, hostOS, allClients.Executor); err != nil {
+		setSpinnerSuffix(s, " Downloading the Hostagent (this might take a few minutes...)")
+		hostAgent, err := installHostAgent(ctx, auth, hostOS, allClients.Executor)
+		if err != nil {
 			errStr := "Error: Unable to install hostagent. " + err.Error()
 			sendSegmentEvent(allClients, errStr, auth, true)
 			return fmt.Errorf(errStr)
 		}
 
-		s.Suffix = " Platform9 packages installed successfully"
+		setSpinnerSuffix(s, " Platform9 packages installed successfully")
 
-		if HostAgent == HostAgentCertless {
-			s.Suffix = " Platform9 packages installed successfully"
+		if hostAgent == HostAgentCertless {
+			setSpinnerSuffix(s, " Platform9 packages installed successfully")
 			s.Stop()
-			fmt.Println(color.Green("✓ ") + "Platform9 packages installed successfully")
-		} else if HostAgent == HostAgentLegacy {
-			s.Suffix = " Hostagent installed successfully"
+			fmt.Fprintln(out, color.Green("✓ ")+"Platform9 packages installed successfully")
+		} else if hostAgent == HostAgentLegacy {
+			setSpinnerSuffix(s, " Hostagent installed successfully")
 			s.Stop()
-			fmt.Println(color.Green("✓ ") + "Hostagent installed successfully")
+			fmt.Fprintln(out, color.Green("✓ ")+"Hostagent installed successfully")
 		}
 		s.Restart()
 	}
 
 	sendSegmentEvent(allClients, "Initialising host - 3", auth, false)
-	s.Suffix = " Identifying host"
+	setSpinnerSuffix(s, " Identifying host")
 
 	zap.S().Debug("Fetching the hostID from conf")
+	dryRun, isDryRun := allClients.Executor.(cmdexec.DryRunExecutor)
 	hostID, err := getHostIDFromConf(allClients, auth)
-	if err != nil {
+	if err != nil && isDryRun {
+		// The hostagent was not installed, so the host has no ID yet
+		hostID = "<host id>"
+	} else if err != nil {
 		sendSegmentEvent(allClients, err.Error(), auth, true)
 		return err
 	}
 
 	s.Stop()
-	fmt.Println(color.Green("✓ ") + "Initialised host successfully")
+	fmt.Fprintln(out, color.Green("✓ ")+"Initialised host successfully")
 	zap.S().Debug("Initialised host successfully")
 	if util.SkipKube {
 		zap.S().Debug("Skip authorizing host as --skip-kube flag is true")
@@ -160,8 +162,13 @@ func PrepNode(ctx objects.Config, allClients client.Client, auth keystone.Keysto
 		return nil
 	}
 
+	if isDryRun {
+		dryRun.Record(fmt.Sprintf("authorize host %s with the pf9-kube role", hostID))
+		return nil
+	}
+
 	s.Restart()
-	s.Suffix = " Authorising host"
+	setSpinnerSuffix(s, " Authorising host")
 	zap.S().Debug("Authorising host")
 	time.Sleep(ctx.WaitPeriod * time.Second)
 
@@ -172,11 +179,11 @@ func PrepNode(ctx objects.Config, allClients client.Client, auth keystone.Keysto
 	}
 
 	zap.S().Debug("Host successfully attached to the Platform9 control-plane")
-	s.Suffix = " Host successfully attached to the Platform9 control-plane"
+	setSpinnerSuffix(s, " Host successfully attached to the Platform9 control-plane")
 	sendSegmentEvent(allClients, "Successful", auth, false)
 	s.Stop()
 
-	fmt.Println(color.Green("✓ ") + "Host successfully attached to the Platform9 control-plane")
+	fmt.Fprintln(out, color.Green("✓ ")+"Host successfully attached to the Platform9 control-plane")
 
 	return nil
 }
@@ -271,33 +278,35 @@ func EnableUnattendedUpdates(allClients client.Client) {
 	}
 }
 
-func installHostAgent(ctx objects.Config, auth keystone.KeystoneAuth, hostOS string, exec cmdexec.Executor) error {
+// installHostAgent installs the hostagent with the installer of the management plane,
+// it returns the type of the installer, HostAgentCertless or HostAgentLegacy.
+func installHostAgent(ctx objects.Config, auth keystone.KeystoneAuth, hostOS string, exec cmdexec.Executor) (int, error) {
 	zap.S().Debug("Downloading the Hostagent (this might take a few minutes...)")
 
 	regionURL, err := keystone.FetchRegionFQDN(ctx.Fqdn, ctx.Region, auth)
 	if err != nil {
-		return fmt.Errorf("Unable to fetch URL: %w", err)
+		return 0, fmt.Errorf("Unable to fetch URL: %w", err)
 	}
 
 	url := fmt.Sprintf("https://%s/clarity/platform9-install-%s.sh", regionURL, hostOS)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return fmt.Errorf("Unable to create a http request: %w", err)
+		return 0, fmt.Errorf("Unable to create a http request: %w", err)
 	}
 
 	client := http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("Unable to send a request to clientL %w", err)
+		return 0, fmt.Errorf("Unable to send a request to clientL %w", err)
 	}
-	HostAgent = resp.StatusCode
+	resp.Body.Close()
 	switch resp.StatusCode {
-	case 404:
-		return installHostAgentLegacy(ctx, regionURL, auth, hostOS, exec)
-	case 200:
-		return installHostAgentCertless(ctx, regionURL, auth, hostOS, exec)
+	case HostAgentLegacy:
+		return HostAgentLegacy, installHostAgentLegacy(ctx, regionURL, auth, hostOS, exec)
+	case HostAgentCertless:
+		return HostAgentCertless, installHostAgentCertless(ctx, regionURL, auth, hostOS, exec)
 	default:
-		return fmt.Errorf("Invalid status code when identifiying hostagent type: %d", resp.StatusCode)
+		return 0, fmt.Errorf("Invalid status code when identifiying hostagent type: %d", resp.StatusCode)
 	}
 }
 
@@ -356,7 +365,7 @@ func installHostAgentCertless(ctx objects.Config, regionURL string, auth keyston
 		_, err = exec.RunWithStdout("bash", "-c", cmd)
 	}
 
-	removeTempDirAndInstaller(exec)
+	removeTempDirAndInstaller(exec, homeDir)
 
 	if err != nil {
 		_, exitCode := cmdexec.ExitCodeChecker(err)
@@ -371,7 +380,7 @@ func installHostAgentCertless(ctx objects.Config, regionURL string, auth keyston
 	return nil
 }
 
-func removeTempDirAndInstaller(exec cmdexec.Executor) {
+func removeTempDirAndInstaller(exec cmdexec.Executor, homeDir string) {
 	zap.S().Debug("Removing temporary directory created to extract installer")
 	removeTmpDirCmd := fmt.Sprintf("rm -rf %s/pf9/pf9-install-*", homeDir)
 	_, err1 := exec.RunWithStdout("bash", "-c", removeTmpDirCmd)
@@ -440,8 +449,11 @@ func OpenOSReleaseFile(exec cmdexec.Executor) (string, error) {
 	return strings.ToLower(string(data)), nil
 }
 
-func pf9PackagesPresent(hostOS string, exec cmdexec.Executor, token string, fqdn string) (bool, bool, error) {
-	var packagesPresent, newPackagesPresent bool = false, false
+// pf9PackagesPresent returns whether Platform9 packages are installed, whether they are
+// the ones of the management plane and whether the management plane is a CDU, which
+// does not list its packages.
+func pf9PackagesPresent(hostOS string, exec cmdexec.Executor, token string, fqdn string) (bool, bool, bool, error) {
+	var packagesPresent bool
 	var pkgCheckCommand, ext string
 	pattern := `([^-\d]+)-(\d+\.\d+\.\d+-\d+)`
 	reg := regexp.MustCompile(pattern)
@@ -465,53 +477,49 @@ func pf9PackagesPresent(hostOS string, exec cmdexec.Executor, token string, fqdn
 
 	if !packagesPresent {
 		zap.S().Infof("Pf9 packages are not present")
-		return false, false, nil
+		return false, false, false, nil
 	}
 	//If pkgs are present, check version
 	url := fmt.Sprintf("%s/protected/nocert-packagelist%s", fqdn, ext)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return true, false, fmt.Errorf("Unable to create a http request to list packages: %w", err)
+		return true, false, false, fmt.Errorf("Unable to create a http request to list packages: %w", err)
 	}
 	req.Header.Set("X-Auth-Token", token)
 	client := http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return true, false, fmt.Errorf("Unable to send a request to client %w", err)
+		return true, false, false, fmt.Errorf("Unable to send a request to client %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusOK {
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return true, false, fmt.Errorf("error reading response body: %w", err)
+			return true, false, false, fmt.Errorf("error reading response body: %w", err)
 		}
 		lines := strings.Split(string(body), "\n")
 		for _, line := range lines {
 			if strings.Contains(line, ext) {
 				match := reg.FindStringSubmatch(line)
 				if len(match) <= 2 {
-					return true, false, fmt.Errorf("error in extracting version from packages")
+					return true, false, false, fmt.Errorf("error in extracting version from packages")
 				}
 				cmd := fmt.Sprintf("%s | { grep -i '%s.*%s' || true; }", pkgCheckCommand, match[1], match[2])
 				out, _ := exec.RunWithStdout("bash", "-c", cmd)
 				if out != "" {
-					newPackagesPresent = true
-					return packagesPresent, true, nil
+					return true, true, false, nil
 				}
 			}
 		}
-		return packagesPresent, false, nil
+		return true, false, false, nil
 	}
 
+	// A CDU redirects the package list
 	if resp.StatusCode == http.StatusFound {
-		isCDU = true
-		return true, false, nil
-	} else {
-		return true, false, fmt.Errorf("Error: List packages request returned status code: %d", resp.StatusCode)
+		return true, false, true, nil
 	}
-
-	return packagesPresent, newPackagesPresent, nil
+	return true, false, false, fmt.Errorf("Error: List packages request returned status code: %d", resp.StatusCode)
 }
 
 func installHostAgentLegacy(ctx objects.Config, regionURL string, auth keystone.KeystoneAuth, hostOS string, exec cmdexec.Executor) error {
@@ -553,7 +561,7 @@ func installHostAgentLegacy(ctx objects.Config, regionURL string, auth keystone.
 		_, err = exec.RunWithStdout("bash", "-c", cmd)
 	}
 
-	removeTempDirAndInstaller(exec)
+	removeTempDirAndInstaller(exec, homeDir)
 
 	if err != nil {
 		_, exitCode := cmdexec.ExitCodeChecker(err)
@@ -574,8 +582,7 @@ func CheckSudo(exec cmdexec.Executor) bool {
 }
 
 func createDirToDownloadInstaller(exec cmdexec.Executor) (string, error) {
-	var err error
-	homeDir, err = exec.RunWithStdout("bash", "-c", "echo $HOME")
+	homeDir, err := exec.RunWithStdout("bash", "-c", "echo $HOME")
 	homeDir = strings.TrimSpace(strings.Trim(homeDir, "\n\""))
 	if err != nil {
 		return "", err
@@ -589,3 +596,11 @@ func createDirToDownloadInstaller(exec cmdexec.Executor) (string, error) {
 	}
 	return homeDir, nil
 }
+
+// setSpinnerSuffix changes the spinner text under its lock, the spinner renders it
+// from its own goroutine
+func setSpinnerSuffix(s *spinner.Spinner, suffix string) {
+	s.Lock()
+	s.Suffix = suffix
+	s.Unlock()
+}
@@ -0,0 +1,182 @@
+package pmk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+)
+
+// Certificates the hostagent authenticates with to the management plane
+const (
+	HostAgentCertsDir = "/etc/pf9/certs"
+	hostAgentCACert   = HostAgentCertsDir + "/ca/cert.pem"
+	hostAgentCert     = HostAgentCertsDir + "/hostagent/cert.pem"
+	hostAgentKey      = HostAgentCertsDir + "/hostagent/key.pem"
+)
+
+// repairStep is one step of a node repair. The host is given time to respond
+// after the steps which change it, a failed step escalates to the next one.
+type repairStep struct {
+	name string
+	run  func() error
+	wait bool
+}
+
+// RepairNode escalates through the repair steps until resmgr reports the host of the
+// client executor responding, each step waits up to timeout for it. It returns the
+// step which brought the host back. Only a host which is not responding is repaired,
+// the other failures to get its status are returned. A dry run goes through all
+// the steps without waiting for the host.
+func RepairNode(ctx objects.Config, c client.Client, keystoneAuth keystone.KeystoneAuth, timeout time.Duration) (string, error) {
+	exec := c.Executor
+	out := c.Output()
+	dryRun, isDryRun := exec.(cmdexec.DryRunExecutor)
+
+	hostID, err := ReadHostID(exec)
+	if err != nil {
+		return "", fmt.Errorf("The node is not onboarded, run pf9ctl prep-node: %w", err)
+	}
+	err = c.Resmgr.HostStatus(keystoneAuth.Token, hostID)
+	if err == nil {
+		fmt.Fprintln(out, color.Green("✓ ")+fmt.Sprintf("Host %s is responding, nothing to repair", hostID))
+		return "nothing to repair", nil
+	}
+	if !errors.Is(err, resmgr.ErrHostNotResponding) {
+		return "", fmt.Errorf("Unable to get the status of host %s: %w", hostID, err)
+	}
+	fmt.Fprintf(out, "Host %s: %s\n", hostID, err.Error())
+
+	steps := []repairStep{
+		{
+			name: "Restart pf9-comms and pf9-hostagent",
+			run:  func() error { return RestartPf9Services(exec) },
+			wait: true,
+		},
+		{
+			name: fmt.Sprintf("Check the certificates in %s", HostAgentCertsDir),
+			run:  func() error { return CheckHostAgentCerts(exec) },
+		},
+		{
+			name: "Reinstall the hostagent",
+			run: func() error {
+				hostOS, err := ValidatePlatform(exec)
+				if err != nil {
+					return fmt.Errorf("Invalid host OS: %w", err)
+				}
+				if _, err := installHostAgent(ctx, keystoneAuth, hostOS, exec); err != nil {
+					return err
+				}
+				// The installer keeps the host ID, it is read again in case it did not
+				id, err := ReadHostID(exec)
+				if err != nil {
+					return err
+				}
+				hostID = id
+				return nil
+			},
+			wait: true,
+		},
+		{
+			name: "Authorize the host again",
+			run: func() error {
+				if isDryRun {
+					dryRun.Record(fmt.Sprintf("authorize host %s with the pf9-kube role", hostID))
+					return nil
+				}
+				return c.Resmgr.AuthorizeHost(hostID, keystoneAuth.Token, util.KubeVersion, keystoneAuth.ProjectID)
+			},
+			wait: true,
+		},
+	}
+
+	for i, step := range steps {
+		fmt.Fprintf(out, "Step %d/%d: %s\n", i+1, len(steps), step.name)
+		if err := step.run(); err != nil {
+			fmt.Fprintln(out, color.Red("x ")+err.Error())
+			continue
+		}
+		fmt.Fprintln(out, color.Green("✓ ")+step.name)
+		if !step.wait || isDryRun {
+			continue
+		}
+		if err := waitForHostResponding(c, keystoneAuth, hostID, timeout); err != nil {
+			if !errors.Is(err, resmgr.ErrHostNotResponding) && !errors.Is(err, resmgr.ErrHostNotFound) {
+				return "", err
+			}
+			fmt.Fprintln(out, color.Yellow("! ")+err.Error())
+			continue
+		}
+		fmt.Fprintln(out, color.Green("✓ ")+fmt.Sprintf("Host %s is responding again", hostID))
+		return step.name, nil
+	}
+	if isDryRun {
+		return fmt.Sprintf("dry run of %d repair steps", len(steps)), nil
+	}
+	return "", fmt.Errorf("Host %s is still not responding after %d repair steps, see /var/log/pf9/hostagent.log and /var/log/pf9/comms/comms.log", hostID, len(steps))
+}
+
+// waitForHostResponding polls resmgr until the host is responding or the timeout runs out.
+// A reinstalled host may not be registered yet, the other failures are returned right away.
+func waitForHostResponding(c client.Client, keystoneAuth keystone.KeystoneAuth, hostID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := c.Resmgr.HostStatus(keystoneAuth.Token, hostID)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, resmgr.ErrHostNotResponding) && !errors.Is(err, resmgr.ErrHostNotFound) {
+			return fmt.Errorf("Unable to get the status of host %s: %w", hostID, err)
+		}
+		zap.S().Debugf("Host %s is not ready: %s", hostID, err.Error())
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("Host %s is still not responding after %s: %w", hostID, timeout, err)
+		}
+		time.Sleep(min(hostPollInterval, remaining))
+	}
+}
+
+// CheckHostAgentCerts checks the hostagent certificate and the CA are present and not
+// expired, the certificate is signed by the CA and matches the key
+func CheckHostAgentCerts(exec cmdexec.Executor) error {
+	for _, cert := range []string{hostAgentCACert, hostAgentCert} {
+		if output, err := exec.RunWithStdout("openssl", "x509", "-noout", "-checkend", "0", "-in", cert); err != nil {
+			return fmt.Errorf("%s is missing or expired: %s", cert, certError(output, err))
+		}
+	}
+	if output, err := exec.RunWithStdout("openssl", "verify", "-CAfile", hostAgentCACert, hostAgentCert); err != nil {
+		return fmt.Errorf("%s is not signed by %s: %s", hostAgentCert, hostAgentCACert, certError(output, err))
+	}
+
+	certKey, err := exec.RunWithStdout("openssl", "x509", "-noout", "-pubkey", "-in", hostAgentCert)
+	if err != nil {
+		return fmt.Errorf("Unable to read the public key of %s: %w", hostAgentCert, err)
+	}
+	key, err := exec.RunWithStdout("openssl", "pkey", "-pubout", "-in", hostAgentKey)
+	if err != nil {
+		return fmt.Errorf("Unable to read %s: %w", hostAgentKey, err)
+	}
+	if strings.TrimSpace(certKey) != strings.TrimSpace(key) {
+		return errors.New(hostAgentKey + " does not match " + hostAgentCert)
+	}
+	return nil
+}
+
+// certError returns what openssl printed about a certificate, the error otherwise
+func certError(output string, err error) string {
+	if output = strings.TrimSpace(output); output != "" {
+		return output
+	}
+	return err.Error()
+}
@@ -0,0 +1,169 @@
+package pmk
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/fakedu"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepairNode(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+	interval := hostPollInterval
+	t.Cleanup(func() { hostPollInterval = interval })
+	hostPollInterval = time.Millisecond
+
+	ctx := objects.Config{
+		Fqdn:          du.URL,
+		Username:      fakedu.DefaultUsername,
+		Password:      fakedu.DefaultPassword,
+		Tenant:        fakedu.DefaultTenant,
+		Region:        fakedu.DefaultRegion,
+		AllowInsecure: true,
+	}
+
+	cases := map[string]struct {
+		responding bool
+		// unknown hosts are not registered with resmgr
+		unknown bool
+		dryRun  bool
+		// fixedBy is the command bringing the host back
+		fixedBy    string
+		certsValid bool
+		step       string
+		err        string
+		authorized bool
+	}{
+		"responding":         {responding: true, step: "nothing to repair"},
+		"fixed by a restart": {fixedBy: "systemctl restart pf9-comms", step: "Restart pf9-comms and pf9-hostagent"},
+		"fixed by reinstall": {fixedBy: "installer.sh --no-proxy", step: "Reinstall the hostagent"},
+		"not fixed":          {certsValid: true, err: "still not responding after 4 repair steps", authorized: true},
+		"dry run":            {dryRun: true, certsValid: true, step: "dry run of 4 repair steps"},
+		"unknown host":       {unknown: true, err: "Unable to get the status of host host-unknown-host: host not found"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			id := "host-" + strings.ReplaceAll(name, " ", "-")
+			if !tc.unknown {
+				du.AddHost(id, name, "10.0.0.1")
+				du.SetResponding(id, tc.responding)
+			}
+
+			var ran []string
+			var received [][]string
+			var exec cmdexec.Executor = &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					cmd := strings.Join(append([]string{name}, args...), " ")
+					ran = append(ran, cmd)
+					received = append(received, append([]string{name}, args...))
+					if tc.fixedBy != "" && strings.Contains(cmd, tc.fixedBy) {
+						du.SetResponding(id, true)
+					}
+					switch {
+					case cmd == "cat "+HostIDFile:
+						return "[hostagent]\nhost_id = " + id + "\n", nil
+					case cmd == "cat /etc/os-release":
+						return "NAME=\"Ubuntu\"\nPRETTY_NAME=\"Ubuntu 22.04.3 LTS\"\n", nil
+					case strings.Contains(cmd, "grep -i pretty_name"):
+						return "22.04.3\n", nil
+					case strings.Contains(cmd, "echo $HOME"):
+						return "/home/ubuntu\n", nil
+					case strings.HasPrefix(cmd, "openssl") && !tc.certsValid:
+						return "Certificate will expire\n", errors.New("exit status 1")
+					}
+					return "", nil
+				},
+			}
+			if tc.dryRun {
+				cmdexec.ResetPlan()
+				defer cmdexec.ResetPlan()
+				exec = cmdexec.DryRunExecutor{Executor: exec, Host: "10.0.0.1"}
+			}
+			c, err := client.NewClient(du.URL, exec, true, true)
+			assert.Nil(t, err)
+			out := &bytes.Buffer{}
+			c.Out = out
+			auth, err := c.Keystone.GetAuth(ctx.Username, ctx.Password, ctx.Tenant, "")
+			assert.Nil(t, err)
+
+			step, err := RepairNode(ctx, c, auth, 10*time.Millisecond)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, tc.step, step)
+			}
+			if tc.responding || tc.unknown {
+				assert.Equal(t, []string{"cat " + HostIDFile}, ran)
+			}
+			if !tc.certsValid && !tc.responding && !tc.unknown && tc.step != "Restart pf9-comms and pf9-hostagent" {
+				assert.Contains(t, out.String(), hostAgentCACert+" is missing or expired: Certificate will expire")
+			}
+			if tc.err != "" && !tc.unknown {
+				assert.Contains(t, out.String(), "Step 4/4: Authorize the host again")
+			}
+
+			if tc.dryRun {
+				plan := cmdexec.Plan()
+				assert.Contains(t, plan, cmdexec.PlannedStep{Host: "10.0.0.1", Step: "authorize host " + id + " with the pf9-kube role"})
+				// Only the read-only probes reach the host
+				for _, words := range received {
+					assert.True(t, cmdexec.IsReadOnly(words[0], words[1:]...), strings.Join(words, " "))
+				}
+			}
+
+			authorized := false
+			for _, node := range du.Nodes() {
+				authorized = authorized || node.Uuid == id
+			}
+			assert.Equal(t, tc.authorized, authorized)
+		})
+	}
+}
+
+func TestCheckHostAgentCerts(t *testing.T) {
+	cases := map[string]struct {
+		fail   string
+		output string
+		keyPub string
+		err    string
+	}{
+		"valid":        {keyPub: "PUBKEY"},
+		"expired":      {fail: "-checkend 0 -in " + hostAgentCert, output: "Certificate will expire", err: hostAgentCert + " is missing or expired: Certificate will expire"},
+		"missing CA":   {fail: "-checkend 0 -in " + hostAgentCACert, err: hostAgentCACert + " is missing or expired: exit status 1"},
+		"not signed":   {fail: "openssl verify", output: "unable to get local issuer certificate", err: "is not signed by " + hostAgentCACert},
+		"key mismatch": {keyPub: "OTHER", err: hostAgentKey + " does not match " + hostAgentCert},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					cmd := strings.Join(append([]string{name}, args...), " ")
+					switch {
+					case tc.fail != "" && strings.Contains(cmd, tc.fail):
+						return tc.output, errors.New("exit status 1")
+					case strings.Contains(cmd, "-pubkey"):
+						return "PUBKEY\n", nil
+					case strings.Contains(cmd, "-pubout"):
+						return tc.keyPub + "\n", nil
+					}
+					return "", nil
+				},
+			}
+			err := CheckHostAgentCerts(exec)
+			if tc.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
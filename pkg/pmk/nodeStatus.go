@@ -0,0 +1,246 @@
+package pmk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/resmgr"
+	"github.com/platform9/pf9ctl/pkg/util"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Verdicts of the node status
+const (
+	NodeHealthy      = "healthy"
+	NodeDegraded     = "degraded"
+	NodeDisconnected = "disconnected"
+)
+
+// Units of the Platform9 agents, the kube ones only run on the nodes with the
+// pf9-kube role
+var (
+	agentUnits = []string{"pf9-hostagent", "pf9-comms"}
+	kubeUnits  = []string{"pf9-nodeletd", "pf9-kubelet"}
+)
+
+// NodeStatus is the health of the Platform9 agents of a node, as seen on the
+// node and by resmgr
+type NodeStatus struct {
+	Host   string `json:"host" yaml:"host"`
+	HostID string `json:"hostId,omitempty" yaml:"hostId,omitempty"`
+	// Units are the states of the Platform9 units, as reported by systemctl is-active
+	Units map[string]string `json:"units" yaml:"units"`
+	// Packages are the versions of the installed Platform9 packages
+	Packages map[string]string `json:"packages" yaml:"packages"`
+	// PackagesOutdated is true when the packages differ from the ones of the
+	// management plane
+	PackagesOutdated bool     `json:"packagesOutdated" yaml:"packagesOutdated"`
+	Registered       bool     `json:"registered" yaml:"registered"`
+	Responding       bool     `json:"responding" yaml:"responding"`
+	Roles            []string `json:"roles" yaml:"roles"`
+	RoleStatus       string   `json:"roleStatus,omitempty" yaml:"roleStatus,omitempty"`
+	Verdict          string   `json:"verdict" yaml:"verdict"`
+	Hints            []string `json:"hints,omitempty" yaml:"hints,omitempty"`
+}
+
+// GetNodeStatus collects the status of the node of the client executor, fqdn is
+// the management plane the packages are compared with
+func GetNodeStatus(c client.Client, keystoneAuth keystone.KeystoneAuth, fqdn, host string) (NodeStatus, error) {
+	exec := c.Executor
+	status := NodeStatus{Host: host, Units: unitStates(exec, append(agentUnits, kubeUnits...)), Packages: map[string]string{}, Roles: []string{}}
+
+	if hostOS, err := ValidatePlatform(exec); err != nil {
+		zap.S().Debugf("Unable to detect the OS of %s, skipping the packages: %s", host, err.Error())
+	} else {
+		status.Packages = installedPf9Packages(hostOS, exec)
+		present, current, cdu, err := pf9PackagesPresent(hostOS, exec, keystoneAuth.Token, fqdn)
+		if err != nil {
+			zap.S().Debugf("Unable to compare the packages of %s with the management plane: %s", host, err.Error())
+		} else if present && !current && !cdu {
+			status.PackagesOutdated = true
+		}
+	}
+
+	hostID, err := ReadHostID(exec)
+	if err != nil {
+		zap.S().Debugf("No host ID on %s: %s", host, err.Error())
+	}
+	status.HostID = hostID
+	if hostID != "" {
+		err := c.Resmgr.HostStatus(keystoneAuth.Token, hostID)
+		switch {
+		case err == nil:
+			status.Registered, status.Responding = true, true
+		case errors.Is(err, resmgr.ErrHostNotResponding):
+			status.Registered = true
+		case !errors.Is(err, resmgr.ErrHostNotFound):
+			return status, fmt.Errorf("Unable to get the status of host %s: %w", hostID, err)
+		}
+	}
+	if status.Registered {
+		hosts, err := c.Resmgr.ListHosts(keystoneAuth.Token)
+		if err != nil {
+			return status, fmt.Errorf("Unable to list the hosts: %w", err)
+		}
+		for _, h := range hosts {
+			if h.ID == hostID {
+				status.Roles = append(status.Roles, h.Roles...)
+				status.RoleStatus = h.RoleStatus
+			}
+		}
+		sort.Strings(status.Roles)
+	}
+
+	status.Verdict, status.Hints = nodeVerdict(status)
+	return status, nil
+}
+
+// unitStates returns the state of each unit, unknown when systemd does not report it
+func unitStates(exec cmdexec.Executor, units []string) map[string]string {
+	// is-active fails when a unit is not active, the states are printed anyway
+	output, _ := exec.RunWithStdout("systemctl", append([]string{"is-active"}, units...)...)
+	states := strings.Fields(output)
+	unitStates := map[string]string{}
+	for i, unit := range units {
+		unitStates[unit] = "unknown"
+		if len(states) == len(units) {
+			unitStates[unit] = states[i]
+		}
+	}
+	return unitStates
+}
+
+// installedPf9Packages returns the versions of the Platform9 packages installed
+func installedPf9Packages(hostOS string, exec cmdexec.Executor) map[string]string {
+	var output string
+	// Both fail when one of the packages is not installed, the others are printed anyway
+	if hostOS == "debian" {
+		output, _ = exec.RunWithStdout("dpkg-query", append([]string{"--show"}, util.Pf9Packages...)...)
+	} else {
+		output, _ = exec.RunWithStdout("rpm", append([]string{"-q", "--qf", `%{NAME}\t%{VERSION}-%{RELEASE}\n`}, util.Pf9Packages...)...)
+	}
+	packages := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		name, version, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if ok && version != "" {
+			packages[name] = version
+		}
+	}
+	return packages
+}
+
+// nodeVerdict returns the verdict of the node and hints to fix what is wrong
+func nodeVerdict(s NodeStatus) (string, []string) {
+	if s.HostID == "" {
+		return NodeDisconnected, []string{fmt.Sprintf("No host ID in %s, the node is not onboarded, run pf9ctl prep-node", HostIDFile)}
+	}
+	if !s.Registered {
+		return NodeDisconnected, []string{fmt.Sprintf("Host %s is not known to the management plane, run pf9ctl prep-node to onboard it again", s.HostID)}
+	}
+
+	var hints []string
+	for _, unit := range agentUnits {
+		if s.Units[unit] != "active" {
+			hints = append(hints, fmt.Sprintf("%s is %s, restart it with systemctl restart %s", unit, s.Units[unit], unit))
+		}
+	}
+	if !s.Responding {
+		hints = append(hints, "The hostagent does not report to the management plane, check pf9-comms reaches it through the network or the proxy (pf9ctl proxy show) and /var/log/pf9/comms/comms.log, or run pf9ctl node repair")
+		return NodeDisconnected, hints
+	}
+
+	if containsString(s.Roles, kubeRole) {
+		for _, unit := range kubeUnits {
+			if s.Units[unit] != "active" {
+				hints = append(hints, fmt.Sprintf("%s is %s, see journalctl -u %s", unit, s.Units[unit], unit))
+			}
+		}
+	}
+	if s.RoleStatus != "" && s.RoleStatus != "ok" {
+		hints = append(hints, fmt.Sprintf("The roles are %s, see /var/log/pf9/hostagent.log", s.RoleStatus))
+	}
+	if s.PackagesOutdated {
+		hints = append(hints, "The Platform9 packages differ from the ones of the management plane, run pf9ctl prep-node to upgrade them")
+	}
+	if len(hints) > 0 {
+		return NodeDegraded, hints
+	}
+	return NodeHealthy, nil
+}
+
+// colorVerdict returns the verdict in the color of its severity
+func colorVerdict(verdict string) string {
+	switch verdict {
+	case NodeHealthy:
+		return color.Green(verdict)
+	case NodeDegraded:
+		return color.Yellow(verdict)
+	}
+	return color.Red(verdict)
+}
+
+// formatVersions lists the units or packages with their state or version, as name=value
+func formatVersions(values map[string]string) string {
+	var list []string
+	for name, value := range values {
+		list = append(list, name+"="+value)
+	}
+	sort.Strings(list)
+	return strings.Join(list, " ")
+}
+
+// WriteNodeStatus renders the status of the nodes as text, JSON or YAML
+func WriteNodeStatus(w io.Writer, format string, statuses []NodeStatus) error {
+	switch format {
+	case OutputText:
+		for i, s := range statuses {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s: %s\n", s.Host, colorVerdict(s.Verdict))
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(tw, "  Host ID:\t%s\n", s.HostID)
+			resmgrState := "not registered"
+			if s.Registered {
+				resmgrState = "not responding"
+				if s.Responding {
+					resmgrState = "responding"
+				}
+				if len(s.Roles) > 0 {
+					resmgrState += fmt.Sprintf(", roles %s", strings.Join(s.Roles, ","))
+				}
+				if s.RoleStatus != "" {
+					resmgrState += fmt.Sprintf(" (%s)", s.RoleStatus)
+				}
+			}
+			fmt.Fprintf(tw, "  Resmgr:\t%s\n", resmgrState)
+			fmt.Fprintf(tw, "  Units:\t%s\n", formatVersions(s.Units))
+			fmt.Fprintf(tw, "  Packages:\t%s\n", formatVersions(s.Packages))
+			tw.Flush()
+			for _, hint := range s.Hints {
+				fmt.Fprintf(w, "  - %s\n", hint)
+			}
+		}
+		return nil
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(statuses)
+	case OutputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		return encoder.Encode(statuses)
+	}
+	return fmt.Errorf("Output format %q is not supported for node status, use %s, %s or %s", format, OutputText, OutputJSON, OutputYAML)
+}
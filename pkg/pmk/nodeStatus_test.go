@@ -0,0 +1,215 @@
+package pmk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/fakedu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeVerdict(t *testing.T) {
+	active := map[string]string{"pf9-hostagent": "active", "pf9-comms": "active", "pf9-nodeletd": "active", "pf9-kubelet": "active"}
+	with := func(unit, state string) map[string]string {
+		units := map[string]string{}
+		for u, s := range active {
+			units[u] = s
+		}
+		units[unit] = state
+		return units
+	}
+	healthy := NodeStatus{HostID: "host-1", Units: active, Registered: true, Responding: true, Roles: []string{"pf9-kube"}, RoleStatus: "ok"}
+
+	cases := map[string]struct {
+		change  func(s *NodeStatus)
+		verdict string
+		hint    string
+	}{
+		"healthy":           {change: func(s *NodeStatus) {}, verdict: NodeHealthy},
+		"not onboarded":     {change: func(s *NodeStatus) { s.HostID = "" }, verdict: NodeDisconnected, hint: "run pf9ctl prep-node"},
+		"not registered":    {change: func(s *NodeStatus) { s.Registered, s.Responding = false, false }, verdict: NodeDisconnected, hint: "not known to the management plane"},
+		"not responding":    {change: func(s *NodeStatus) { s.Responding = false }, verdict: NodeDisconnected, hint: "does not report to the management plane"},
+		"comms stopped":     {change: func(s *NodeStatus) { s.Units = with("pf9-comms", "failed"); s.Responding = false }, verdict: NodeDisconnected, hint: "pf9-comms is failed"},
+		"hostagent stopped": {change: func(s *NodeStatus) { s.Units = with("pf9-hostagent", "inactive") }, verdict: NodeDegraded, hint: "systemctl restart pf9-hostagent"},
+		"kubelet stopped":   {change: func(s *NodeStatus) { s.Units = with("pf9-kubelet", "inactive") }, verdict: NodeDegraded, hint: "journalctl -u pf9-kubelet"},
+		"no kube role":      {change: func(s *NodeStatus) { s.Units = with("pf9-kubelet", "inactive"); s.Roles = nil }, verdict: NodeHealthy},
+		"roles failed":      {change: func(s *NodeStatus) { s.RoleStatus = "failed" }, verdict: NodeDegraded, hint: "The roles are failed"},
+		"outdated packages": {change: func(s *NodeStatus) { s.PackagesOutdated = true }, verdict: NodeDegraded, hint: "run pf9ctl prep-node to upgrade"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := healthy
+			tc.change(&s)
+			verdict, hints := nodeVerdict(s)
+			assert.Equal(t, tc.verdict, verdict)
+			if tc.hint == "" {
+				assert.Empty(t, hints)
+				return
+			}
+			assert.Contains(t, strings.Join(hints, "\n"), tc.hint)
+		})
+	}
+}
+
+func TestInstalledPf9Packages(t *testing.T) {
+	var ran string
+	exec := &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			ran = strings.Join(append([]string{name}, args...), " ")
+			return "pf9-comms\t5.9.0-123\npf9-hostagent\t5.9.0-123\npf9-kube\t\n", errors.New("exit status 1")
+		},
+	}
+
+	assert.Equal(t, map[string]string{"pf9-comms": "5.9.0-123", "pf9-hostagent": "5.9.0-123"}, installedPf9Packages("debian", exec))
+	assert.True(t, strings.HasPrefix(ran, "dpkg-query --show pf9-hostagent pf9-comms"))
+	installedPf9Packages("redhat", exec)
+	assert.True(t, strings.HasPrefix(ran, `rpm -q --qf %{NAME}\t%{VERSION}-%{RELEASE}\n pf9-hostagent`))
+}
+
+func TestGetNodeStatus(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+
+	c, err := client.NewClient(du.URL, nil, true, true)
+	assert.Nil(t, err)
+	auth, err := c.Keystone.GetAuth(fakedu.DefaultUsername, fakedu.DefaultPassword, fakedu.DefaultTenant, "")
+	assert.Nil(t, err)
+	du.AddHost("host-1", "node-1", "10.0.0.1")
+	assert.Nil(t, c.Resmgr.AuthorizeHost("host-1", auth.Token, "", auth.ProjectID))
+
+	hostIDConf := "[hostagent]\nhost_id = host-1\n"
+	units := "active\nactive\nactive\nactive\n"
+	c.Executor = &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			switch {
+			case name == "systemctl":
+				return units, errors.New("exit status 3")
+			case name == "cat" && args[0] == HostIDFile:
+				return hostIDConf, nil
+			}
+			return "", errors.New("exit status 1")
+		},
+	}
+
+	status, err := GetNodeStatus(c, auth, du.URL, "10.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, "host-1", status.HostID)
+	assert.True(t, status.Registered)
+	assert.True(t, status.Responding)
+	assert.Equal(t, map[string]string{"pf9-hostagent": "active", "pf9-comms": "active", "pf9-nodeletd": "active", "pf9-kubelet": "active"}, status.Units)
+	assert.Equal(t, []string{"pf9-kube"}, status.Roles)
+	assert.Equal(t, "ok", status.RoleStatus)
+	assert.Equal(t, NodeHealthy, status.Verdict)
+
+	units = "active\nactive\nactive\nfailed\n"
+	status, err = GetNodeStatus(c, auth, du.URL, "10.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, NodeDegraded, status.Verdict)
+	assert.Equal(t, []string{"pf9-kubelet is failed, see journalctl -u pf9-kubelet"}, status.Hints)
+
+	du.SetResponding("host-1", false)
+	status, err = GetNodeStatus(c, auth, du.URL, "10.0.0.1")
+	assert.Nil(t, err)
+	assert.True(t, status.Registered)
+	assert.False(t, status.Responding)
+	assert.Equal(t, NodeDisconnected, status.Verdict)
+
+	hostIDConf = "[hostagent]\nhost_id = host-2\n"
+	status, err = GetNodeStatus(c, auth, du.URL, "10.0.0.1")
+	assert.Nil(t, err)
+	assert.False(t, status.Registered)
+	assert.Equal(t, NodeDisconnected, status.Verdict)
+
+	units = ""
+	status, err = GetNodeStatus(c, auth, du.URL, "10.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, "unknown", status.Units["pf9-comms"])
+}
+
+// TestGetNodeStatusInParallel gets the status of two hosts at once, one compared with a
+// CDU which does not list its packages, run it with -race to catch shared host state.
+func TestGetNodeStatusInParallel(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+	cdu := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer cdu.Close()
+
+	c, err := client.NewClient(du.URL, nil, true, true)
+	assert.Nil(t, err)
+	auth, err := c.Keystone.GetAuth(fakedu.DefaultUsername, fakedu.DefaultPassword, fakedu.DefaultTenant, "")
+	assert.Nil(t, err)
+
+	fqdns := map[string]string{"10.0.0.1": du.URL, "10.0.0.2": cdu.URL}
+	results := RunOnHosts(io.Discard, []string{"10.0.0.1", "10.0.0.2"}, 2, func(host string, out io.Writer) (string, error) {
+		hc := c
+		hc.Out = out
+		hc.Executor = &cmdexec.MockExecutor{
+			MockRunWithStdout: func(name string, args ...string) (string, error) {
+				cmd := strings.Join(append([]string{name}, args...), " ")
+				switch {
+				case cmd == "cat /etc/os-release":
+					return "NAME=\"Ubuntu\"\nPRETTY_NAME=\"Ubuntu 22.04.3 LTS\"\n", nil
+				case strings.Contains(cmd, "grep -i pretty_name"):
+					return "22.04.3\n", nil
+				case strings.Contains(cmd, "dpkg -l") && !strings.Contains(cmd, ".*"):
+					return "ii  pf9-hostagent  5.9.0-123  amd64\n", nil
+				}
+				return "", errors.New("exit status 1")
+			},
+		}
+		status, err := GetNodeStatus(hc, auth, fqdns[host], host)
+		if err != nil {
+			return "", err
+		}
+		if status.PackagesOutdated {
+			return "outdated", nil
+		}
+		return "current", nil
+	})
+	assert.Equal(t, 0, HostsFailed(results))
+	assert.Equal(t, "outdated", results[0].Result)
+	assert.Equal(t, "current", results[1].Result)
+}
+
+func TestWriteNodeStatus(t *testing.T) {
+	statuses := []NodeStatus{{
+		Host:       "10.0.0.1",
+		HostID:     "host-1",
+		Units:      map[string]string{"pf9-hostagent": "active", "pf9-comms": "failed"},
+		Packages:   map[string]string{"pf9-hostagent": "5.9.0-123"},
+		Registered: true,
+		Roles:      []string{"pf9-kube"},
+		RoleStatus: "ok",
+		Verdict:    NodeDisconnected,
+		Hints:      []string{"pf9-comms is failed, restart it with systemctl restart pf9-comms"},
+	}}
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, WriteNodeStatus(out, OutputText, statuses))
+	assert.Contains(t, out.String(), "10.0.0.1: ")
+	assert.Contains(t, out.String(), "not responding, roles pf9-kube (ok)")
+	assert.Contains(t, out.String(), "pf9-comms=failed pf9-hostagent=active")
+	assert.Contains(t, out.String(), "  - pf9-comms is failed")
+
+	out.Reset()
+	assert.Nil(t, WriteNodeStatus(out, OutputJSON, statuses))
+	var decoded []NodeStatus
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, statuses, decoded)
+
+	out.Reset()
+	assert.Nil(t, WriteNodeStatus(out, OutputYAML, statuses))
+	assert.Contains(t, out.String(), "verdict: disconnected")
+
+	assert.ErrorContains(t, WriteNodeStatus(out, OutputJUnit, statuses), "not supported for node status")
+}
@@ -0,0 +1,187 @@
+package pmk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"gopkg.in/yaml.v3"
+)
+
+// Roles of a node in its cluster
+const (
+	NodeRoleMaster = "master"
+	NodeRoleWorker = "worker"
+)
+
+// Resmgr role of the hosts which can be nodes of a cluster
+const kubeRole = "pf9-kube"
+
+// NodeInfo is a host known to resmgr joined with its qbert node, if it has one
+type NodeInfo struct {
+	Hostname    string            `json:"hostname" yaml:"hostname"`
+	PrimaryIP   string            `json:"primaryIp" yaml:"primaryIp"`
+	HostID      string            `json:"hostId" yaml:"hostId"`
+	Responding  bool              `json:"responding" yaml:"responding"`
+	Roles       map[string]string `json:"roles" yaml:"roles"`
+	ClusterName string            `json:"clusterName,omitempty" yaml:"clusterName,omitempty"`
+	ClusterUUID string            `json:"clusterUuid,omitempty" yaml:"clusterUuid,omitempty"`
+	ClusterRole string            `json:"clusterRole,omitempty" yaml:"clusterRole,omitempty"`
+}
+
+// NodeFilter selects the nodes listed, the zero value selects all of them
+type NodeFilter struct {
+	// Cluster is the name or the UUID of a cluster
+	Cluster      string
+	Unassigned   bool
+	Disconnected bool
+}
+
+// Match returns true when the node is selected by the filter
+func (f NodeFilter) Match(n NodeInfo) bool {
+	if f.Cluster != "" && f.Cluster != n.ClusterName && f.Cluster != n.ClusterUUID {
+		return false
+	}
+	if f.Unassigned && n.ClusterUUID != "" {
+		return false
+	}
+	if f.Disconnected && n.Responding {
+		return false
+	}
+	return true
+}
+
+// ListNodes returns every host registered with resmgr, with its cluster from qbert
+func ListNodes(c client.Client, keystoneAuth keystone.KeystoneAuth, filter NodeFilter) ([]NodeInfo, error) {
+	hosts, err := c.Resmgr.ListHosts(keystoneAuth.Token)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the hosts: %w", err)
+	}
+	qbertNodes, err := c.Qbert.GetAllNodes(keystoneAuth.Token, keystoneAuth.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the nodes: %w", err)
+	}
+	nodesByID := map[string]qbert.Node{}
+	for _, n := range qbertNodes {
+		nodesByID[n.Uuid] = n
+	}
+
+	nodes := []NodeInfo{}
+	for _, h := range hosts {
+		info := NodeInfo{Hostname: h.Hostname, HostID: h.ID, Responding: h.Responding, Roles: map[string]string{}}
+		if len(h.IPs) > 0 {
+			info.PrimaryIP = h.IPs[0]
+		}
+		for _, role := range h.Roles {
+			info.Roles[role] = ""
+		}
+		if n, ok := nodesByID[h.ID]; ok {
+			if n.PrimaryIp != "" {
+				info.PrimaryIP = n.PrimaryIp
+			}
+			if _, ok := info.Roles[kubeRole]; ok {
+				info.Roles[kubeRole] = n.ActualKubeRoleVersion
+			}
+			info.ClusterName, info.ClusterUUID = n.ClusterName, n.ClusterUuid
+			if n.ClusterUuid != "" {
+				info.ClusterRole = NodeRoleWorker
+				if n.IsMaster == 1 {
+					info.ClusterRole = NodeRoleMaster
+				}
+			}
+		}
+		if filter.Match(info) {
+			nodes = append(nodes, info)
+		}
+	}
+	return nodes, nil
+}
+
+// HostIDFile is where hostagent stores the ID of the host
+const HostIDFile = "/etc/pf9/host_id.conf"
+
+// ReadHostID returns the ID of the host from its hostagent config
+func ReadHostID(exec cmdexec.Executor) (string, error) {
+	conf, err := exec.RunWithStdout("cat", HostIDFile)
+	if err != nil {
+		return "", fmt.Errorf("Unable to read %s: %w", HostIDFile, err)
+	}
+	for _, line := range strings.Split(conf, "\n") {
+		name, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(name) == "host_id" && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	return "", fmt.Errorf("host_id not found in %s", HostIDFile)
+}
+
+// NodeCluster returns the cluster the host is attached to, nil when it is not attached
+func NodeCluster(c client.Client, keystoneAuth keystone.KeystoneAuth, hostID string) (*qbert.Cluster, error) {
+	nodes, err := c.Qbert.GetAllNodes(keystoneAuth.Token, keystoneAuth.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the nodes: %w", err)
+	}
+	clusterID := ""
+	for _, n := range nodes {
+		if n.Uuid == hostID {
+			clusterID = n.ClusterUuid
+		}
+	}
+	if clusterID == "" {
+		return nil, nil
+	}
+	clusters, err := c.Qbert.ListClusters(keystoneAuth.ProjectID, keystoneAuth.Token)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list clusters: %w", err)
+	}
+	for _, cluster := range clusters {
+		if cluster.Uuid == clusterID {
+			return &cluster, nil
+		}
+	}
+	return nil, fmt.Errorf("Cluster %s of host %s not found", clusterID, hostID)
+}
+
+// WriteNodes renders the nodes as a table, JSON or YAML
+func WriteNodes(w io.Writer, format string, nodes []NodeInfo) error {
+	switch format {
+	case OutputText:
+		tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(tw, "HOSTNAME\tPRIMARY IP\tHOST ID\tRESPONDING\tROLES\tCLUSTER\tCLUSTER ROLE")
+		for _, n := range nodes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%s\t%s\t%s\n",
+				n.Hostname, n.PrimaryIP, n.HostID, n.Responding, formatRoles(n.Roles), n.ClusterName, n.ClusterRole)
+		}
+		return tw.Flush()
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(nodes)
+	case OutputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		return encoder.Encode(nodes)
+	}
+	return fmt.Errorf("Output format %q is not supported for nodes, use %s, %s or %s", format, OutputText, OutputJSON, OutputYAML)
+}
+
+// formatRoles lists the roles with their version, as role=version
+func formatRoles(roles map[string]string) string {
+	var list []string
+	for role, version := range roles {
+		if version != "" {
+			role += "=" + version
+		}
+		list = append(list, role)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
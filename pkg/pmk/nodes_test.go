@@ -0,0 +1,159 @@
+package pmk
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/fakedu"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestListNodes(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+
+	c, err := client.NewClient(du.URL, nil, true, true)
+	assert.Nil(t, err)
+	auth, err := c.Keystone.GetAuth(fakedu.DefaultUsername, fakedu.DefaultPassword, fakedu.DefaultTenant, "")
+	assert.Nil(t, err)
+
+	du.AddHost("host-1", "master", "10.0.0.1")
+	du.AddHost("host-2", "worker", "10.0.0.2")
+	du.AddHost("host-3", "spare", "10.0.0.3")
+	for _, id := range []string{"host-1", "host-2", "host-3"} {
+		assert.Nil(t, c.Resmgr.AuthorizeHost(id, auth.Token, "", auth.ProjectID))
+	}
+	du.AddHost("host-4", "new", "10.0.0.4")
+	du.SetResponding("host-3", false)
+	clusterID, err := c.Qbert.CreateCluster(qbert.ClusterCreateRequest{Name: "prod"}, auth.ProjectID, auth.Token)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Qbert.AttachNode(clusterID, auth.ProjectID, auth.Token, []string{"host-1"}, "master"))
+	assert.Nil(t, c.Qbert.AttachNode(clusterID, auth.ProjectID, auth.Token, []string{"host-2"}, "worker"))
+
+	cases := map[string]struct {
+		filter NodeFilter
+		ids    []string
+	}{
+		"all":                     {filter: NodeFilter{}, ids: []string{"host-1", "host-2", "host-3", "host-4"}},
+		"cluster name":            {filter: NodeFilter{Cluster: "prod"}, ids: []string{"host-1", "host-2"}},
+		"cluster uuid":            {filter: NodeFilter{Cluster: clusterID}, ids: []string{"host-1", "host-2"}},
+		"unknown cluster":         {filter: NodeFilter{Cluster: "dev"}},
+		"unassigned":              {filter: NodeFilter{Unassigned: true}, ids: []string{"host-3", "host-4"}},
+		"disconnected":            {filter: NodeFilter{Disconnected: true}, ids: []string{"host-3"}},
+		"unassigned disconnected": {filter: NodeFilter{Unassigned: true, Disconnected: true}, ids: []string{"host-3"}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			nodes, err := ListNodes(c, auth, tc.filter)
+			assert.Nil(t, err)
+			var ids []string
+			for _, n := range nodes {
+				ids = append(ids, n.HostID)
+			}
+			assert.Equal(t, tc.ids, ids)
+		})
+	}
+
+	nodes, err := ListNodes(c, auth, NodeFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, NodeInfo{
+		Hostname:    "master",
+		PrimaryIP:   "10.0.0.1",
+		HostID:      "host-1",
+		Responding:  true,
+		Roles:       map[string]string{"pf9-kube": fakedu.KubeRoleVersion},
+		ClusterName: "prod",
+		ClusterUUID: clusterID,
+		ClusterRole: NodeRoleMaster,
+	}, nodes[0])
+	assert.Equal(t, NodeRoleWorker, nodes[1].ClusterRole)
+	assert.Equal(t, NodeInfo{Hostname: "new", PrimaryIP: "10.0.0.4", HostID: "host-4", Responding: true, Roles: map[string]string{}}, nodes[3])
+}
+
+func TestWriteNodes(t *testing.T) {
+	nodes := []NodeInfo{
+		{Hostname: "master", PrimaryIP: "10.0.0.1", HostID: "host-1", Responding: true, Roles: map[string]string{"pf9-kube": "1.29.2-pmk.1"}, ClusterName: "prod", ClusterUUID: "c1", ClusterRole: NodeRoleMaster},
+		{Hostname: "new", PrimaryIP: "10.0.0.4", HostID: "host-4", Roles: map[string]string{}},
+	}
+
+	out := &bytes.Buffer{}
+	assert.Nil(t, WriteNodes(out, OutputText, nodes))
+	assert.Contains(t, out.String(), "HOSTNAME")
+	assert.Contains(t, out.String(), "pf9-kube=1.29.2-pmk.1")
+	assert.Contains(t, out.String(), "false")
+
+	for _, format := range []string{OutputJSON, OutputYAML} {
+		out.Reset()
+		assert.Nil(t, WriteNodes(out, format, nodes))
+		var decoded []NodeInfo
+		if format == OutputJSON {
+			assert.Nil(t, json.Unmarshal(out.Bytes(), &decoded))
+		} else {
+			assert.Nil(t, yaml.Unmarshal(out.Bytes(), &decoded))
+		}
+		assert.Equal(t, nodes, decoded)
+	}
+
+	assert.NotNil(t, WriteNodes(out, OutputJUnit, nodes))
+}
+
+func TestReadHostID(t *testing.T) {
+	cases := map[string]struct {
+		conf string
+		id   string
+		err  string
+	}{
+		"spaces":    {conf: "[hostagent]\nhost_id = 1b5c-42\n", id: "1b5c-42"},
+		"no spaces": {conf: "[hostagent]\nhost_id=1b5c-42", id: "1b5c-42"},
+		"missing":   {conf: "[hostagent]\n", err: "host_id not found"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			exec := &cmdexec.MockExecutor{
+				MockRunWithStdout: func(name string, args ...string) (string, error) {
+					return tc.conf, nil
+				},
+			}
+			id, err := ReadHostID(exec)
+			if tc.err != "" {
+				assert.ErrorContains(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.id, id)
+		})
+	}
+}
+
+func TestNodeCluster(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+
+	c, err := client.NewClient(du.URL, nil, true, true)
+	assert.Nil(t, err)
+	auth, err := c.Keystone.GetAuth(fakedu.DefaultUsername, fakedu.DefaultPassword, fakedu.DefaultTenant, "")
+	assert.Nil(t, err)
+
+	du.AddHost("host-1", "master", "10.0.0.1")
+	du.AddHost("host-2", "spare", "10.0.0.2")
+	for _, id := range []string{"host-1", "host-2"} {
+		assert.Nil(t, c.Resmgr.AuthorizeHost(id, auth.Token, "", auth.ProjectID))
+	}
+	clusterID, err := c.Qbert.CreateCluster(qbert.ClusterCreateRequest{Name: "prod", ContainerCIDR: "10.20.0.0/16"}, auth.ProjectID, auth.Token)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Qbert.AttachNode(clusterID, auth.ProjectID, auth.Token, []string{"host-1"}, "master"))
+
+	cluster, err := NodeCluster(c, auth, "host-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "prod", cluster.Name)
+	assert.Equal(t, "10.20.0.0/16", cluster.ContainerCIDR)
+
+	cluster, err = NodeCluster(c, auth, "host-2")
+	assert.Nil(t, err)
+	assert.Nil(t, cluster)
+}
@@ -0,0 +1,214 @@
+package pmk
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/color"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// HostResult is the outcome of running a node operation on one host
+type HostResult struct {
+	Host     string
+	Result   string
+	Err      error
+	Duration time.Duration
+}
+
+// HostFunc runs a node operation on a host, progress is printed to out
+type HostFunc func(host string, out io.Writer) (string, error)
+
+// RunOnHosts runs fn on every host, at most parallelism hosts at a time. Each host
+// gets its own progress line on out, the results are returned in the order of hosts.
+func RunOnHosts(out io.Writer, hosts []string, parallelism int, fn HostFunc) []HostResult {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	isTerm := false
+	if f, ok := out.(*os.File); ok {
+		isTerm = terminal.IsTerminal(int(f.Fd()))
+	}
+	progress := newHostProgress(out, hosts, isTerm)
+	progress.start()
+	defer progress.stop()
+
+	results := make([]HostResult, len(hosts))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			zap.S().Debugf("Starting on host %s", host)
+			start := time.Now()
+			result, err := fn(host, progress.writer(host))
+			results[i] = HostResult{Host: host, Result: result, Err: err, Duration: time.Since(start).Round(time.Second)}
+			if err != nil {
+				zap.S().Debugf("Failed on host %s: %s", host, err.Error())
+				progress.done(host, color.Red("x ")+err.Error())
+			} else {
+				zap.S().Debugf("Finished on host %s", host)
+				progress.done(host, color.Green("✓ ")+result)
+			}
+		}(i, host)
+	}
+	wg.Wait()
+	return results
+}
+
+// HostsFailed returns the number of hosts the operation failed on
+func HostsFailed(results []HostResult) int {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// PrintHostResults prints the summary table of a run on several hosts
+func PrintHostResults(w io.Writer, results []HostResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tSTATUS\tRESULT\tDURATION\tERROR")
+	for _, r := range results {
+		status, errStr := "ok", ""
+		if r.Err != nil {
+			status, errStr = "failed", r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Host, status, r.Result, r.Duration, errStr)
+	}
+	tw.Flush()
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// hostProgress keeps one status line per host. On a terminal the lines are
+// redrawn in place, otherwise every completed line is printed with the host name.
+type hostProgress struct {
+	mu     sync.Mutex
+	out    io.Writer
+	hosts  []string
+	status map[string]string
+	isTerm bool
+	drawn  int
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newHostProgress(out io.Writer, hosts []string, isTerm bool) *hostProgress {
+	p := &hostProgress{out: out, hosts: hosts, status: map[string]string{}, isTerm: isTerm, quit: make(chan struct{})}
+	for _, host := range hosts {
+		p.status[host] = "waiting"
+	}
+	return p
+}
+
+func (p *hostProgress) start() {
+	if !p.isTerm {
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.quit:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.render()
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+func (p *hostProgress) stop() {
+	close(p.quit)
+	p.wg.Wait()
+	if p.isTerm {
+		p.mu.Lock()
+		p.render()
+		p.mu.Unlock()
+	}
+}
+
+// render redraws the status lines, must be called with the lock held
+func (p *hostProgress) render() {
+	if p.drawn > 0 {
+		fmt.Fprintf(p.out, "\033[%dA", p.drawn)
+	}
+	for _, host := range p.hosts {
+		fmt.Fprintf(p.out, "\r\033[K%-15s %s\n", host, p.status[host])
+	}
+	p.drawn = len(p.hosts)
+}
+
+// update sets the status line of the host, complete lines are printed when not on a terminal
+func (p *hostProgress) update(host, line string, complete bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status[host] = line
+	if complete && !p.isTerm {
+		fmt.Fprintf(p.out, "[%s] %s\n", host, line)
+	}
+}
+
+func (p *hostProgress) done(host, status string) {
+	p.update(host, status, true)
+}
+
+func (p *hostProgress) writer(host string) io.Writer {
+	return &hostWriter{progress: p, host: host}
+}
+
+// hostWriter turns the output of a node operation, including spinner frames,
+// into status updates for its host.
+type hostWriter struct {
+	progress *hostProgress
+	host     string
+	line     []byte
+}
+
+func (w *hostWriter) Write(b []byte) (int, error) {
+	for _, c := range b {
+		switch c {
+		case '\n':
+			w.flush(true)
+		case '\r':
+			// Spinners redraw the line after a carriage return
+			w.flush(false)
+		default:
+			w.line = append(w.line, c)
+		}
+	}
+	return len(b), nil
+}
+
+func (w *hostWriter) flush(complete bool) {
+	line := ansiEscape.ReplaceAllString(string(w.line), "")
+	w.line = w.line[:0]
+	if complete {
+		zap.S().Debugf("[%s] %s", w.host, strings.TrimSpace(line))
+	}
+	w.progress.update(w.host, line, complete)
+}
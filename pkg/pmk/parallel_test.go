@@ -0,0 +1,74 @@
+package pmk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunOnHosts(t *testing.T) {
+	var running, maxRunning int32
+	hosts := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}
+
+	var progress bytes.Buffer
+	results := RunOnHosts(&progress, hosts, 2, func(host string, out io.Writer) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		fmt.Fprintf(out, "checking %s\n", host)
+		if host == "10.0.0.3" {
+			return "requiredFail", errors.New("Required pre-requisite check(s) failed")
+		}
+		return "pass", nil
+	})
+
+	assert.Equal(t, int32(2), maxRunning)
+	assert.Equal(t, len(hosts), len(results))
+	for i, r := range results {
+		assert.Equal(t, hosts[i], r.Host)
+	}
+	assert.Equal(t, "requiredFail", results[2].Result)
+	assert.NotNil(t, results[2].Err)
+	assert.Equal(t, 1, HostsFailed(results))
+	assert.Contains(t, progress.String(), "checking 10.0.0.5")
+}
+
+func TestPrintHostResults(t *testing.T) {
+	var buf bytes.Buffer
+	PrintHostResults(&buf, []HostResult{
+		{Host: "10.0.0.1", Result: "pass", Duration: 12 * time.Second},
+		{Host: "10.0.0.2", Result: "requiredFail", Err: errors.New("Required pre-requisite check(s) failed"), Duration: 3 * time.Second},
+	})
+	assert.Equal(t, "HOST       STATUS   RESULT         DURATION   ERROR\n"+
+		"10.0.0.1   ok       pass           12s        \n"+
+		"10.0.0.2   failed   requiredFail   3s         Required pre-requisite check(s) failed\n", buf.String())
+}
+
+func TestHostWriter(t *testing.T) {
+	var buf bytes.Buffer
+	progress := newHostProgress(&buf, []string{"10.0.0.1"}, false)
+	w := progress.writer("10.0.0.1")
+
+	// Spinner frames only update the status, complete lines are printed
+	fmt.Fprint(w, "\r\x1b[K\x1b[31m⠋\x1b[0m Running checks \r\x1b[K\x1b[31m⠙\x1b[0m Running checks ")
+	fmt.Fprint(w, "\r\x1b[K")
+	assert.Equal(t, "", buf.String())
+	assert.Equal(t, "⠙ Running checks", progress.status["10.0.0.1"])
+
+	fmt.Fprint(w, "✓ Sudo Access Check\n")
+	assert.Equal(t, "[10.0.0.1] ✓ Sudo Access Check\n", buf.String())
+	assert.Equal(t, "✓ Sudo Access Check", progress.status["10.0.0.1"])
+}
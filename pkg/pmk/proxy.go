@@ -0,0 +1,412 @@
+package pmk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/platform9/pf9ctl/pkg/objects"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"go.uber.org/zap"
+)
+
+// Files holding the proxy settings of pf9-hostagent and pf9-comms
+const (
+	HostAgentEnvFile = "/opt/pf9/hostagent/pf9-hostagent.env"
+	CommsProxyFile   = "/etc/pf9/comms_proxy_cfg.json"
+)
+
+// Units getting the proxy through a systemd drop-in, the container runtimes pull
+// the images and nodelet downloads the Kubernetes components
+var runtimeProxyUnits = []string{"containerd", "docker", "pf9-nodeletd"}
+
+// RuntimeProxyDropIn is the systemd drop-in giving the proxy to the unit
+func RuntimeProxyDropIn(unit string) string {
+	return fmt.Sprintf("/etc/systemd/system/%s.service.d/http-proxy.conf", unit)
+}
+
+// RuntimeProxyEnvFile holds the proxy variables of the unit. The proxy credentials
+// are kept in this root only file, systemd shows the Environment= settings of a
+// drop-in to every user.
+func RuntimeProxyEnvFile(unit string) string {
+	return fmt.Sprintf("/etc/systemd/system/%s.service.d/http-proxy.env", unit)
+}
+
+// NodeProxy is the proxy configured on a node
+type NodeProxy struct {
+	// HostAgent are the proxy variables of pf9-hostagent, by lower case name
+	HostAgent map[string]string
+	// Comms is the proxy URL of pf9-comms, empty when it has none
+	Comms        string
+	CommsNoProxy string
+	// Runtime are the units with a proxy drop-in
+	Runtime []string
+}
+
+// IsSet returns true when pf9-hostagent or pf9-comms use a proxy
+func (p NodeProxy) IsSet() bool {
+	return len(p.HostAgent) > 0 || p.Comms != "" || len(p.Runtime) > 0
+}
+
+// ProxyURL validates the proxy settings and returns the URL of the proxy
+func ProxyURL(proxy objects.HttpProxy) (string, error) {
+	var errs []string
+	if proxy.Protocol != "http" && proxy.Protocol != "https" {
+		errs = append(errs, fmt.Sprintf("protocol %q should be http or https", proxy.Protocol))
+	}
+	if proxy.Host == "" || strings.ContainsAny(proxy.Host, "/:@ ") && net.ParseIP(proxy.Host) == nil {
+		errs = append(errs, fmt.Sprintf("host %q should be an IP or a host name", proxy.Host))
+	}
+	if port, err := strconv.Atoi(proxy.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Sprintf("port %q should be a number between 1 and 65535", proxy.Port))
+	}
+	if (proxy.User == "") != (proxy.Pass == "") {
+		errs = append(errs, "both the proxy user and password should be passed")
+	}
+	if len(errs) > 0 {
+		return "", fmt.Errorf("Invalid proxy: %s", strings.Join(errs, ", "))
+	}
+
+	u := url.URL{Scheme: proxy.Protocol, Host: net.JoinHostPort(proxy.Host, proxy.Port)}
+	if proxy.User != "" {
+		u.User = url.UserPassword(proxy.User, proxy.Pass)
+	}
+	return u.String(), nil
+}
+
+// CheckProxyReachesDU makes sure the node can reach the DU through the proxy. The
+// proxy credentials are passed to curl in a root only config file, its command line
+// is seen by every user of the node and logged.
+func CheckProxyReachesDU(exec cmdexec.Executor, proxyURL, fqdn string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("Invalid proxy %s: %w", redactProxyURL(proxyURL), err)
+	}
+	args := []string{"-sS", "--head", "--fail", "--max-time", "30", "--write-out", `\n%{http_code} %{http_connect}`}
+	if u.User != nil {
+		if d, ok := exec.(cmdexec.DryRunExecutor); ok {
+			d.Record(fmt.Sprintf("check %s is reachable through the proxy %s", fqdn, u.Redacted()))
+			return nil
+		}
+		conf, err := writeCurlProxyUser(exec, u.User)
+		if err != nil {
+			return err
+		}
+		defer exec.RunWithStdout("rm", "-f", conf)
+		args = append(args, "--config", conf)
+		u.User = nil
+	}
+	args = append(args, "--proxy", u.String(), strings.TrimSuffix(fqdn, "/")+"/keystone/v3")
+
+	output, err := exec.RunWithStdout("curl", args...)
+	if proxyAuthRequired(output) {
+		return fmt.Errorf("The proxy %s rejected the credentials: 407 Proxy Authentication Required", u.String())
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to reach %s through the proxy: %w", fqdn, err)
+	}
+	return nil
+}
+
+// writeCurlProxyUser writes the proxy credentials to a root only curl config and
+// returns its path
+func writeCurlProxyUser(exec cmdexec.Executor, user *url.Userinfo) (string, error) {
+	output, err := exec.RunWithStdout("mktemp", "/tmp/pf9ctl-curl.XXXXXXXX")
+	conf := strings.TrimSpace(output)
+	if err != nil || !path.IsAbs(conf) {
+		return "", fmt.Errorf("Unable to create the curl config: %s", mktempError(output, err))
+	}
+	pass, _ := user.Password()
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace
+	data := fmt.Sprintf("proxy-user = \"%s\"\n", escape(user.Username()+":"+pass))
+	if err := cmdexec.WriteFile(exec, conf, []byte(data), 0600, "root:root"); err != nil {
+		exec.RunWithStdout("rm", "-f", conf)
+		return "", err
+	}
+	return conf, nil
+}
+
+// proxyAuthRequired reports if the proxy answered 407, to the request or to the
+// CONNECT of an https request, from the codes curl writes last
+func proxyAuthRequired(output string) bool {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for _, code := range strings.Fields(lines[len(lines)-1]) {
+		if code == "407" {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadNodeProxy reads the proxy settings of pf9-hostagent and pf9-comms on the node
+func ReadNodeProxy(exec cmdexec.Executor) (NodeProxy, error) {
+	proxy := NodeProxy{HostAgent: map[string]string{}}
+	env, err := readNodeFile(exec, HostAgentEnvFile)
+	if err != nil {
+		return proxy, err
+	}
+	proxy.HostAgent = parseHostAgentProxy(env)
+
+	comms, err := readNodeFile(exec, CommsProxyFile)
+	if err != nil {
+		return proxy, err
+	}
+	if strings.TrimSpace(comms) != "" {
+		if proxy.Comms, proxy.CommsNoProxy, err = parseCommsProxy([]byte(comms)); err != nil {
+			return proxy, fmt.Errorf("Invalid %s: %w", CommsProxyFile, err)
+		}
+	}
+
+	for _, unit := range runtimeProxyUnits {
+		if _, err := exec.RunWithStdout("test", "-e", RuntimeProxyDropIn(unit)); err == nil {
+			proxy.Runtime = append(proxy.Runtime, unit)
+		}
+	}
+	return proxy, nil
+}
+
+// readNodeFile returns the content of a file on the node, empty when it does not exist
+func readNodeFile(exec cmdexec.Executor, file string) (string, error) {
+	content, err := exec.RunWithStdout("cat", file)
+	if err == nil {
+		return content, nil
+	}
+	if _, testErr := exec.RunWithStdout("test", "-e", file); testErr != nil {
+		zap.S().Debugf("%s does not exist", file)
+		return "", nil
+	}
+	return "", fmt.Errorf("Unable to read %s: %w", file, err)
+}
+
+// parseHostAgentProxy returns the proxy variables of an env file, the last
+// definition of a variable wins
+func parseHostAgentProxy(env string) map[string]string {
+	vars := map[string]string{}
+	for _, line := range strings.Split(env, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "export "))
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || strings.HasPrefix(name, "#") {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "http_proxy" && name != "https_proxy" && name != "no_proxy" {
+			continue
+		}
+		vars[name] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return vars
+}
+
+// parseCommsProxy returns the proxy URL and the no_proxy list of a pf9-comms
+// proxy config
+func parseCommsProxy(data []byte) (string, string, error) {
+	var cfg objects.ProxySetting
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", "", err
+	}
+	if cfg.Proxy == (objects.HttpProxy{}) {
+		return "", "", nil
+	}
+	proxyURL, err := ProxyURL(cfg.Proxy)
+	if err != nil {
+		return "", "", err
+	}
+	return proxyURL, cfg.Proxy.NoProxy, nil
+}
+
+// CommsProxyConfig returns the content of the pf9-comms proxy config
+func CommsProxyConfig(proxy objects.HttpProxy) ([]byte, error) {
+	if _, err := ProxyURL(proxy); err != nil {
+		return nil, err
+	}
+	return json.Marshal(objects.ProxySetting{Proxy: proxy})
+}
+
+// SetCommsProxy writes the pf9-comms proxy config, readable by pf9-comms only as
+// it holds the proxy password
+func SetCommsProxy(exec cmdexec.Executor, proxy objects.HttpProxy) error {
+	data, err := CommsProxyConfig(proxy)
+	if err != nil {
+		return err
+	}
+	if err := cmdexec.WriteFile(exec, CommsProxyFile, data, 0640, "pf9:pf9group"); err != nil {
+		return err
+	}
+	zap.S().Infof("pf9-comms proxy setting added to %s", CommsProxyFile)
+	return nil
+}
+
+// redactProxyURL hides the password of a proxy URL
+func redactProxyURL(proxyURL string) string {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.User == nil {
+		return proxyURL
+	}
+	return u.Redacted()
+}
+
+// PrintNodeProxy prints the proxy settings of a node, passwords are hidden
+func PrintNodeProxy(w io.Writer, proxy NodeProxy) {
+	if !proxy.IsSet() {
+		fmt.Fprintln(w, "No proxy is set")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(tw, "pf9-hostagent\t%s\n", HostAgentEnvFile)
+	if len(proxy.HostAgent) == 0 {
+		fmt.Fprintln(tw, "  no proxy\t")
+	}
+	for _, name := range []string{"http_proxy", "https_proxy", "no_proxy"} {
+		if value, ok := proxy.HostAgent[name]; ok {
+			fmt.Fprintf(tw, "  %s\t%s\n", name, redactProxyURL(value))
+		}
+	}
+	fmt.Fprintf(tw, "pf9-comms\t%s\n", CommsProxyFile)
+	if proxy.Comms == "" {
+		fmt.Fprintln(tw, "  no proxy\t")
+	} else {
+		fmt.Fprintf(tw, "  proxy\t%s\n", redactProxyURL(proxy.Comms))
+		if proxy.CommsNoProxy != "" {
+			fmt.Fprintf(tw, "  no_proxy\t%s\n", proxy.CommsNoProxy)
+		}
+	}
+	for _, unit := range proxy.Runtime {
+		fmt.Fprintf(tw, "%s\t%s\n", unit, RuntimeProxyDropIn(unit))
+	}
+	tw.Flush()
+}
+
+// UnsetNodeProxy removes the proxy of pf9-hostagent and pf9-comms and restarts them
+func UnsetNodeProxy(exec cmdexec.Executor) error {
+	if _, err := exec.RunWithStdout("test", "-e", HostAgentEnvFile); err == nil {
+		if _, err := exec.RunWithStdout("sed", "-i", "-E", `/^(export )?(http|https|no)_proxy=/Id`, HostAgentEnvFile); err != nil {
+			return fmt.Errorf("Unable to remove the proxy from %s: %w", HostAgentEnvFile, err)
+		}
+	}
+	if _, err := exec.RunWithStdout("rm", "-f", CommsProxyFile); err != nil {
+		return fmt.Errorf("Unable to remove %s: %w", CommsProxyFile, err)
+	}
+	if err := UnsetRuntimeProxy(exec); err != nil {
+		return err
+	}
+	return RestartPf9Services(exec)
+}
+
+// RestartPf9Services restarts pf9-hostagent and pf9-comms so they read their proxy again
+func RestartPf9Services(exec cmdexec.Executor) error {
+	zap.S().Info("Restarting Platform9 services")
+	for _, service := range []string{"pf9-hostagent", "pf9-comms"} {
+		if _, err := exec.RunWithStdout("systemctl", "restart", service); err != nil {
+			return fmt.Errorf("Unable to restart %s: %w", service, err)
+		}
+		zap.S().Infof("%s is restarted", service)
+	}
+	return nil
+}
+
+// RuntimeNoProxy returns the no_proxy list of the container runtimes and nodelet:
+// noProxy with the CIDRs of the cluster, when the node has one, and the IPs of the
+// node, which pods and services must reach without the proxy.
+func RuntimeNoProxy(noProxy string, cluster *qbert.Cluster, nodeIPs []string) string {
+	entries := strings.Split(noProxy, ",")
+	if cluster != nil {
+		entries = append(entries, cluster.ContainerCIDR, cluster.ServiceCIDR)
+	}
+	entries = append(entries, nodeIPs...)
+
+	var list []string
+	seen := map[string]bool{}
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e != "" && !seen[e] {
+			seen[e] = true
+			list = append(list, e)
+		}
+	}
+	return strings.Join(list, ",")
+}
+
+// NodeIPs returns the IPs of the node
+func NodeIPs(exec cmdexec.Executor) ([]string, error) {
+	output, err := exec.RunWithStdout("hostname", "-I")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get the IPs of the node: %w", err)
+	}
+	return strings.Fields(output), nil
+}
+
+// runtimeProxyEnv returns the environment file with the proxy variables. Values are
+// double quoted, systemd unescapes the characters a shell would in that case.
+func runtimeProxyEnv(proxyURL, noProxy string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`).Replace
+	var env strings.Builder
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		fmt.Fprintf(&env, "%s=\"%s\"\n", name, escape(proxyURL))
+	}
+	for _, name := range []string{"NO_PROXY", "no_proxy"} {
+		fmt.Fprintf(&env, "%s=\"%s\"\n", name, escape(noProxy))
+	}
+	return env.String()
+}
+
+// SetRuntimeProxy writes the proxy drop-ins of containerd, docker and nodelet and
+// restarts the ones running
+func SetRuntimeProxy(exec cmdexec.Executor, proxyURL, noProxy string) error {
+	env := runtimeProxyEnv(proxyURL, noProxy)
+	for _, unit := range runtimeProxyUnits {
+		dropIn := RuntimeProxyDropIn(unit)
+		if _, err := exec.RunWithStdout("mkdir", "-p", path.Dir(dropIn)); err != nil {
+			return fmt.Errorf("Unable to create %s: %w", path.Dir(dropIn), err)
+		}
+		envFile := RuntimeProxyEnvFile(unit)
+		if err := cmdexec.WriteFile(exec, envFile, []byte(env), 0600, "root:root"); err != nil {
+			return err
+		}
+		conf := fmt.Sprintf("[Service]\nEnvironmentFile=%s\n", envFile)
+		if err := cmdexec.WriteFile(exec, dropIn, []byte(conf), 0644, "root:root"); err != nil {
+			return err
+		}
+		zap.S().Infof("%s proxy setting added to %s", unit, envFile)
+	}
+	return restartRuntimeUnits(exec)
+}
+
+// UnsetRuntimeProxy removes the proxy drop-ins and restarts the running units
+// when there were any
+func UnsetRuntimeProxy(exec cmdexec.Executor) error {
+	removed := false
+	for _, unit := range runtimeProxyUnits {
+		dropIn := RuntimeProxyDropIn(unit)
+		if _, err := exec.RunWithStdout("test", "-e", dropIn); err != nil {
+			continue
+		}
+		if _, err := exec.RunWithStdout("rm", "-f", dropIn, RuntimeProxyEnvFile(unit)); err != nil {
+			return fmt.Errorf("Unable to remove %s: %w", dropIn, err)
+		}
+		removed = true
+	}
+	if !removed {
+		return nil
+	}
+	return restartRuntimeUnits(exec)
+}
+
+// restartRuntimeUnits reloads systemd and restarts the units which are running
+func restartRuntimeUnits(exec cmdexec.Executor) error {
+	if _, err := exec.RunWithStdout("systemctl", "daemon-reload"); err != nil {
+		return fmt.Errorf("Unable to reload systemd: %w", err)
+	}
+	for _, unit := range runtimeProxyUnits {
+		if _, err := exec.RunWithStdout("systemctl", "try-restart", unit); err != nil {
+			return fmt.Errorf("Unable to restart %s: %w", unit, err)
+		}
+	}
+	return nil
+}
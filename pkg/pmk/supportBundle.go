@@ -0,0 +1,265 @@
+package pmk
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"go.uber.org/zap"
+)
+
+// Services whose journal is collected in the support bundle
+var bundleJournalUnits = []string{"pf9-hostagent", "pf9-comms", "pf9-nodeletd"}
+
+// Number of pf9ctl log files, one per day, added to the support bundle
+const bundleLogDays = 7
+
+// SupportBundle is a tarball of the logs and configuration needed to debug a node
+type SupportBundle struct {
+	Path string
+	name string
+	file *os.File
+	gz   *gzip.Writer
+	tw   *tar.Writer
+}
+
+// NewSupportBundle creates pf9ctl-support-bundle-<timestamp>.tar.gz in dir
+func NewSupportBundle(dir string, now time.Time) (*SupportBundle, error) {
+	name := "pf9ctl-support-bundle-" + now.Format("20060102-150405")
+	bundlePath := filepath.Join(dir, name+".tar.gz")
+	f, err := os.OpenFile(bundlePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create support bundle: %w", err)
+	}
+	gz := gzip.NewWriter(f)
+	return &SupportBundle{Path: bundlePath, name: name, file: f, gz: gz, tw: tar.NewWriter(gz)}, nil
+}
+
+// AddBytes adds a file with the given content to the bundle
+func (b *SupportBundle) AddBytes(name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    path.Join(b.name, name),
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := b.tw.Write(data)
+	return err
+}
+
+// AddFile adds a local file to the bundle
+func (b *SupportBundle) AddFile(name, src string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return b.AddBytes(name, data)
+}
+
+// AddLogs adds the most recent pf9ctl logs found next to logFile
+func (b *SupportBundle) AddLogs(logFile string) error {
+	ext := filepath.Ext(logFile)
+	logs, err := filepath.Glob(strings.TrimSuffix(logFile, ext) + "-*" + ext)
+	if err != nil {
+		return err
+	}
+	// The log file names contain the date, so the newest sort last
+	sort.Strings(logs)
+	if len(logs) > bundleLogDays {
+		logs = logs[len(logs)-bundleLogDays:]
+	}
+	for _, log := range logs {
+		if err := b.AddFile(path.Join("pf9ctl", filepath.Base(log)), log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTarball copies the entries of a gzipped tarball to the bundle under dir
+func (b *SupportBundle) addTarball(dir string, r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Symlinks could point anywhere on the host, keep only plain files and dirs
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeDir {
+			continue
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		if name == "." || strings.HasPrefix(name, "../") {
+			continue
+		}
+		hdr.Name = path.Join(b.name, dir, name)
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		hdr.Uname, hdr.Gname = "", ""
+		if err = b.tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err = io.Copy(b.tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// AddHost collects the logs, redacted configuration and probe outputs of a host,
+// owner is the user reading the tarball built on the host, the ssh user or the
+// local uid. Nothing is changed on the host outside of the staging directory.
+// Collection is best effort, the steps which failed are listed in <host>/errors.txt.
+func (b *SupportBundle) AddHost(host, owner string, exec cmdexec.Executor) error {
+	zap.S().Debugf("Collecting support bundle from %s", host)
+	var stepErrs []string
+	// A fresh directory, a fixed path in /tmp could be a symlink planted by another user
+	out, err := exec.RunWithStdout("mktemp", "-d", "/tmp/pf9ctl-support.XXXXXXXX")
+	if dir := strings.TrimSpace(out); err != nil || !path.IsAbs(dir) {
+		stepErrs = append(stepErrs, fmt.Sprintf("Create staging directory: %s", mktempError(out, err)))
+	} else {
+		for _, step := range supportBundleSteps(dir, owner) {
+			if _, err := exec.RunWithStdout("bash", "-c", step.cmd); err != nil {
+				zap.S().Debugf("Support bundle step %q failed on %s: %s", step.name, host, err)
+				stepErrs = append(stepErrs, fmt.Sprintf("%s: %s", step.name, err))
+			}
+		}
+		if err := b.fetchHostTarball(host, path.Join(dir, bundleTarball), exec); err != nil {
+			stepErrs = append(stepErrs, fmt.Sprintf("Fetch collected files: %s", err))
+		}
+		exec.RunWithStdout("rm", "-rf", dir)
+	}
+
+	if len(stepErrs) > 0 {
+		return b.AddBytes(path.Join(host, "errors.txt"), []byte(strings.Join(stepErrs, "\n")+"\n"))
+	}
+	return nil
+}
+
+// fetchHostTarball reads the tarball built on the host, over sftp for remote hosts
+func (b *SupportBundle) fetchHostTarball(host, tarball string, exec cmdexec.Executor) error {
+	src := tarball
+	if remote, ok := exec.(*cmdexec.RemoteExecutor); ok {
+		tmp, err := os.CreateTemp("", "pf9ctl-support-")
+		if err != nil {
+			return err
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		if err = remote.Client.DownloadFile(tarball, tmp.Name(), 0600, nil); err != nil {
+			return err
+		}
+		src = tmp.Name()
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return b.addTarball(host, f)
+}
+
+// Close finishes writing the bundle
+func (b *SupportBundle) Close() error {
+	if err := b.tw.Close(); err != nil {
+		b.file.Close()
+		return err
+	}
+	if err := b.gz.Close(); err != nil {
+		b.file.Close()
+		return err
+	}
+	return b.file.Close()
+}
+
+type supportBundleStep struct {
+	name string
+	cmd  string
+}
+
+// Values of keys which look like credentials are replaced in the copied configuration
+const redactSecrets = `sed -E -i 's/((pass(word|wd)?|secret|token|api_?key|credentials?)[^=:]*[=:][[:space:]]*).*/\1<redacted>/I'`
+
+// Read-only commands whose output is collected in the probes directory, the
+// checks of check-node are not run as they install packages and disable swap
+var bundleProbes = []struct{ name, cmd string }{
+	{"kernel", "uname -a"},
+	{"cpu", "lscpu"},
+	{"memory", "free -m"},
+	{"swap", "swapon --show"},
+	{"disk", "df -h"},
+	{"ports", "ss -tlnp"},
+	{"services", "systemctl status --no-pager " + strings.Join(bundleJournalUnits, " ")},
+}
+
+// The files of a host are staged in the files directory of the directory created
+// by mktemp and packed next to it
+const (
+	bundleFiles   = "files"
+	bundleTarball = "bundle.tar.gz"
+)
+
+// supportBundleSteps returns the commands collecting the files in the staging
+// directory dir and packing them for owner, dir is removed by the caller
+func supportBundleSteps(dir, owner string) []supportBundleStep {
+	staging := path.Join(dir, bundleFiles)
+	tarball := path.Join(dir, bundleTarball)
+	steps := []supportBundleStep{
+		{"Create staging directory", fmt.Sprintf("mkdir -p %[1]s/journal %[1]s/etc %[1]s/probes", staging)},
+		{"Collect /var/log/pf9", fmt.Sprintf("cp -r /var/log/pf9 %s/var-log-pf9", staging)},
+	}
+	for _, unit := range bundleJournalUnits {
+		steps = append(steps, supportBundleStep{"Collect " + unit + " journal",
+			fmt.Sprintf("journalctl -u %s --no-pager --since '-7d' > %s/journal/%s.log", unit, staging, unit)})
+	}
+	steps = append(steps,
+		supportBundleStep{"Collect /etc/pf9", fmt.Sprintf("cp -r /etc/pf9 %s/etc/pf9", staging)},
+		supportBundleStep{"Redact /etc/pf9", fmt.Sprintf("find %[1]s/etc -name '*.key' -delete; "+
+			"grep -rlI 'PRIVATE KEY' %[1]s/etc | xargs -r rm -f; "+
+			"find %[1]s/etc -type f -print0 | xargs -0 -r %[2]s", staging, redactSecrets)},
+		supportBundleStep{"Collect OS release", fmt.Sprintf("cp /etc/os-release %s/os-release", staging)},
+		supportBundleStep{"Collect package list", fmt.Sprintf("(dpkg -l || rpm -qa) > %s/packages.txt 2>/dev/null", staging)},
+	)
+	for _, probe := range bundleProbes {
+		// The errors of a probe are part of its output
+		steps = append(steps, supportBundleStep{"Collect " + probe.name,
+			fmt.Sprintf("%s > %s/probes/%s.txt 2>&1 || true", probe.cmd, staging, probe.name)})
+	}
+	// The tarball holds logs and configuration, only the owner downloading it can read it
+	steps = append(steps, supportBundleStep{"Pack collected files",
+		fmt.Sprintf("tar czf %[2]s -C %[1]s . && rm -rf %[1]s && chmod 600 %[2]s && chown %[4]s %[2]s && chmod 711 %[3]s",
+			staging, tarball, dir, shellQuote(owner))})
+	return steps
+}
+
+// shellQuote quotes s as a single word for the shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// mktempError returns why mktemp did not create the staging directory
+func mktempError(output string, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("unexpected mktemp output %q", output)
+}
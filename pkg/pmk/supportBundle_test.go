@@ -0,0 +1,171 @@
+package pmk
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/cmdexec"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestTarball creates the tarball which the pack step builds on a host
+func writeTestTarball(t *testing.T, loc string) {
+	f, err := os.Create(loc)
+	assert.Nil(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	files := map[string]string{"./os-release": "NAME=Ubuntu\n", "./etc/pf9/hostagent.conf": "password = <redacted>\n"}
+	for name, content := range files {
+		assert.Nil(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err = tw.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, tw.WriteHeader(&tar.Header{Name: "./etc/pf9/link", Linkname: "/etc/shadow", Typeflag: tar.TypeSymlink}))
+	assert.Nil(t, tw.WriteHeader(&tar.Header{Name: "../escape", Mode: 0644, Typeflag: tar.TypeReg}))
+	assert.Nil(t, tw.Close())
+	assert.Nil(t, gz.Close())
+}
+
+func readBundle(t *testing.T, loc string) map[string]string {
+	f, err := os.Open(loc)
+	assert.Nil(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	assert.Nil(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		data, err := io.ReadAll(tr)
+		assert.Nil(t, err)
+		files[hdr.Name] = string(data)
+	}
+	return files
+}
+
+func TestSupportBundle(t *testing.T) {
+	dir := t.TempDir()
+	bundle, err := NewSupportBundle(dir, time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	prefix := "pf9ctl-support-bundle-20240501-103000/"
+
+	staging := t.TempDir()
+	var commands, removed []string
+	exec := &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			switch name {
+			case "mktemp":
+				return staging + "\n", nil
+			case "rm":
+				removed = append(removed, strings.Join(args, " "))
+				os.RemoveAll(args[len(args)-1])
+				return "", nil
+			case "bash":
+			default:
+				return "", errors.New("exit status 1")
+			}
+			cmd := args[1]
+			commands = append(commands, cmd)
+			switch {
+			case strings.HasPrefix(cmd, "journalctl -u pf9-comms"):
+				return "", errors.New("exit status 1")
+			case strings.HasPrefix(cmd, "tar czf"):
+				writeTestTarball(t, strings.Fields(cmd)[2])
+			}
+			return "", nil
+		},
+	}
+
+	assert.Nil(t, bundle.AddHost("10.0.0.1", "ubuntu", exec))
+	assert.Nil(t, bundle.AddBytes("notes.txt", []byte("hello")))
+	assert.Nil(t, bundle.Close())
+
+	for _, cmd := range commands {
+		assert.NotContains(t, cmd, "$")
+		assert.NotContains(t, cmd, "\"")
+		assert.NotContains(t, cmd, "`")
+		assert.NotContains(t, cmd, "/tmp/pf9ctl-support-bundle")
+	}
+	assert.Contains(t, commands[len(commands)-1], "tar czf "+staging+"/bundle.tar.gz -C "+staging+"/files .")
+	assert.Contains(t, commands[len(commands)-1], "chmod 600 "+staging+"/bundle.tar.gz && chown 'ubuntu' "+staging+"/bundle.tar.gz")
+	assert.Equal(t, []string{"-rf " + staging}, removed)
+	_, err = os.Stat(staging)
+	assert.True(t, os.IsNotExist(err))
+
+	files := readBundle(t, bundle.Path)
+	assert.Equal(t, "NAME=Ubuntu\n", files[prefix+"10.0.0.1/os-release"])
+	assert.Equal(t, "password = <redacted>\n", files[prefix+"10.0.0.1/etc/pf9/hostagent.conf"])
+	assert.Contains(t, files[prefix+"10.0.0.1/errors.txt"], "Collect pf9-comms journal: exit status 1")
+	assert.Equal(t, "hello", files[prefix+"notes.txt"])
+	assert.NotContains(t, files, prefix+"10.0.0.1/etc/pf9/link")
+	assert.Equal(t, 4, len(files))
+}
+
+func TestSupportBundleWithoutStagingDir(t *testing.T) {
+	bundle, err := NewSupportBundle(t.TempDir(), time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC))
+	assert.Nil(t, err)
+	prefix := "pf9ctl-support-bundle-20240501-103000/"
+
+	var ran []string
+	exec := &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			ran = append(ran, name)
+			if name == "mktemp" {
+				return "mktemp: failed to create directory via template\n", errors.New("exit status 1")
+			}
+			return "", errors.New("exit status 1")
+		},
+	}
+
+	assert.Nil(t, bundle.AddHost("10.0.0.1", "ubuntu", exec))
+	assert.Nil(t, bundle.Close())
+	assert.NotContains(t, ran, "bash")
+	assert.NotContains(t, ran, "rm")
+	files := readBundle(t, bundle.Path)
+	assert.Contains(t, files[prefix+"10.0.0.1/errors.txt"], "Create staging directory: exit status 1")
+}
+
+func TestSupportBundleOnlyChangesStagingDir(t *testing.T) {
+	bundle, err := NewSupportBundle(t.TempDir(), time.Now())
+	assert.Nil(t, err)
+	defer bundle.Close()
+
+	staging := "/tmp/pf9ctl-support.AbCd1234"
+	var received [][]string
+	exec := &cmdexec.MockExecutor{
+		MockRunWithStdout: func(name string, args ...string) (string, error) {
+			received = append(received, append([]string{name}, args...))
+			if name == "mktemp" {
+				return staging + "\n", nil
+			}
+			return "", nil
+		},
+	}
+	bundle.AddHost("10.0.0.1", "ubuntu", exec)
+
+	assert.Equal(t, []string{"mktemp", "-d", "/tmp/pf9ctl-support.XXXXXXXX"}, received[0])
+	for _, words := range received[1:] {
+		cmd := strings.Join(words, " ")
+		// The commands which are not read-only work in the staging directory
+		if !cmdexec.IsReadOnly(words[0], words[1:]...) {
+			assert.Contains(t, cmd, staging)
+		}
+		for _, change := range []string{"apt", "yum", "swapoff", "fstab", "systemctl start", "systemctl enable", "/opt/pf9"} {
+			assert.NotContains(t, cmd, change)
+		}
+	}
+	for _, probe := range bundleProbes {
+		assert.True(t, cmdexec.IsReadOnly("bash", "-c", probe.cmd), probe.cmd)
+	}
+}
@@ -0,0 +1,220 @@
+package pmk
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/color"
+	"github.com/platform9/pf9ctl/pkg/keystone"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"go.uber.org/zap"
+)
+
+// roleVersion is a pf9-kube role version, like 1.29.2-pmk.1
+type roleVersion struct {
+	major, minor, patch, build int
+}
+
+var roleVersionRe = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-pmk\.(\d+))?$`)
+
+func parseRoleVersion(v string) (roleVersion, error) {
+	m := roleVersionRe.FindStringSubmatch(strings.TrimSpace(v))
+	if m == nil {
+		return roleVersion{}, fmt.Errorf("Invalid version %q, expected a version like 1.29.2-pmk.1", v)
+	}
+	var parts [4]int
+	for i, s := range m[1:] {
+		if s != "" {
+			parts[i], _ = strconv.Atoi(s)
+		}
+	}
+	return roleVersion{parts[0], parts[1], parts[2], parts[3]}, nil
+}
+
+// compare returns -1, 0 or 1 when v is older, the same or newer than o
+func (v roleVersion) compare(o roleVersion) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch, v.build - o.build} {
+		if d < 0 {
+			return -1
+		} else if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// CheckUpgradePath returns an error when a cluster can't go from the current to
+// the target version. Kubernetes only supports upgrading one minor version at a time.
+func CheckUpgradePath(current, target string, available []string) error {
+	if !containsString(available, target) {
+		return fmt.Errorf("Version %s is not available, the available versions are %s", target, strings.Join(available, ", "))
+	}
+	from, err := parseRoleVersion(current)
+	if err != nil {
+		return fmt.Errorf("Unable to read the version of the cluster: %w", err)
+	}
+	to, err := parseRoleVersion(target)
+	if err != nil {
+		return err
+	}
+	switch {
+	case to.compare(from) == 0:
+		return fmt.Errorf("Cluster is already at %s", current)
+	case to.compare(from) < 0:
+		return fmt.Errorf("Downgrading from %s to %s is not supported", current, target)
+	case to.major != from.major:
+		return fmt.Errorf("Upgrading from %s to %s changes the major version, which is not supported", current, target)
+	case to.minor > from.minor+1:
+		return fmt.Errorf("Upgrading from %s to %s skips a minor version, upgrade to a %d.%d version first", current, target, from.major, from.minor+1)
+	}
+	return nil
+}
+
+// UpgradeTargets returns the available versions the cluster can be upgraded to,
+// oldest first
+func UpgradeTargets(current string, available []string) []string {
+	var targets []string
+	for _, v := range available {
+		if CheckUpgradePath(current, v, available) == nil {
+			targets = append(targets, v)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		a, _ := parseRoleVersion(targets[i])
+		b, _ := parseRoleVersion(targets[j])
+		return a.compare(b) < 0
+	})
+	return targets
+}
+
+// UpgradePlan is what an upgrade of a cluster would change
+type UpgradePlan struct {
+	Cluster qbert.Cluster
+	// Targets are the versions the cluster can be upgraded to
+	Targets []string
+	// Target is the version the cluster is upgraded to, empty when the cluster is up to date
+	Target string
+	Nodes  []NodeInfo
+}
+
+// PlanUpgrade checks that the cluster can be upgraded to the version to, the
+// newest version allowed is picked when to is empty.
+func PlanUpgrade(c client.Client, keystoneAuth keystone.KeystoneAuth, cluster qbert.Cluster, to string) (UpgradePlan, error) {
+	plan := UpgradePlan{Cluster: cluster}
+	pmkVersions, err := c.Qbert.GetPMKVersions(keystoneAuth.Token, keystoneAuth.ProjectID)
+	if err != nil {
+		return plan, fmt.Errorf("Unable to get the available versions: %w", err)
+	}
+	var available []string
+	for _, v := range pmkVersions.Roles {
+		available = append(available, v.RoleVersion)
+	}
+	plan.Targets = UpgradeTargets(cluster.KubeRoleVersion, available)
+
+	if to == "" {
+		if len(plan.Targets) == 0 {
+			return plan, nil
+		}
+		to = plan.Targets[len(plan.Targets)-1]
+	}
+	if err := CheckUpgradePath(cluster.KubeRoleVersion, to, available); err != nil {
+		return plan, err
+	}
+	plan.Target = to
+
+	if plan.Nodes, err = ListNodes(c, keystoneAuth, NodeFilter{Cluster: cluster.Uuid}); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// PrintUpgradePlan prints the versions of the cluster and the nodes which are upgraded
+func PrintUpgradePlan(w io.Writer, plan UpgradePlan) {
+	fmt.Fprintf(w, "Cluster %s is at version %s\n", plan.Cluster.Name, plan.Cluster.KubeRoleVersion)
+	if len(plan.Targets) == 0 {
+		fmt.Fprintln(w, "No upgrade is available")
+	} else {
+		fmt.Fprintf(w, "Available upgrades: %s\n", strings.Join(plan.Targets, ", "))
+	}
+	if plan.Target == "" {
+		return
+	}
+
+	fmt.Fprintf(w, "\nUpgrading to %s changes %d node(s):\n", plan.Target, len(plan.Nodes))
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "HOSTNAME\tPRIMARY IP\tCLUSTER ROLE\tVERSION")
+	for _, n := range plan.Nodes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s -> %s\n", n.Hostname, n.PrimaryIP, n.ClusterRole, n.Roles[kubeRole], plan.Target)
+	}
+	tw.Flush()
+}
+
+// UpgradeCluster starts the upgrade of the plan and waits for the nodes to converge
+func UpgradeCluster(c client.Client, keystoneAuth keystone.KeystoneAuth, plan UpgradePlan, timeout time.Duration) error {
+	if err := c.Qbert.UpgradeCluster(plan.Cluster.Uuid, keystoneAuth.ProjectID, keystoneAuth.Token, plan.Target); err != nil {
+		return fmt.Errorf("Unable to upgrade cluster %s: %w", plan.Cluster.Name, err)
+	}
+	fmt.Fprintf(c.Output(), "Upgrading cluster %s to %s\n", plan.Cluster.Name, plan.Target)
+	return WaitForUpgrade(c, keystoneAuth, plan.Cluster, plan.Target, timeout)
+}
+
+// WaitForUpgrade polls the cluster and its nodes with an exponential backoff until
+// every node runs the version and the cluster is ok, the cluster fails or the
+// timeout runs out. The number of upgraded nodes is printed as it grows.
+func WaitForUpgrade(c client.Client, keystoneAuth keystone.KeystoneAuth, cluster qbert.Cluster, version string, timeout time.Duration) error {
+	out := c.Output()
+	deadline := time.Now().Add(timeout)
+	interval := clusterPollInterval
+	upgraded, total := -1, 0
+	phase := ""
+	for {
+		status, err := c.Qbert.GetClusterStatus(cluster.Uuid, keystoneAuth.ProjectID, keystoneAuth.Token)
+		var nodes []NodeInfo
+		if err == nil {
+			nodes, err = ListNodes(c, keystoneAuth, NodeFilter{Cluster: cluster.Uuid})
+		}
+		if err != nil {
+			// Polled again, qbert may be briefly unavailable during the upgrade
+			zap.S().Debugf("Unable to get the status of cluster %s: %s", cluster.Name, err.Error())
+		} else {
+			phase = status.Phase()
+			if phase == qbert.ClusterError {
+				if status.TaskError != "" {
+					return fmt.Errorf("Upgrade of cluster %s failed: %s", cluster.Name, status.TaskError)
+				}
+				return fmt.Errorf("Upgrade of cluster %s failed", cluster.Name)
+			}
+			count := 0
+			for _, n := range nodes {
+				if n.Roles[kubeRole] == version {
+					count++
+				}
+			}
+			if count != upgraded || len(nodes) != total {
+				upgraded, total = count, len(nodes)
+				fmt.Fprintf(out, "  %d/%d node(s) upgraded to %s\n", upgraded, total, version)
+			}
+			if phase == qbert.ClusterOk && upgraded == total {
+				fmt.Fprintln(out, color.Green("✓")+" Cluster "+cluster.Name+" is upgraded to "+version)
+				return nil
+			}
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if upgraded < 0 {
+				return fmt.Errorf("Timed out after %s waiting for cluster %s to upgrade: %w", timeout, cluster.Name, err)
+			}
+			return fmt.Errorf("Timed out after %s waiting for cluster %s to upgrade, %d/%d node(s) upgraded and the cluster is %s", timeout, cluster.Name, upgraded, total, phase)
+		}
+		time.Sleep(min(interval, remaining))
+		interval = min(2*interval, clusterPollMaxInterval)
+	}
+}
@@ -0,0 +1,104 @@
+package pmk
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/platform9/pf9ctl/pkg/client"
+	"github.com/platform9/pf9ctl/pkg/fakedu"
+	"github.com/platform9/pf9ctl/pkg/qbert"
+	"github.com/stretchr/testify/assert"
+)
+
+var availableVersions = []string{"1.28.5-pmk.3", "1.29.2-pmk.1", "1.29.4-pmk.2", "1.30.1-pmk.1", "1.31.0-pmk.1"}
+
+func TestCheckUpgradePath(t *testing.T) {
+	cases := map[string]struct {
+		current string
+		target  string
+		err     string
+	}{
+		"patch":           {current: "1.29.2-pmk.1", target: "1.29.4-pmk.2"},
+		"minor":           {current: "1.29.2-pmk.1", target: "1.30.1-pmk.1"},
+		"skips a minor":   {current: "1.29.2-pmk.1", target: "1.31.0-pmk.1", err: "skips a minor version, upgrade to a 1.30 version first"},
+		"same version":    {current: "1.29.2-pmk.1", target: "1.29.2-pmk.1", err: "already at 1.29.2-pmk.1"},
+		"downgrade":       {current: "1.29.4-pmk.2", target: "1.29.2-pmk.1", err: "Downgrading"},
+		"not available":   {current: "1.29.2-pmk.1", target: "1.29.3-pmk.1", err: "Version 1.29.3-pmk.1 is not available"},
+		"invalid current": {current: "latest", target: "1.29.2-pmk.1", err: "Unable to read the version of the cluster"},
+		"newer build":     {current: "1.29.4-pmk.1", target: "1.29.4-pmk.2"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := CheckUpgradePath(tc.current, tc.target, availableVersions)
+			if tc.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestUpgradeTargets(t *testing.T) {
+	assert.Equal(t, []string{"1.29.2-pmk.1", "1.29.4-pmk.2"}, UpgradeTargets("1.28.5-pmk.3", []string{"1.29.4-pmk.2", "1.29.2-pmk.1", "1.28.5-pmk.3", "1.30.1-pmk.1"}))
+	assert.Nil(t, UpgradeTargets("1.31.0-pmk.1", availableVersions))
+}
+
+func TestUpgradeCluster(t *testing.T) {
+	du := fakedu.New()
+	defer du.Close()
+	du.RoleVersions = []string{"1.28.5-pmk.3", "1.29.2-pmk.1", "1.30.1-pmk.1"}
+	interval, maxInterval := clusterPollInterval, clusterPollMaxInterval
+	t.Cleanup(func() { clusterPollInterval, clusterPollMaxInterval = interval, maxInterval })
+	clusterPollInterval, clusterPollMaxInterval = time.Millisecond, 4*time.Millisecond
+
+	c, err := client.NewClient(du.URL, nil, true, true)
+	assert.Nil(t, err)
+	out := &bytes.Buffer{}
+	c.Out = out
+	auth, err := c.Keystone.GetAuth(fakedu.DefaultUsername, fakedu.DefaultPassword, fakedu.DefaultTenant, "")
+	assert.Nil(t, err)
+
+	du.AddHost("host-1", "master", "10.0.0.1")
+	du.AddHost("host-2", "worker", "10.0.0.2")
+	assert.Nil(t, c.Resmgr.AuthorizeHost("host-1", auth.Token, "", auth.ProjectID))
+	assert.Nil(t, c.Resmgr.AuthorizeHost("host-2", auth.Token, "", auth.ProjectID))
+	clusterID, err := c.Qbert.CreateCluster(qbert.ClusterCreateRequest{Name: "prod", PmkVersion: "1.28.5-pmk.3"}, auth.ProjectID, auth.Token)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Qbert.AttachNode(clusterID, auth.ProjectID, auth.Token, []string{"host-1"}, "master"))
+	assert.Nil(t, c.Qbert.AttachNode(clusterID, auth.ProjectID, auth.Token, []string{"host-2"}, "worker"))
+	cluster := du.Clusters()[0]
+
+	_, err = PlanUpgrade(c, auth, cluster, "1.30.1-pmk.1")
+	assert.ErrorContains(t, err, "skips a minor version")
+
+	plan, err := PlanUpgrade(c, auth, cluster, "")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.29.2-pmk.1"}, plan.Targets)
+	assert.Equal(t, "1.29.2-pmk.1", plan.Target)
+	assert.Equal(t, 2, len(plan.Nodes))
+
+	PrintUpgradePlan(out, plan)
+	assert.Contains(t, out.String(), "Cluster prod is at version 1.28.5-pmk.3")
+	assert.Contains(t, out.String(), "1.28.5-pmk.3 -> 1.29.2-pmk.1")
+
+	out.Reset()
+	assert.Nil(t, UpgradeCluster(c, auth, plan, time.Second))
+	assert.Contains(t, out.String(), "2/2 node(s) upgraded to 1.29.2-pmk.1")
+	assert.Equal(t, "1.29.2-pmk.1", du.Clusters()[0].KubeRoleVersion)
+
+	// A cluster stuck converging times out
+	du.SetClusterStatus(clusterID, "ok", "converging", "")
+	assert.ErrorContains(t, WaitForUpgrade(c, auth, cluster, "1.29.2-pmk.1", 10*time.Millisecond), "2/2 node(s) upgraded and the cluster is converging")
+	du.SetClusterStatus(clusterID, "ok", "error", "etcd failed")
+	assert.ErrorContains(t, WaitForUpgrade(c, auth, cluster, "1.29.2-pmk.1", time.Second), "Upgrade of cluster prod failed: etcd failed")
+
+	plan, err = PlanUpgrade(c, auth, du.Clusters()[0], "")
+	assert.Nil(t, err)
+	assert.Equal(t, "1.30.1-pmk.1", plan.Target)
+	du.RoleVersions = []string{"1.29.2-pmk.1"}
+	plan, err = PlanUpgrade(c, auth, du.Clusters()[0], "")
+	assert.Nil(t, err)
+	assert.Equal(t, "", plan.Target)
+}
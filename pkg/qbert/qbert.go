@@ -44,8 +44,13 @@ type Qbert interface {
 	CheckClusterExists(Name, projectID, token string) (bool, string, string, error)
 	CheckClusterExistsWithUuid(uuid, projectID, token string) (string, error)
 	GetNodeInfo(token, projectID, hostUUID string) (Node, error)
-	GetAllNodes(token, projectID string) []Node
-	GetPMKVersions(token, projectID string) PMKVersions
+	GetAllNodes(token, projectID string) ([]Node, error)
+	GetPMKVersions(token, projectID string) (PMKVersions, error)
+	ListClusters(projectID, token string) ([]Cluster, error)
+	GetKubeconfig(clusterID, projectID, token string) ([]byte, error)
+	GetClusterStatus(clusterID, projectID, token string) (ClusterStatus, error)
+	UpgradeCluster(clusterID, projectID, token, version string) error
+	UpdateEtcdBackup(clusterID, projectID, token string, backup EtcdBackup) error
 }
 
 func NewQbert(fqdn string) Qbert {
@@ -78,42 +83,65 @@ type Node struct {
 	PrimaryIp   string `json:"primaryIp"`
 	IsMaster    int    `json:"isMaster"`
 	ClusterName string `json:"clusterName"`
+	// Version of the pf9-kube role installed on the node
+	ActualKubeRoleVersion string `json:"actualKubeRoleVersion"`
+}
+
+// Cluster is a cluster as returned by the qbert clusters API
+type Cluster struct {
+	Uuid            string     `json:"uuid"`
+	Name            string     `json:"name"`
+	Status          string     `json:"status"`
+	TaskStatus      string     `json:"taskStatus"`
+	TaskError       string     `json:"taskError,omitempty"`
+	KubeRoleVersion string     `json:"kubeRoleVersion"`
+	NetworkPlugin   CNIBackend `json:"networkPlugin"`
+	ContainerCIDR   string     `json:"containersCidr"`
+	ServiceCIDR     string     `json:"servicesCidr"`
+	MasterVirtualIP string     `json:"masterVipIpv4"`
+	ExternalDNSName string     `json:"externalDnsName"`
+	NumMasters      int        `json:"numMasters"`
+	NumWorkers      int        `json:"numWorkers"`
+	NodePoolUuid    string     `json:"nodePoolUuid"`
+	ProjectId       string     `json:"projectId"`
+	EtcdBackup      EtcdBackup `json:"etcdBackup"`
 }
 
 type ClusterCreateRequest struct {
-	Name                   string     `json:"name"`
-	ContainerCIDR          string     `json:"containersCidr"`
-	ServiceCIDR            string     `json:"servicesCidr"`
-	MasterVirtualIP        string     `json:"masterVipIpv4"`
-	MasterVirtualIPIface   string     `json:"masterVipIface"`
-	AllowWorkloadOnMaster  bool       `json:"allowWorkloadsOnMaster"`
-	Privileged             bool       `json:"privileged"`
-	ExternalDNSName        string     `json:"externalDnsName"`
-	NetworkPlugin          CNIBackend `json:"networkPlugin"`
-	MetalLBAddressPool     string     `json:"metallbCidr"`
-	NodePoolUUID           string     `json:"nodePoolUuid"`
-	EnableMetalLb          bool       `json:"enableMetallb"`
-	Masterless             bool       `json:"masterless"`
-	EtcdBackup             EtcdBackup `json:"etcdBackup"`
-	NetworkPluginOperator  bool       `json:"deployLuigiOperator"`
-	EnableKubVirt          bool       `json:"deployKubevirt"`
-	EnableProfileAgent     bool       `json:"enableProfileAgent"`
-	PmkVersion             string     `json:"kubeRoleVersion"`
-	IPEncapsulation        string     `json:"calicoIpIpMode"`
-	InterfaceDetection     string     `json:"calicoIPv4DetectionMethod"`
-	UseHostName            bool       `json:"useHostname"`
-	MtuSize                string     `json:"mtuSize"`
-	BlockSize              string     `json:"calicoV4BlockSize"`
-	ContainerRuntime       string     `json:"containerRuntime"`
-	NetworkStack           int        `json:"ipv6"`
-	TopologyManagerPolicy  string     `json:"topologyManagerPolicy"`
-	ReservedCPUs           string     `json:"reservedCPUs"`
-	ApiServerFlags         []string   `json:"apiServerFlags"`
-	ControllerManagerFlags []string   `json:"controllerManagerFlags"`
-	SchedulerFlags         []string   `json:"schedulerFlags"`
-	RuntimeConfig          string     `json:"runtimeConfig"`
-	CalicoNatOutgoing      int        `json:"calicoNatOutgoing"`
-	HttpProxy              string     `json:"httpProxy"`
+	Name                   string            `json:"name"`
+	ContainerCIDR          string            `json:"containersCidr"`
+	ServiceCIDR            string            `json:"servicesCidr"`
+	MasterVirtualIP        string            `json:"masterVipIpv4"`
+	MasterVirtualIPIface   string            `json:"masterVipIface"`
+	AllowWorkloadOnMaster  bool              `json:"allowWorkloadsOnMaster"`
+	Privileged             bool              `json:"privileged"`
+	ExternalDNSName        string            `json:"externalDnsName"`
+	NetworkPlugin          CNIBackend        `json:"networkPlugin"`
+	MetalLBAddressPool     string            `json:"metallbCidr"`
+	NodePoolUUID           string            `json:"nodePoolUuid"`
+	EnableMetalLb          bool              `json:"enableMetallb"`
+	Masterless             bool              `json:"masterless"`
+	EtcdBackup             EtcdBackup        `json:"etcdBackup"`
+	NetworkPluginOperator  bool              `json:"deployLuigiOperator"`
+	EnableKubVirt          bool              `json:"deployKubevirt"`
+	EnableProfileAgent     bool              `json:"enableProfileAgent"`
+	PmkVersion             string            `json:"kubeRoleVersion"`
+	IPEncapsulation        string            `json:"calicoIpIpMode"`
+	InterfaceDetection     string            `json:"calicoIPv4DetectionMethod"`
+	UseHostName            bool              `json:"useHostname"`
+	MtuSize                string            `json:"mtuSize"`
+	BlockSize              string            `json:"calicoV4BlockSize"`
+	ContainerRuntime       string            `json:"containerRuntime"`
+	NetworkStack           int               `json:"ipv6"`
+	TopologyManagerPolicy  string            `json:"topologyManagerPolicy"`
+	ReservedCPUs           string            `json:"reservedCPUs"`
+	ApiServerFlags         []string          `json:"apiServerFlags"`
+	ControllerManagerFlags []string          `json:"controllerManagerFlags"`
+	SchedulerFlags         []string          `json:"schedulerFlags"`
+	RuntimeConfig          string            `json:"runtimeConfig"`
+	CalicoNatOutgoing      int               `json:"calicoNatOutgoing"`
+	HttpProxy              string            `json:"httpProxy"`
+	Tags                   map[string]string `json:"tags,omitempty"`
 }
 
 func (c QbertImpl) CreateCluster(
@@ -281,6 +309,86 @@ func (c QbertImpl) DetachNode(clusterID, projectID, token string, nodeID string)
 	return nil
 }
 
+// UpgradeCluster starts the upgrade of the cluster to the pf9-kube role version
+func (c QbertImpl) UpgradeCluster(clusterID, projectID, token, version string) error {
+	zap.S().Debugf("Upgrading the cluster: %s to %s", clusterID, version)
+
+	byt, err := json.Marshal(map[string]string{"kubeRoleVersion": version})
+	if err != nil {
+		return fmt.Errorf("Unable to marshal payload: %s", err.Error())
+	}
+
+	upgradeEndpoint := fmt.Sprintf(
+		"%s/qbert/v4/%s/clusters/%s/upgrade",
+		c.fqdn, projectID, clusterID)
+
+	client := http.Client{}
+
+	req, err := http.NewRequest("POST", upgradeEndpoint, strings.NewReader(string(byt)))
+	if err != nil {
+		return fmt.Errorf("Unable to create a request: %w", err)
+	}
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to POST request through client: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		respString, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			zap.S().Info("Error occurred while converting response body to string")
+		}
+		zap.S().Debug(string(respString))
+		return fmt.Errorf("%v", string(respString))
+	}
+	return nil
+}
+
+// UpdateEtcdBackup replaces the etcd backup settings of the cluster
+func (c QbertImpl) UpdateEtcdBackup(clusterID, projectID, token string, backup EtcdBackup) error {
+	zap.S().Debugf("Updating the etcd backup of the cluster: %s", clusterID)
+
+	byt, err := json.Marshal(map[string]EtcdBackup{"etcdBackup": backup})
+	if err != nil {
+		return fmt.Errorf("Unable to marshal payload: %s", err.Error())
+	}
+
+	updateEndpoint := fmt.Sprintf(
+		"%s/qbert/v3/%s/clusters/%s",
+		c.fqdn, projectID, clusterID)
+
+	client := http.Client{}
+
+	req, err := http.NewRequest("PUT", updateEndpoint, strings.NewReader(string(byt)))
+	if err != nil {
+		return fmt.Errorf("Unable to create a request: %w", err)
+	}
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to PUT request through client: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		respString, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			zap.S().Info("Error occurred while converting response body to string")
+		}
+		zap.S().Debug(string(respString))
+		return fmt.Errorf("%v", string(respString))
+	}
+	return nil
+}
+
 func (c QbertImpl) DeleteCluster(clusterID, projectID, token string) error {
 	zap.S().Debugf("Deleting the %s cluster: ", clusterID)
 
@@ -419,40 +527,132 @@ func (c QbertImpl) GetNodePoolID(projectID, token string) (string, error) {
 }
 
 func (c QbertImpl) CheckClusterExists(name, projectID, token string) (bool, string, string, error) {
-	qbertApiClustersEndpoint := fmt.Sprintf("%s/qbert/v3/%s/clusters", c.fqdn, projectID) // Context should return projectID,make changes to keystoneAuth.
+	clusters, err := c.ListClusters(projectID, token)
+	if err != nil {
+		return false, "", "", err
+	}
+
+	for _, cluster := range clusters {
+		if cluster.Name == name {
+			return true, cluster.Uuid, cluster.Status, nil
+		}
+	}
+
+	return false, "", "", nil
+}
+
+// ListClusters returns all the clusters in the project
+func (c QbertImpl) ListClusters(projectID, token string) ([]Cluster, error) {
+	qbertApiClustersEndpoint := fmt.Sprintf("%s/qbert/v3/%s/clusters", c.fqdn, projectID)
 	client := http.Client{}
 	req, err := http.NewRequest("GET", qbertApiClustersEndpoint, nil)
-
 	if err != nil {
-		return false, "", "", fmt.Errorf("Unable to create request to check cluster name: %w", err)
+		return nil, fmt.Errorf("Unable to create request to list clusters: %w", err)
 	}
 
 	req.Header.Set("X-Auth-Token", token)
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := client.Do(req)
 	if err != nil {
-		return false, "", "", err
+		return nil, err
 	}
+	defer resp.Body.Close()
+
 	if resp.StatusCode != 200 {
-		return false, "", "", fmt.Errorf("Couldn't query the qbert Endpoint: %d", resp.StatusCode)
+		return nil, fmt.Errorf("Couldn't query the qbert Endpoint: %d", resp.StatusCode)
 	}
-	var payload []map[string]interface{}
 
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(&payload)
+	var clusters []Cluster
+	if err = json.NewDecoder(resp.Body).Decode(&clusters); err != nil {
+		return nil, fmt.Errorf("Unable to decode clusters: %w", err)
+	}
+	return clusters, nil
+}
+
+// GetKubeconfig returns the kubeconfig of the cluster, the token of its user
+// is a placeholder to be replaced with the credentials.
+func (c QbertImpl) GetKubeconfig(clusterID, projectID, token string) ([]byte, error) {
+	url := fmt.Sprintf("%s/qbert/v3/%s/kubeconfig/%s", c.fqdn, projectID, clusterID)
+	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return false, "", "", err
+		return nil, fmt.Errorf("Unable to create request to get kubeconfig: %w", err)
 	}
 
-	for _, val := range payload {
-		if val["name"] == name {
-			cluster_uuid := val["uuid"].(string)
-			cluster_status := val["status"].(string)
-			return true, cluster_uuid, cluster_status, nil
-		}
+	req.Header.Set("X-Auth-Token", token)
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	return false, "", "", nil
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read kubeconfig: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Couldn't get the kubeconfig of cluster %s: %d %s", clusterID, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// Phases of a cluster, as summarized by ClusterStatus.Phase
+const (
+	ClusterCreating   = "creating"
+	ClusterConverging = "converging"
+	ClusterOk         = "ok"
+	ClusterError      = "error"
+)
+
+// ClusterStatus is the state of a cluster as reported by qbert
+type ClusterStatus struct {
+	Status     string `json:"status"`
+	TaskStatus string `json:"taskStatus"`
+	TaskError  string `json:"taskError"`
+}
+
+// Phase summarizes the status and task status of the cluster as one of
+// creating, converging, ok or error.
+func (s ClusterStatus) Phase() string {
+	switch {
+	case s.Status == "error" || s.TaskStatus == "error":
+		return ClusterError
+	case s.Status == "ok" && (s.TaskStatus == "success" || s.TaskStatus == ""):
+		return ClusterOk
+	case s.Status == "" || s.Status == "pending" || s.Status == "creating":
+		return ClusterCreating
+	}
+	return ClusterConverging
+}
+
+// GetClusterStatus returns the current status of the cluster
+func (c QbertImpl) GetClusterStatus(clusterID, projectID, token string) (ClusterStatus, error) {
+	url := fmt.Sprintf("%s/qbert/v3/%s/clusters/%s", c.fqdn, projectID, clusterID)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return ClusterStatus{}, fmt.Errorf("Unable to create request to get cluster status: %w", err)
+	}
+
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ClusterStatus{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 400 || resp.StatusCode == 404 {
+		return ClusterStatus{}, fmt.Errorf("Cluster %s does not exist", clusterID)
+	} else if resp.StatusCode != 200 {
+		return ClusterStatus{}, fmt.Errorf("Couldn't get the status of cluster %s: %d", clusterID, resp.StatusCode)
+	}
+
+	var status ClusterStatus
+	if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return ClusterStatus{}, fmt.Errorf("Unable to decode cluster status: %w", err)
+	}
+	return status, nil
 }
 
 func (c QbertImpl) CheckClusterExistsWithUuid(uuid, projectID, token string) (string, error) {
@@ -591,55 +791,57 @@ func (c QbertImpl) GetNodeInfo(token, projectID, hostUUID string) (Node, error)
 	return node, nil
 }
 
-func (c QbertImpl) GetAllNodes(token, projectID string) []Node {
+func (c QbertImpl) GetAllNodes(token, projectID string) ([]Node, error) {
 	url := fmt.Sprintf("%s/qbert/v3/%s/nodes", c.fqdn, projectID)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		zap.S().Infof("Unable to create request to check if node is connected to any cluster: %w", err)
+		return nil, fmt.Errorf("Unable to create request to check if node is connected to any cluster: %w", err)
 	}
 	req.Header.Set("X-Auth-Token", token)
 	req.Header.Set("Content-Type", "application/json")
 	client := http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		zap.S().Fatalf("Unable to send request to qbert: %w", err)
+		return nil, fmt.Errorf("Unable to send request to qbert: %w", err)
 	}
+	defer resp.Body.Close()
 
 	var nodes []Node
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		zap.S().Fatalf("Unable to read resp body of node info: %w", err)
+		return nil, fmt.Errorf("Unable to read resp body of node info: %w", err)
 	}
 	err = json.Unmarshal(body, &nodes)
 	if err != nil {
-		zap.S().Debugf("Unable to unmarshal node info: %w", err)
+		zap.S().Debugf("Unable to unmarshal node info: %s", err)
 	}
-	return nodes
+	return nodes, nil
 }
 
-func (c QbertImpl) GetPMKVersions(token, projectID string) PMKVersions {
+func (c QbertImpl) GetPMKVersions(token, projectID string) (PMKVersions, error) {
+	pmkVersions := PMKVersions{}
 	url := fmt.Sprintf("%s/qbert/v4/%s/clusters/supportedRoleVersions", c.fqdn, projectID)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		zap.S().Fatalf("Unable to create request to get pmk versions: %w", err)
+		return pmkVersions, fmt.Errorf("Unable to create request to get pmk versions: %w", err)
 	}
 	req.Header.Set("X-Auth-Token", token)
 	req.Header.Set("Content-Type", "application/json")
 	client := http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		zap.S().Fatalf("Unable to send request to qbert: %w", err)
+		return pmkVersions, fmt.Errorf("Unable to send request to qbert: %w", err)
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		zap.S().Fatalf("Unable to read resp body: %w", err)
+		return pmkVersions, fmt.Errorf("Unable to read resp body: %w", err)
 	}
 
-	pmkVersions := PMKVersions{}
 	err = json.Unmarshal(body, &pmkVersions)
 	if err != nil {
-		zap.S().Debugf("Unable to unmarshal resp body: %w", err)
+		zap.S().Debugf("Unable to unmarshal resp body: %s", err)
 	}
-	return pmkVersions
+	return pmkVersions, nil
 }
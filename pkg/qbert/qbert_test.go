@@ -0,0 +1,146 @@
+package qbert
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var clustersResp = `[
+  {
+    "uuid": "c1a2b3c4-0000-0000-0000-000000000001",
+    "name": "prod",
+    "status": "ok",
+    "taskStatus": "success",
+    "kubeRoleVersion": "1.26.14-pmk.123",
+    "networkPlugin": "calico",
+    "containersCidr": "10.20.0.0/16",
+    "servicesCidr": "10.21.0.0/16",
+    "numMasters": 3,
+    "numWorkers": 5
+  },
+  {
+    "uuid": "c1a2b3c4-0000-0000-0000-000000000002",
+    "name": "staging",
+    "status": "creating",
+    "kubeRoleVersion": "1.27.10-pmk.45",
+    "networkPlugin": "flannel",
+    "numMasters": 1,
+    "numWorkers": 0
+  }
+]`
+
+func newClustersServer(t *testing.T, status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/qbert/v3/project-id/clusters", req.URL.Path)
+		assert.Equal(t, "token", req.Header.Get("X-Auth-Token"))
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestListClusters(t *testing.T) {
+	srv := newClustersServer(t, 200, clustersResp)
+	defer srv.Close()
+
+	clusters, err := NewQbert(srv.URL).ListClusters("project-id", "token")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(clusters))
+	assert.Equal(t, Cluster{
+		Uuid:            "c1a2b3c4-0000-0000-0000-000000000001",
+		Name:            "prod",
+		Status:          "ok",
+		TaskStatus:      "success",
+		KubeRoleVersion: "1.26.14-pmk.123",
+		NetworkPlugin:   CNIBackend("calico"),
+		ContainerCIDR:   "10.20.0.0/16",
+		ServiceCIDR:     "10.21.0.0/16",
+		NumMasters:      3,
+		NumWorkers:      5,
+	}, clusters[0])
+	assert.Equal(t, "staging", clusters[1].Name)
+}
+
+func TestListClustersError(t *testing.T) {
+	srv := newClustersServer(t, 500, "")
+	defer srv.Close()
+
+	_, err := NewQbert(srv.URL).ListClusters("project-id", "token")
+	assert.Equal(t, fmt.Errorf("Couldn't query the qbert Endpoint: 500"), err)
+}
+
+func TestCheckClusterExists(t *testing.T) {
+	srv := newClustersServer(t, 200, clustersResp)
+	defer srv.Close()
+
+	exists, uuid, status, err := NewQbert(srv.URL).CheckClusterExists("staging", "project-id", "token")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "c1a2b3c4-0000-0000-0000-000000000002", uuid)
+	assert.Equal(t, "creating", status)
+
+	exists, _, _, err = NewQbert(srv.URL).CheckClusterExists("missing", "project-id", "token")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
+
+func TestGetKubeconfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "token", req.Header.Get("X-Auth-Token"))
+		if req.URL.Path != "/qbert/v3/project-id/kubeconfig/cluster-id" {
+			w.WriteHeader(404)
+			return
+		}
+		fmt.Fprint(w, "apiVersion: v1\nkind: Config\n")
+	}))
+	defer srv.Close()
+
+	kubeconfig, err := NewQbert(srv.URL).GetKubeconfig("cluster-id", "project-id", "token")
+	assert.Nil(t, err)
+	assert.Equal(t, "apiVersion: v1\nkind: Config\n", string(kubeconfig))
+
+	_, err = NewQbert(srv.URL).GetKubeconfig("missing", "project-id", "token")
+	assert.NotNil(t, err)
+}
+
+func TestClusterStatusPhase(t *testing.T) {
+	cases := map[string]struct {
+		status ClusterStatus
+		phase  string
+	}{
+		"pending":          {ClusterStatus{Status: "pending"}, ClusterCreating},
+		"creating":         {ClusterStatus{Status: "creating", TaskStatus: "converging"}, ClusterCreating},
+		"converging":       {ClusterStatus{Status: "ok", TaskStatus: "converging"}, ClusterConverging},
+		"updating":         {ClusterStatus{Status: "updating", TaskStatus: "success"}, ClusterConverging},
+		"ok":               {ClusterStatus{Status: "ok", TaskStatus: "success"}, ClusterOk},
+		"failed task":      {ClusterStatus{Status: "ok", TaskStatus: "error", TaskError: "etcd failed"}, ClusterError},
+		"cluster in error": {ClusterStatus{Status: "error"}, ClusterError},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.phase, tc.status.Phase())
+		})
+	}
+}
+
+func TestGetClusterStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "token", req.Header.Get("X-Auth-Token"))
+		if req.URL.Path != "/qbert/v3/project-id/clusters/cluster-id" {
+			w.WriteHeader(400)
+			return
+		}
+		fmt.Fprint(w, `{"uuid": "cluster-id", "status": "ok", "taskStatus": "error", "taskError": "etcd failed"}`)
+	}))
+	defer srv.Close()
+
+	status, err := NewQbert(srv.URL).GetClusterStatus("cluster-id", "project-id", "token")
+	assert.Nil(t, err)
+	assert.Equal(t, ClusterStatus{Status: "ok", TaskStatus: "error", TaskError: "etcd failed"}, status)
+
+	_, err = NewQbert(srv.URL).GetClusterStatus("missing", "project-id", "token")
+	assert.NotNil(t, err)
+}
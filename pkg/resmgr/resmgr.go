@@ -3,6 +3,7 @@ package resmgr
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -19,10 +20,18 @@ import (
 
 type Resmgr interface {
 	AuthorizeHost(hostID, token string, version string, projectID string) error
-	GetHostId(token string, hostIP []string) []string
-	HostStatus(token string, hostID string) bool
+	GetHostId(token string, hostIP []string) ([]string, error)
+	HostStatus(token string, hostID string) error
+	ListHosts(token string) ([]Host, error)
 }
 
+var (
+	// ErrHostNotFound is returned when no host known to resmgr matches
+	ErrHostNotFound = errors.New("host not found")
+	// ErrHostNotResponding is returned when the hostagent of a host does not report to resmgr
+	ErrHostNotResponding = errors.New("host is not responding")
+)
+
 type ResmgrImpl struct {
 	fqdn          string
 	minWait       time.Duration
@@ -40,6 +49,16 @@ type hostInfo []struct {
 	ID string `json:"id,omitempty"`
 }
 
+// Host is a host registered with resmgr by its hostagent
+type Host struct {
+	ID         string
+	Hostname   string
+	IPs        []string
+	Responding bool
+	Roles      []string
+	RoleStatus string
+}
+
 func NewResmgr(fqdn string, maxHttpRetry int, minWait, maxWait time.Duration, allowInsecure bool) Resmgr {
 
 	return &ResmgrImpl{fqdn, minWait, maxWait, maxHttpRetry, allowInsecure}
@@ -87,28 +106,30 @@ func (c *ResmgrImpl) AuthorizeHost(hostID string, token string, version string,
 	return nil
 }
 
-func (c *ResmgrImpl) GetHostId(token string, hostIPs []string) []string {
+// GetHostId returns the IDs of the hosts with the IPs, it fails with ErrHostNotFound
+// when one of them is unknown.
+func (c *ResmgrImpl) GetHostId(token string, hostIPs []string) ([]string, error) {
 	url := fmt.Sprintf("%s/resmgr/v1/hosts", c.fqdn)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		zap.S().Fatalf("Unable to create a new request: %w", err)
+		return nil, fmt.Errorf("Unable to create a new request: %w", err)
 	}
 	req.Header.Set("X-Auth-Token", token)
 	client := http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		zap.S().Fatalf("Client is unable to send the request %s: %w", url, err)
+		return nil, fmt.Errorf("Client is unable to send the request %s: %w", url, err)
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		zap.S().Fatalf("Unable to read resp body for request %s : %w", url, err)
+		return nil, fmt.Errorf("Unable to read resp body for request %s : %w", url, err)
 	}
 
 	nodeData := hostInfo{}
 	err = json.Unmarshal(body, &nodeData)
 	if err != nil {
-		zap.S().Debugf("Unable to unmarshal resp body to struct: %w", err)
+		zap.S().Debugf("Unable to unmarshal resp body to struct: %s", err)
 	}
 	var hostUUIDs []string
 
@@ -123,29 +144,38 @@ func (c *ResmgrImpl) GetHostId(token string, hostIPs []string) []string {
 			}
 		}
 		if hostNotFound {
-			zap.S().Fatalf("Unable to find host with IP %v please try again or run prep-node first", hostip)
+			return nil, fmt.Errorf("%w: unable to find host with IP %v please try again or run prep-node first", ErrHostNotFound, hostip)
 		}
 	}
 
-	return hostUUIDs
+	return hostUUIDs, nil
 }
 
-func (c *ResmgrImpl) HostStatus(token string, hostID string) bool {
+// HostStatus returns nil when the host is responding, ErrHostNotResponding when
+// it is not and ErrHostNotFound when resmgr does not know it. Other failures of
+// resmgr are returned with the status code and the body.
+func (c *ResmgrImpl) HostStatus(token string, hostID string) error {
 	url := fmt.Sprintf("%s/resmgr/v1/hosts/%s", c.fqdn, hostID)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		zap.S().Fatalf("Unable to create a new request: %w", err)
+		return fmt.Errorf("Unable to create a new request: %w", err)
 	}
 	req.Header.Set("X-Auth-Token", token)
 	client := http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		zap.S().Fatalf("Client is unable to send the request: %w", err)
+		return fmt.Errorf("Client is unable to send the request: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrHostNotFound, hostID)
+	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		zap.S().Fatalf("Unable to read resp body: %w", err)
+		return fmt.Errorf("Unable to read resp body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("url %s returned status code: %d, error: %s", url, resp.StatusCode, string(body))
 	}
 
 	type hostInfo struct {
@@ -156,7 +186,63 @@ func (c *ResmgrImpl) HostStatus(token string, hostID string) bool {
 	host := hostInfo{}
 	err = json.Unmarshal(body, &host)
 	if err != nil {
-		zap.S().Debugf("Unable to unmarshal resp body to struct: %w", err)
+		zap.S().Debugf("Unable to unmarshal resp body to struct: %s", err)
+	}
+	if !host.Info.Responding {
+		return fmt.Errorf("%w: %s", ErrHostNotResponding, hostID)
+	}
+	return nil
+}
+
+// ListHosts returns all the hosts known to resmgr
+func (c *ResmgrImpl) ListHosts(token string) ([]Host, error) {
+	url := fmt.Sprintf("%s/resmgr/v1/hosts", c.fqdn)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create a new request: %w", err)
+	}
+	req.Header.Set("X-Auth-Token", token)
+	client := http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Client is unable to send the request %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read resp body for request %s : %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("url %s returned status code: %d, error: %s", url, resp.StatusCode, string(body))
+	}
+
+	var hostList []struct {
+		ID   string `json:"id"`
+		Info struct {
+			Hostname   string `json:"hostname"`
+			Responding bool   `json:"responding"`
+		} `json:"info"`
+		Extensions struct {
+			IPAddress struct {
+				Data []string `json:"data"`
+			} `json:"ip_address,omitempty"`
+		} `json:"extensions,omitempty"`
+		Roles      []string `json:"roles"`
+		RoleStatus string   `json:"role_status"`
+	}
+	if err := json.Unmarshal(body, &hostList); err != nil {
+		return nil, fmt.Errorf("Unable to parse the hosts: %w", err)
+	}
+	hosts := make([]Host, 0, len(hostList))
+	for _, h := range hostList {
+		hosts = append(hosts, Host{
+			ID:         h.ID,
+			Hostname:   h.Info.Hostname,
+			IPs:        h.Extensions.IPAddress.Data,
+			Responding: h.Info.Responding,
+			Roles:      h.Roles,
+			RoleStatus: h.RoleStatus,
+		})
 	}
-	return host.Info.Responding
+	return hosts, nil
 }
@@ -1,11 +1,16 @@
 package resmgr
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	rhttp "github.com/hashicorp/go-retryablehttp"
 	"github.com/platform9/pf9ctl/pkg/util"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestRetryHTTP(t *testing.T) {
@@ -26,3 +31,39 @@ func TestRetryHTTP(t *testing.T) {
 	defer resp.Body.Close()
 
 }
+
+func TestHostStatus(t *testing.T) {
+	cases := map[string]struct {
+		status int
+		body   string
+		err    error
+		msg    string
+	}{
+		"responding":     {status: http.StatusOK, body: `{"info": {"responding": true}}`},
+		"not responding": {status: http.StatusOK, body: `{"info": {"responding": false}}`, err: ErrHostNotResponding},
+		"not found":      {status: http.StatusNotFound, err: ErrHostNotFound},
+		"unauthorized":   {status: http.StatusUnauthorized, body: "token expired", msg: "returned status code: 401, error: token expired"},
+		"server error":   {status: http.StatusServiceUnavailable, body: "resmgr is down", msg: "returned status code: 503, error: resmgr is down"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/resmgr/v1/hosts/host-1", r.URL.Path)
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer srv.Close()
+
+			err := NewResmgr(srv.URL, 1, time.Millisecond, time.Millisecond, false).HostStatus("token", "host-1")
+			switch {
+			case tc.err != nil:
+				assert.True(t, errors.Is(err, tc.err), err)
+			case tc.msg != "":
+				assert.ErrorContains(t, err, tc.msg)
+				assert.False(t, errors.Is(err, ErrHostNotResponding))
+			default:
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
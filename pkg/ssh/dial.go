@@ -0,0 +1,119 @@
+// Copyright 2020 Platform9 Systems Inc.
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// hop is one of the hosts the connection goes through
+type hop struct {
+	user string
+	addr string
+}
+
+// AgentAvailable reports if an ssh-agent can be used for authentication
+func AgentAvailable() bool {
+	return os.Getenv("SSH_AUTH_SOCK") != ""
+}
+
+// agentAuthMethod authenticates with the keys of the agent listening on SSH_AUTH_SOCK,
+// the returned connection to the agent is to be closed once the hops are dialed
+func agentAuthMethod() (ssh.AuthMethod, io.Closer) {
+	if !AgentAvailable() {
+		return nil, nil
+	}
+	conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK"))
+	if err != nil {
+		zap.S().Debugf("Unable to connect to ssh-agent: %s", err)
+		return nil, nil
+	}
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), conn
+}
+
+// parseJumpHosts parses a ProxyJump style list of bastions, users default to the node user
+func parseJumpHosts(jumpHost, defaultUser string) ([]hop, error) {
+	if strings.TrimSpace(jumpHost) == "" {
+		return nil, nil
+	}
+
+	var hops []hop
+	for _, jump := range strings.Split(jumpHost, ",") {
+		jump = strings.TrimSpace(jump)
+		user := defaultUser
+		if i := strings.LastIndex(jump, "@"); i >= 0 {
+			user, jump = jump[:i], jump[i+1:]
+		}
+		host, port := jump, "22"
+		if h, p, err := net.SplitHostPort(jump); err == nil {
+			host, port = h, p
+		}
+		host = strings.Trim(host, "[]")
+		if host == "" || user == "" {
+			return nil, fmt.Errorf("invalid jump host %q, expected [user@]host[:port]", jump)
+		}
+		hops = append(hops, hop{user: user, addr: net.JoinHostPort(host, port)})
+	}
+	return hops, nil
+}
+
+// hopClient is the ssh client of the last hop, it keeps the clients of the jump
+// hosts the connection goes through so they are closed with it
+type hopClient struct {
+	*ssh.Client
+	jumps []*ssh.Client
+}
+
+// Close closes the connection to the last hop, then the jump hosts last one first
+func (c *hopClient) Close() error {
+	err := c.Client.Close()
+	for i := len(c.jumps) - 1; i >= 0; i-- {
+		c.jumps[i].Close()
+	}
+	return err
+}
+
+// dialHops connects to the last hop, each hop is reached through the ssh connection of the previous one
+func dialHops(hops []hop, authMethods []ssh.AuthMethod, hostKeyCallback ssh.HostKeyCallback, knownHosts string) (*hopClient, error) {
+	var client *hopClient
+	for _, h := range hops {
+		sshConfig := &ssh.ClientConfig{
+			User:              h.user,
+			Auth:              authMethods,
+			HostKeyCallback:   hostKeyCallback,
+			HostKeyAlgorithms: knownHostKeyAlgorithms(knownHosts, h.addr),
+		}
+
+		if client == nil {
+			first, err := ssh.Dial("tcp", h.addr, sshConfig)
+			if err != nil {
+				return nil, err
+			}
+			client = &hopClient{Client: first}
+			continue
+		}
+
+		zap.S().Debugf("Connecting to %s through %s", h.addr, client.RemoteAddr())
+		conn, err := client.Dial("tcp", h.addr)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("unable to reach %s through jump host %s: %s", h.addr, client.RemoteAddr(), err)
+		}
+		c, chans, reqs, err := ssh.NewClientConn(conn, h.addr, sshConfig)
+		if err != nil {
+			conn.Close()
+			client.Close()
+			return nil, err
+		}
+		client.jumps = append(client.jumps, client.Client)
+		client.Client = ssh.NewClient(c, chans, reqs)
+	}
+	return client, nil
+}
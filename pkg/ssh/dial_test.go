@@ -0,0 +1,149 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestParseJumpHosts(t *testing.T) {
+	cases := map[string]struct {
+		jumpHost string
+		hops     []hop
+		err      bool
+	}{
+		"Empty": {
+			jumpHost: "",
+			hops:     nil,
+		},
+		"HostOnly": {
+			jumpHost: "bastion.example.com",
+			hops:     []hop{{user: "ubuntu", addr: "bastion.example.com:22"}},
+		},
+		"UserAndPort": {
+			jumpHost: "admin@10.0.0.10:2222",
+			hops:     []hop{{user: "admin", addr: "10.0.0.10:2222"}},
+		},
+		"Chain": {
+			jumpHost: "admin@bastion1, bastion2:2200",
+			hops:     []hop{{user: "admin", addr: "bastion1:22"}, {user: "ubuntu", addr: "bastion2:2200"}},
+		},
+		"IPv6": {
+			jumpHost: "[fd00::10]:2222,fd00::11",
+			hops:     []hop{{user: "ubuntu", addr: "[fd00::10]:2222"}, {user: "ubuntu", addr: "[fd00::11]:22"}},
+		},
+		"MissingHost": {
+			jumpHost: "admin@",
+			err:      true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			hops, err := parseJumpHosts(tc.jumpHost, "ubuntu")
+			if tc.err {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.hops, hops)
+		})
+	}
+}
+
+func TestAgentAuthMethod(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	assert.False(t, AgentAvailable())
+	auth, conn := agentAuthMethod()
+	assert.Nil(t, auth)
+	assert.Nil(t, conn)
+}
+
+// testSSHServer accepts one ssh connection without authentication and forwards its
+// direct-tcpip channels, closed is closed when the connection ends
+type testSSHServer struct {
+	addr   string
+	closed chan struct{}
+}
+
+func newTestSSHServer(t *testing.T) *testSSHServer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.Nil(t, err)
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	t.Cleanup(func() { l.Close() })
+	srv := &testSSHServer{addr: l.Addr().String(), closed: make(chan struct{})}
+
+	go func() {
+		defer close(srv.closed)
+		nc, err := l.Accept()
+		if err != nil {
+			return
+		}
+		conn, chans, reqs, err := ssh.NewServerConn(nc, config)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		go func() {
+			for newChan := range chans {
+				var target struct {
+					Host       string
+					Port       uint32
+					OriginHost string
+					OriginPort uint32
+				}
+				if newChan.ChannelType() != "direct-tcpip" || ssh.Unmarshal(newChan.ExtraData(), &target) != nil {
+					newChan.Reject(ssh.UnknownChannelType, "only direct-tcpip")
+					continue
+				}
+				out, err := net.Dial("tcp", net.JoinHostPort(target.Host, fmt.Sprint(target.Port)))
+				if err != nil {
+					newChan.Reject(ssh.ConnectionFailed, err.Error())
+					continue
+				}
+				ch, chReqs, err := newChan.Accept()
+				if err != nil {
+					out.Close()
+					continue
+				}
+				go ssh.DiscardRequests(chReqs)
+				go func() { io.Copy(ch, out); ch.Close() }()
+				go func() { io.Copy(out, ch); out.Close() }()
+			}
+		}()
+		conn.Wait()
+	}()
+	return srv
+}
+
+func TestDialHopsClosesJumpHosts(t *testing.T) {
+	bastion1, bastion2, node := newTestSSHServer(t), newTestSSHServer(t), newTestSSHServer(t)
+	hops := []hop{{user: "admin", addr: bastion1.addr}, {user: "admin", addr: bastion2.addr}, {user: "ubuntu", addr: node.addr}}
+
+	client, err := dialHops(hops, nil, ssh.InsecureIgnoreHostKey(), "")
+	assert.Nil(t, err)
+	assert.Equal(t, "ubuntu", client.User())
+	assert.Equal(t, 2, len(client.jumps))
+
+	assert.Nil(t, client.Close())
+	for _, srv := range []*testSSHServer{node, bastion2, bastion1} {
+		select {
+		case <-srv.closed:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("connection to %s is still open", srv.addr)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Platform9 Systems Inc.
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// HostKeyTOFU trusts the key of a host seen for the first time and records it
+	HostKeyTOFU = "tofu"
+	// HostKeyStrict refuses hosts which are not in the known_hosts file
+	HostKeyStrict = "strict"
+)
+
+var (
+	// KnownHostsFile is the known_hosts file used for verifying host keys,
+	// ~/.ssh/known_hosts when empty
+	KnownHostsFile string
+	// HostKeyCheck is the host key verification mode, tofu or strict
+	HostKeyCheck = HostKeyTOFU
+
+	ErrInvalidHostKeyCheck = fmt.Errorf("invalid host key check mode, supported modes are %s and %s", HostKeyTOFU, HostKeyStrict)
+
+	// serializes the updates of the known_hosts file when hosts are processed in parallel
+	knownHostsLock sync.Mutex
+)
+
+// UnknownHostError is returned in strict mode for hosts missing from known_hosts
+type UnknownHostError struct {
+	Host        string
+	Fingerprint string
+	File        string
+}
+
+func (e *UnknownHostError) Error() string {
+	return fmt.Sprintf("host key for %s (%s) is not in %s, add it with `ssh-keyscan %s >> %s` after checking the fingerprint, or use --host-key-check=%s",
+		e.Host, e.Fingerprint, e.File, e.Host, e.File, HostKeyTOFU)
+}
+
+// HostKeyChangedError is returned when the key offered by a host does not match known_hosts
+type HostKeyChangedError struct {
+	Host        string
+	Fingerprint string
+	Known       []string
+}
+
+func (e *HostKeyChangedError) Error() string {
+	return fmt.Sprintf("host key for %s has changed, it offered %s but %s is expected. Someone could be impersonating the host, "+
+		"if the host was reinstalled remove the old key with `ssh-keygen -R %s`",
+		e.Host, e.Fingerprint, strings.Join(e.Known, ", "), e.Host)
+}
+
+func knownHostsLoc() (string, error) {
+	if KnownHostsFile != "" {
+		return KnownHostsFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find the known_hosts file: %s", err)
+	}
+	return filepath.Join(home, ".ssh", "known_hosts"), nil
+}
+
+// HostKeyCallback verifies host keys against the known_hosts file using the given mode
+func HostKeyCallback(file, mode string) (ssh.HostKeyCallback, error) {
+	if mode != HostKeyTOFU && mode != HostKeyStrict {
+		return nil, ErrInvalidHostKeyCheck
+	}
+
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		if mode == HostKeyStrict {
+			return nil, fmt.Errorf("known_hosts file %s not found, it is required with --host-key-check=%s", file, HostKeyStrict)
+		}
+		if err = os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return nil, fmt.Errorf("unable to create %s: %s", filepath.Dir(file), err)
+		}
+		if err = os.WriteFile(file, nil, 0600); err != nil {
+			return nil, fmt.Errorf("unable to create %s: %s", file, err)
+		}
+	}
+
+	check, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", file, err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+
+		host := knownhosts.Normalize(hostname)
+		fingerprint := ssh.FingerprintSHA256(key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			var known []string
+			for _, want := range keyErr.Want {
+				known = append(known, fmt.Sprintf("%s (%s:%d)", ssh.FingerprintSHA256(want.Key), want.Filename, want.Line))
+			}
+			return &HostKeyChangedError{Host: host, Fingerprint: fingerprint, Known: known}
+		}
+		if mode == HostKeyStrict {
+			return &UnknownHostError{Host: host, Fingerprint: fingerprint, File: file}
+		}
+		return addKnownHost(file, host, key)
+	}, nil
+}
+
+// addKnownHost records the key of a host seen for the first time
+func addKnownHost(file, host string, key ssh.PublicKey) error {
+	knownHostsLock.Lock()
+	defer knownHostsLock.Unlock()
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to record host key for %s: %s", host, err)
+	}
+	defer f.Close()
+	if _, err = fmt.Fprintln(f, knownhosts.Line([]string{host}, key)); err != nil {
+		return fmt.Errorf("unable to record host key for %s: %s", host, err)
+	}
+	zap.S().Infof("Added host key %s for %s to %s", ssh.FingerprintSHA256(key), host, file)
+	return nil
+}
+
+// knownHostKeyAlgorithms returns the key algorithms recorded for the host, so the
+// host is asked for the type of key known_hosts has instead of its preferred one.
+func knownHostKeyAlgorithms(file, addr string) []string {
+	check, err := knownhosts.New(file)
+	if err != nil {
+		return nil
+	}
+	// Checking a key which can not match returns the known keys of the host
+	var keyErr *knownhosts.KeyError
+	if err = check(addr, &net.TCPAddr{}, probeKey{}); !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algos []string
+	for _, want := range keyErr.Want {
+		switch keyType := want.Key.Type(); keyType {
+		case ssh.KeyAlgoRSA:
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algos = append(algos, keyType)
+		}
+	}
+	return algos
+}
+
+// probeKey is a public key which never matches a known_hosts entry
+type probeKey struct{}
+
+func (probeKey) Type() string                            { return "pf9ctl-probe" }
+func (probeKey) Marshal() []byte                         { return []byte("pf9ctl-probe") }
+func (probeKey) Verify(_ []byte, _ *ssh.Signature) error { return errors.New("probe key") }
@@ -0,0 +1,80 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.Nil(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	assert.Nil(t, err)
+	return key
+}
+
+var remote = &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+
+func TestHostKeyCallbackTOFU(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".ssh", "known_hosts")
+	key := newHostKey(t)
+
+	// The first connection records the key
+	cb, err := HostKeyCallback(file, HostKeyTOFU)
+	assert.Nil(t, err)
+	assert.Nil(t, cb("10.0.0.1:22", remote, key))
+	byt, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(byt), "10.0.0.1 ssh-ed25519 "))
+
+	// The recorded key is accepted, a different one is refused
+	cb, err = HostKeyCallback(file, HostKeyTOFU)
+	assert.Nil(t, err)
+	assert.Nil(t, cb("10.0.0.1:22", remote, key))
+
+	otherKey := newHostKey(t)
+	err = cb("10.0.0.1:22", remote, otherKey)
+	var changed *HostKeyChangedError
+	assert.True(t, errors.As(err, &changed))
+	assert.Equal(t, ssh.FingerprintSHA256(otherKey), changed.Fingerprint)
+	assert.Contains(t, err.Error(), ssh.FingerprintSHA256(key))
+
+	assert.Equal(t, []string{ssh.KeyAlgoED25519}, knownHostKeyAlgorithms(file, "10.0.0.1:22"))
+	assert.Nil(t, knownHostKeyAlgorithms(file, "10.0.0.2:22"))
+}
+
+func TestHostKeyCallbackStrict(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "known_hosts")
+
+	_, err := HostKeyCallback(file, HostKeyStrict)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, os.WriteFile(file, nil, 0600))
+	cb, err := HostKeyCallback(file, HostKeyStrict)
+	assert.Nil(t, err)
+
+	key := newHostKey(t)
+	err = cb("10.0.0.1:22", remote, key)
+	var unknown *UnknownHostError
+	assert.True(t, errors.As(err, &unknown))
+	assert.Equal(t, ssh.FingerprintSHA256(key), unknown.Fingerprint)
+
+	// Nothing is recorded in strict mode
+	byt, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "", string(byt))
+}
+
+func TestHostKeyCallbackInvalidMode(t *testing.T) {
+	_, err := HostKeyCallback(filepath.Join(t.TempDir(), "known_hosts"), "off")
+	assert.Equal(t, ErrInvalidHostKeyCheck, err)
+}
@@ -7,10 +7,16 @@ package ssh
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"net"
 	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/pkg/sftp"
 	"go.uber.org/zap"
@@ -21,81 +27,133 @@ import (
 type Client interface {
 	// RunCommand executes the remote command returning the stdout, stderr and any error associated with it
 	RunCommand(cmd string) ([]byte, []byte, error)
+	// RunCommandContext executes the remote command like RunCommand, killing it when ctx is done
+	RunCommandContext(ctx context.Context, cmd string) ([]byte, []byte, error)
 	// Uploadfile uploads the srcFile to remoteDestFilePath and changes the mode to the filemode
 	UploadFile(srcFilePath, remoteDstFilePath string, mode os.FileMode, cb func(read int64, total int64)) error
 	// Downloadfile downloads the remoteFile to localFile and changes the mode to the filemode
 	DownloadFile(remoteFile, localPath string, mode os.FileMode, cb func(read int64, total int64)) error
+	// Close closes the sftp session and the ssh connections, including the ones to the jump hosts
+	Close() error
 }
 
 type client struct {
-	sshClient  *ssh.Client
+	sshClient  *hopClient
 	sftpClient *sftp.Client
 	proxyURL   string
+	host       string
 }
 
 var (
 	SudoPassword string
+	// sudo passwords of the hosts which do not use SudoPassword
+	hostSudoPasswords sync.Map
 )
 
+// SetHostSudoPassword sets the sudo password used on one host instead of SudoPassword
+func SetHostSudoPassword(host, password string) {
+	hostSudoPasswords.Store(host, password)
+}
+
+func (c *client) sudoPassword() string {
+	if password, ok := hostSudoPasswords.Load(c.host); ok {
+		return password.(string)
+	}
+	return SudoPassword
+}
+
 const (
 	runAsSudo = true
 )
 
+// Time given to a cancelled command to exit after SIGTERM before it is killed
+var killDelay = 5 * time.Second
+
 // NewClient creates a new Client that can be used to perform action on a
-// machine
-func NewClient(host string, port int, username string, privateKey []byte, password, proxyURL string) (Client, error) {
+// machine. When jumpHost is set the connection goes through the listed bastions,
+// using the ProxyJump syntax [user@]host[:port][,[user@]host[:port]...].
+func NewClient(host string, port int, username string, privateKey []byte, password, proxyURL, jumpHost string) (Client, error) {
 
-	authMethods := make([]ssh.AuthMethod, 1)
+	var authMethods []ssh.AuthMethod
 	// give preferece to privateKey
 	if privateKey != nil {
 		signer, err := ssh.ParsePrivateKey([]byte(privateKey))
 		if err != nil {
 			return nil, fmt.Errorf("error parsing private key: %s", err)
 		}
-		authMethods[0] = ssh.PublicKeys(signer)
-	} else {
-		authMethods[0] = ssh.Password(password)
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	}
+	if agentAuth, agentConn := agentAuthMethod(); agentAuth != nil {
+		// The agent is only needed while the hops authenticate
+		defer agentConn.Close()
+		authMethods = append(authMethods, agentAuth)
+	}
+	if password != "" || len(authMethods) == 0 {
+		authMethods = append(authMethods, ssh.Password(password))
 	}
-	sshConfig := &ssh.ClientConfig{
-		User: string(username),
-		Auth: authMethods,
-		// by default ignore host key checks
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+
+	knownHosts, err := knownHostsLoc()
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := HostKeyCallback(knownHosts, HostKeyCheck)
+	if err != nil {
+		return nil, err
 	}
 
-	sshClient, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", host, port), sshConfig)
+	hops, err := parseJumpHosts(jumpHost, username)
+	if err != nil {
+		return nil, err
+	}
+	hops = append(hops, hop{user: username, addr: net.JoinHostPort(host, strconv.Itoa(port))})
+
+	sshClient, err := dialHops(hops, authMethods, hostKeyCallback, knownHosts)
 	if err != nil {
 		return nil, fmt.Errorf("unable to dial %s:%d: %s", host, port, err)
 	}
-	sftpClient, err := sftp.NewClient(sshClient)
+	sftpClient, err := sftp.NewClient(sshClient.Client)
+	if err != nil {
+		sshClient.Close()
+		return nil, fmt.Errorf("unable to start sftp on %s:%d: %s", host, port, err)
+	}
 	return &client{
 		sshClient:  sshClient,
 		sftpClient: sftpClient,
 		proxyURL:   proxyURL,
+		host:       host,
 	}, nil
 }
 
+// Close closes the sftp session and the ssh connection with the ones to the jump hosts
+func (c *client) Close() error {
+	c.sftpClient.Close()
+	return c.sshClient.Close()
+}
+
 // RunCommand runs a command on the machine and returns stdout and stderr
 // separately
 func (c *client) RunCommand(cmd string) ([]byte, []byte, error) {
+	return c.RunCommandContext(context.Background(), cmd)
+}
+
+// RunCommandContext runs a command on the machine like RunCommand. When ctx is
+// done the command is sent SIGTERM, which sudo relays, and SIGKILL after killDelay
+// before the session is closed.
+func (c *client) RunCommandContext(ctx context.Context, cmd string) ([]byte, []byte, error) {
 
 	session, err := c.sshClient.NewSession()
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to create session: %s", err)
 	}
-	stdOutPipe, err := session.StdoutPipe()
-	if err != nil {
-		return nil, nil, fmt.Errorf("unable to pipe stdout: %s", err)
-	}
-	stdErrPipe, err := session.StderrPipe()
-	if err != nil {
-		return nil, nil, fmt.Errorf("unable to pipe stderr: %s", err)
-	}
+	defer session.Close()
+	var stdOut, stdErr bytes.Buffer
+	session.Stdout = &stdOut
+	session.Stderr = &stdErr
 	// Prepend sudo if runAsSudo set to true
 	if runAsSudo {
 		// Prepend Sudo and add if Password is required to access Sudo
-		if SudoPassword != "" {
-			cmd = fmt.Sprintf("echo %s | sudo -S su ; sudo %s", SudoPassword, cmd)
+		if sudoPassword := c.sudoPassword(); sudoPassword != "" {
+			cmd = fmt.Sprintf("echo %s | sudo -S su ; sudo %s", sudoPassword, cmd)
 		} else {
 			cmd = fmt.Sprintf("sudo %s", cmd)
 		}
@@ -107,25 +165,51 @@ func (c *client) RunCommand(cmd string) ([]byte, []byte, error) {
 	if err != nil {
 		return nil, nil, fmt.Errorf("unable to run command: %s", err)
 	}
-	stdOut, err := ioutil.ReadAll(stdOutPipe)
-	stdErr, err := ioutil.ReadAll(stdErrPipe)
-	err = session.Wait()
+
+	waited := make(chan error, 1)
+	go func() { waited <- session.Wait() }()
+	select {
+	case err = <-waited:
+	case <-ctx.Done():
+		session.Signal(ssh.SIGTERM)
+		select {
+		case <-waited:
+		case <-time.After(killDelay):
+			session.Signal(ssh.SIGKILL)
+			session.Close()
+			<-waited
+		}
+		err = ctx.Err()
+	}
 	if err != nil {
 		retError := err
 		switch err.(type) {
 		case *ssh.ExitError:
-			retError = fmt.Errorf("command %s failed: %s", cmd, err)
+			retError = fmt.Errorf("command %s failed: %w", cmd, err)
 		case *ssh.ExitMissingError:
-			retError = fmt.Errorf("command %s failed (no exit status): %s", cmd, err)
+			retError = fmt.Errorf("command %s failed (no exit status): %w", cmd, err)
 		default:
-			retError = fmt.Errorf("command %s failed: %s", cmd, err)
+			retError = fmt.Errorf("command %s failed: %w", cmd, err)
 		}
 
-		zap.L().Debug("Error ", zap.String("stdout", string(stdOut)), zap.String("stderr", string(stdErr)))
+		zap.L().Debug("Error ", zap.String("stdout", stdOut.String()), zap.String("stderr", stdErr.String()))
 
-		return stdOut, stdErr, retError
+		return stdOut.Bytes(), stdErr.Bytes(), retError
+	}
+	return stdOut.Bytes(), stdErr.Bytes(), nil
+}
+
+// ExitStatus returns the exit status of the remote command which failed with
+// err, -1 when it did not exit on its own.
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus()
 	}
-	return stdOut, stdErr, nil
+	return -1
 }
 
 // Upload writes a file to the machine
@@ -97,6 +97,12 @@ var (
 	Pf9DBDir = filepath.Join(Pf9Dir, "db")
 	// Pf9DBLoc represents location of the config file.
 	Pf9DBLoc = filepath.Join(Pf9DBDir, "config.json")
+	// Pf9ContextDir is the dir for storing named contexts
+	Pf9ContextDir = filepath.Join(Pf9DBDir, "contexts")
+	// Pf9CurrentContextLoc stores the name of the context in use
+	Pf9CurrentContextLoc = filepath.Join(Pf9DBDir, "current-context")
+	// Pf9SecretsLoc is the age encrypted file storing the credentials
+	Pf9SecretsLoc = filepath.Join(Pf9DBDir, "secrets.age")
 	// Pf9Log represents location of the log.
 	Pf9Log = filepath.Join(Pf9LogDir, "pf9ctl.log")
 	// WaitPeriod is the sleep period for the cli